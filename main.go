@@ -9,6 +9,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	baseApi "github.com/kubeshark/base/pkg/api"
+	"github.com/kubeshark/hub/pkg/anomaly"
 	"github.com/kubeshark/hub/pkg/api"
 	"github.com/kubeshark/hub/pkg/app"
 	"github.com/kubeshark/hub/pkg/config"
@@ -17,8 +18,13 @@ import (
 	"github.com/kubeshark/hub/pkg/entries"
 	"github.com/kubeshark/hub/pkg/middlewares"
 	"github.com/kubeshark/hub/pkg/oas"
+	"github.com/kubeshark/hub/pkg/otlp"
+	"github.com/kubeshark/hub/pkg/providers"
 	"github.com/kubeshark/hub/pkg/routes"
 	"github.com/kubeshark/hub/pkg/servicemap"
+	"github.com/kubeshark/hub/pkg/suggestions"
+	"github.com/kubeshark/hub/pkg/top"
+	"github.com/kubeshark/hub/pkg/traces"
 	"github.com/kubeshark/hub/pkg/utils"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
@@ -27,6 +33,25 @@ import (
 var namespace = flag.String("namespace", "", "Resolve IPs if they belong to resources in this namespace (default is all)")
 var port = flag.Int("port", 80, "Port number of the HTTP server")
 var debug = flag.Bool("debug", false, "Enable debug mode")
+var serviceMapMaxNodes = flag.Int("service-map-max-nodes", 0, "Maximum number of service map nodes, the least recently seen ones are evicted (default is no limit)")
+var serviceMapMaxEdges = flag.Int("service-map-max-edges", 0, "Maximum number of service map edges, the least recently seen ones are evicted (default is no limit)")
+var serviceMapIdleTimeout = flag.Duration("service-map-idle-timeout", 0, "Evict the service map nodes and edges that are not seen for this long (default is never)")
+var serviceMapCollapseUnresolved = flag.String("service-map-collapse-unresolved", "", "Collapse the unresolved IPs of the service map into CIDR buckets (cidr) or a single node (external)")
+var serviceMapCollapseIPv4Prefix = flag.Int("service-map-collapse-ipv4-prefix", 24, "Prefix length of the IPv4 CIDR buckets of the unresolved IPs")
+var serviceMapCollapseIPv6Prefix = flag.Int("service-map-collapse-ipv6-prefix", 64, "Prefix length of the IPv6 CIDR buckets of the unresolved IPs")
+var serviceMapUpdateInterval = flag.Duration("service-map-update-interval", 2*time.Second, "Interval of the service map deltas sent to the subscribed WebSockets")
+var statsPersistInterval = flag.Duration("stats-persist-interval", time.Minute, "Interval of saving the traffic stats to the data directory")
+var costPricePerGB = flag.Float64("cost-price-per-gb", 0, "Price per GB of all the traffic in the cost report, e.g. the cost of observing it")
+var costIntraNamespacePricePerGB = flag.Float64("cost-intra-namespace-price-per-gb", 0, "Price per GB of the traffic within a namespace in the cost report")
+var costCrossNamespacePricePerGB = flag.Float64("cost-cross-namespace-price-per-gb", 0, "Price per GB of the traffic between namespaces in the cost report")
+var costExternalPricePerGB = flag.Float64("cost-external-price-per-gb", 0, "Price per GB of the traffic with unresolved or external peers in the cost report")
+var anomalyDetection = flag.Bool("anomaly-detection", true, "Detect the spikes, drops and error rate surges of the traffic")
+var anomalySensitivity = flag.Float64("anomaly-sensitivity", anomaly.DefaultSensitivity, "Number of standard deviations above the learned baseline that is reported as a spike")
+var otlpEndpoint = flag.String("otlp-endpoint", "", "Base URL of an OTLP/HTTP receiver to export the entries to as spans, e.g. http://otel-collector:4318 (default is no export)")
+var otlpHeaders = flag.String("otlp-headers", "", "Comma separated name=value headers sent to the OTLP receiver, e.g. its API key")
+var otlpBatchSize = flag.Int("otlp-batch-size", otlp.DefaultBatchSize, "Maximum number of spans sent to the OTLP receiver at once")
+var otlpFlushInterval = flag.Duration("otlp-flush-interval", otlp.DefaultFlushInterval, "Interval of sending the spans of an incomplete batch to the OTLP receiver")
+var otlpMaxRetries = flag.Int("otlp-max-retries", otlp.DefaultMaxRetries, "Number of retries of a batch when the OTLP receiver is unreachable or too busy")
 
 func main() {
 	flag.Parse()
@@ -64,6 +89,7 @@ func hostApi(socketHarOutputChannel chan<- *baseApi.OutputChannelItem) *gin.Engi
 	eventHandlers := api.RoutesEventHandlers{
 		SocketOutChannel: socketHarOutputChannel,
 	}
+	api.SetImportChannel(socketHarOutputChannel)
 
 	ginApp.Use(middlewares.CORSMiddleware())
 
@@ -81,8 +107,10 @@ func hostApi(socketHarOutputChannel chan<- *baseApi.OutputChannelItem) *gin.Engi
 	routes.EntriesRoutes(ginApp)
 	routes.MetadataRoutes(ginApp)
 	routes.StatusRoutes(ginApp)
+	routes.MetricsRoutes(ginApp)
 	routes.DbRoutes(ginApp)
 	routes.ReplayRoutes(ginApp)
+	routes.TracesRoutes(ginApp)
 
 	return ginApp
 }
@@ -93,6 +121,20 @@ func runInApiServerMode(namespace string) *gin.Engine {
 	}
 	app.ConfigureBasenineServer(db.BasenineHost, db.BaseninePort, config.Config.MaxDBSizeBytes, config.Config.LogLevel, config.Config.InsertionFilter)
 	api.StartResolving(namespace)
+	providers.StartStatsPersistence(*statsPersistInterval)
+	providers.SetDefaultCostPrices(providers.CostPrices{
+		Observability: *costPricePerGB,
+		Intra:         *costIntraNamespacePricePerGB,
+		Cross:         *costCrossNamespacePricePerGB,
+		External:      *costExternalPricePerGB,
+	})
+	if *anomalyDetection {
+		dependency.GetInstance(dependency.AnomalyDetectorDependency).(*anomaly.Detector).SetSensitivity(*anomalySensitivity)
+		api.StartAnomalyDetection()
+	}
+	if *otlpEndpoint != "" {
+		startOtlpExport()
+	}
 
 	enableExpFeatureIfNeeded()
 
@@ -106,7 +148,34 @@ func enableExpFeatureIfNeeded() {
 	}
 	if config.Config.ServiceMap {
 		serviceMapGenerator := dependency.GetInstance(dependency.ServiceMapGeneratorDependency).(servicemap.ServiceMap)
+		serviceMapGenerator.SetOptions(servicemap.ServiceMapOptions{
+			MaxNodes:           *serviceMapMaxNodes,
+			MaxEdges:           *serviceMapMaxEdges,
+			IdleTimeout:        *serviceMapIdleTimeout,
+			CollapseUnresolved: *serviceMapCollapseUnresolved,
+			CollapseIPv4Prefix: *serviceMapCollapseIPv4Prefix,
+			CollapseIPv6Prefix: *serviceMapCollapseIPv6Prefix,
+		})
 		serviceMapGenerator.Enable()
+		api.StartServiceMapBroadcaster(*serviceMapUpdateInterval)
+	}
+}
+
+func startOtlpExport() {
+	headers, err := otlp.ParseHeaders(*otlpHeaders)
+	if err != nil {
+		log.Fatal().Err(err).Msg("While parsing the OTLP headers!")
+	}
+
+	exporter := dependency.GetInstance(dependency.OtlpExporterDependency).(*otlp.Exporter)
+	if err := exporter.Start(otlp.Options{
+		Endpoint:      *otlpEndpoint,
+		Headers:       headers,
+		BatchSize:     *otlpBatchSize,
+		FlushInterval: *otlpFlushInterval,
+		MaxRetries:    *otlpMaxRetries,
+	}); err != nil {
+		log.Fatal().Err(err).Msg("While starting the OTLP export!")
 	}
 }
 
@@ -117,4 +186,9 @@ func initializeDependencies() {
 	dependency.RegisterGenerator(dependency.EntriesProvider, func() interface{} { return &entries.BasenineEntriesProvider{} })
 	dependency.RegisterGenerator(dependency.EntriesSocketStreamer, func() interface{} { return &api.BasenineEntryStreamer{} })
 	dependency.RegisterGenerator(dependency.EntryStreamerSocketConnector, func() interface{} { return &api.DefaultEntryStreamerSocketConnector{} })
+	dependency.RegisterGenerator(dependency.AnomalyDetectorDependency, func() interface{} { return anomaly.GetDefaultDetectorInstance() })
+	dependency.RegisterGenerator(dependency.TopTrackerDependency, func() interface{} { return top.GetDefaultTrackerInstance() })
+	dependency.RegisterGenerator(dependency.QuerySuggestionsDependency, func() interface{} { return suggestions.GetDefaultCatalogInstance() })
+	dependency.RegisterGenerator(dependency.TracesIndexDependency, func() interface{} { return traces.GetDefaultIndexInstance() })
+	dependency.RegisterGenerator(dependency.OtlpExporterDependency, func() interface{} { return otlp.GetDefaultExporterInstance() })
 }
@@ -0,0 +1,288 @@
+package aggregate
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kubeshark/hub/pkg/export"
+	"github.com/kubeshark/hub/pkg/providers"
+)
+
+const (
+	FunctionCount = "count"
+	FunctionSum   = "sum"
+	FunctionAvg   = "avg"
+	FunctionMin   = "min"
+	FunctionMax   = "max"
+
+	BucketColumn = "bucket"
+	MaxGroups    = 10000
+)
+
+var (
+	functionPattern   = regexp.MustCompile(`^([a-z]+|p[0-9]+(?:\.[0-9]+)?)\((.*)\)$`)
+	percentilePattern = regexp.MustCompile(`^p([0-9]+(?:\.[0-9]+)?)$`)
+)
+
+// Aggregate is an aggregate function of a numeric field, e.g. p95(elapsedTime), only count needs no field.
+// The percentiles are estimated with an error under 10%, as the latencies of the traffic stats are.
+type Aggregate struct {
+	Function   string
+	Percentile float64
+	Field      string
+}
+
+// ParseAggregate parses an aggregate function such as count, sum(requestSize), avg(responseSize) or p95(elapsedTime).
+func ParseAggregate(value string) (*Aggregate, error) {
+	value = strings.TrimSpace(value)
+	if value == FunctionCount {
+		return &Aggregate{Function: FunctionCount}, nil
+	}
+
+	match := functionPattern.FindStringSubmatch(value)
+	if match == nil {
+		return nil, fmt.Errorf("invalid aggregate: %s", value)
+	}
+
+	aggregate := &Aggregate{Function: match[1], Field: strings.TrimSpace(match[2])}
+	switch aggregate.Function {
+	case FunctionCount:
+		return aggregate, nil
+	case FunctionSum, FunctionAvg, FunctionMin, FunctionMax:
+	default:
+		percentileMatch := percentilePattern.FindStringSubmatch(aggregate.Function)
+		if percentileMatch == nil {
+			return nil, fmt.Errorf("invalid aggregate: %s, the functions are count, sum, avg, min, max and pNN", value)
+		}
+		aggregate.Percentile, _ = strconv.ParseFloat(percentileMatch[1], 64)
+		if aggregate.Percentile <= 0 || aggregate.Percentile > 100 {
+			return nil, fmt.Errorf("invalid aggregate: %s, the percentile must be between 0 and 100", value)
+		}
+	}
+
+	if aggregate.Field == "" {
+		return nil, fmt.Errorf("invalid aggregate: %s, a field is required", value)
+	}
+
+	return aggregate, nil
+}
+
+func (a *Aggregate) Name() string {
+	if a.Field == "" {
+		return a.Function
+	}
+	return fmt.Sprintf("%s(%s)", a.Function, a.Field)
+}
+
+type Spec struct {
+	GroupBy    []string
+	Aggregates []*Aggregate
+	Bucket     time.Duration
+}
+
+// Result is a table of a row per group, with the group by fields, the bucket if any and the aggregates as columns.
+// It is partial when the evaluation has timed out or the groups have exceeded MaxGroups.
+type Result struct {
+	Columns      []string        `json:"columns"`
+	Rows         [][]interface{} `json:"rows"`
+	EntriesCount int64           `json:"entriesCount"`
+	Partial      bool            `json:"partial"`
+	Reason       string          `json:"reason,omitempty"`
+}
+
+type accumulator struct {
+	count     int64
+	sum       float64
+	min       float64
+	max       float64
+	histogram providers.LatencyHistogram
+}
+
+type group struct {
+	key          string
+	values       []interface{}
+	bucket       int64
+	accumulators []*accumulator
+}
+
+// Aggregator aggregates the entries, decoded as JSON objects, one by one.
+type Aggregator struct {
+	spec          *Spec
+	groups        map[string]*group
+	entriesCount  int64
+	groupsLimited bool
+}
+
+func NewAggregator(spec *Spec) *Aggregator {
+	return &Aggregator{
+		spec:   spec,
+		groups: make(map[string]*group),
+	}
+}
+
+func (a *Aggregator) Add(object map[string]interface{}) {
+	a.entriesCount++
+
+	values := make([]interface{}, len(a.spec.GroupBy))
+	for i, field := range a.spec.GroupBy {
+		values[i] = export.GetField(object, field)
+	}
+
+	var bucket int64
+	if a.spec.Bucket > 0 {
+		bucketMs := a.spec.Bucket.Milliseconds()
+		timestamp, _ := getNumber(object["timestamp"])
+		bucket = int64(timestamp) - int64(timestamp)%bucketMs
+	}
+
+	g := a.getGroup(values, bucket)
+	if g == nil {
+		return
+	}
+
+	for i, aggregate := range a.spec.Aggregates {
+		acc := g.accumulators[i]
+		if aggregate.Field == "" {
+			acc.count++
+			continue
+		}
+
+		fieldValue := export.GetField(object, aggregate.Field)
+		if aggregate.Function == FunctionCount {
+			if fieldValue != nil {
+				acc.count++
+			}
+			continue
+		}
+
+		number, ok := getNumber(fieldValue)
+		if !ok {
+			continue
+		}
+		if acc.count == 0 || number < acc.min {
+			acc.min = number
+		}
+		if acc.count == 0 || number > acc.max {
+			acc.max = number
+		}
+		acc.count++
+		acc.sum += number
+		if acc.histogram != nil {
+			acc.histogram.Add(int64(number))
+		}
+	}
+}
+
+func (a *Aggregator) getGroup(values []interface{}, bucket int64) *group {
+	keyBytes, _ := json.Marshal(values)
+	key := fmt.Sprintf("%d:%s", bucket, keyBytes)
+
+	if g, ok := a.groups[key]; ok {
+		return g
+	}
+	if len(a.groups) >= MaxGroups {
+		a.groupsLimited = true
+		return nil
+	}
+
+	g := &group{key: key, values: values, bucket: bucket, accumulators: make([]*accumulator, len(a.spec.Aggregates))}
+	for i, aggregate := range a.spec.Aggregates {
+		g.accumulators[i] = &accumulator{}
+		if aggregate.Percentile > 0 {
+			g.accumulators[i].histogram = providers.LatencyHistogram{}
+		}
+	}
+	a.groups[key] = g
+
+	return g
+}
+
+func getNumber(value interface{}) (float64, bool) {
+	switch value := value.(type) {
+	case json.Number:
+		number, err := value.Float64()
+		return number, err == nil
+	case float64:
+		return value, true
+	case int64:
+		return float64(value), true
+	case int:
+		return float64(value), true
+	default:
+		return 0, false
+	}
+}
+
+// Result returns the rows sorted by bucket and then by the group by values.
+func (a *Aggregator) Result() *Result {
+	result := &Result{
+		Columns:      make([]string, 0),
+		Rows:         make([][]interface{}, 0, len(a.groups)),
+		EntriesCount: a.entriesCount,
+	}
+
+	result.Columns = append(result.Columns, a.spec.GroupBy...)
+	if a.spec.Bucket > 0 {
+		result.Columns = append(result.Columns, BucketColumn)
+	}
+	for _, aggregate := range a.spec.Aggregates {
+		result.Columns = append(result.Columns, aggregate.Name())
+	}
+
+	groups := make([]*group, 0, len(a.groups))
+	for _, g := range a.groups {
+		groups = append(groups, g)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].bucket != groups[j].bucket {
+			return groups[i].bucket < groups[j].bucket
+		}
+		return groups[i].key < groups[j].key
+	})
+
+	for _, g := range groups {
+		row := make([]interface{}, 0, len(result.Columns))
+		row = append(row, g.values...)
+		if a.spec.Bucket > 0 {
+			row = append(row, g.bucket)
+		}
+		for i, aggregate := range a.spec.Aggregates {
+			row = append(row, getAggregateValue(aggregate, g.accumulators[i]))
+		}
+		result.Rows = append(result.Rows, row)
+	}
+
+	if a.groupsLimited {
+		result.Partial = true
+		result.Reason = fmt.Sprintf("more than %d groups", MaxGroups)
+	}
+
+	return result
+}
+
+func getAggregateValue(aggregate *Aggregate, acc *accumulator) interface{} {
+	if aggregate.Function == FunctionCount {
+		return acc.count
+	}
+	if acc.count == 0 {
+		return nil
+	}
+
+	switch aggregate.Function {
+	case FunctionSum:
+		return acc.sum
+	case FunctionAvg:
+		return acc.sum / float64(acc.count)
+	case FunctionMin:
+		return acc.min
+	case FunctionMax:
+		return acc.max
+	default:
+		return acc.histogram.Percentile(aggregate.Percentile)
+	}
+}
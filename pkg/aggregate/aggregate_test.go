@@ -0,0 +1,93 @@
+package aggregate
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAggregate(t *testing.T) {
+	tests := map[string]struct {
+		value    string
+		expected *Aggregate
+	}{
+		"count":        {value: "count", expected: &Aggregate{Function: FunctionCount}},
+		"count field":  {value: "count(response.status)", expected: &Aggregate{Function: FunctionCount, Field: "response.status"}},
+		"sum":          {value: "sum(requestSize)", expected: &Aggregate{Function: FunctionSum, Field: "requestSize"}},
+		"percentile":   {value: " p95(elapsedTime) ", expected: &Aggregate{Function: "p95", Percentile: 95, Field: "elapsedTime"}},
+		"fraction":     {value: "p99.9(elapsedTime)", expected: &Aggregate{Function: "p99.9", Percentile: 99.9, Field: "elapsedTime"}},
+		"unknown":      {value: "median(elapsedTime)"},
+		"no field":     {value: "avg()"},
+		"percentile 0": {value: "p0(elapsedTime)"},
+		"malformed":    {value: "sum(elapsedTime"},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			aggregate, err := ParseAggregate(test.value)
+			if test.expected == nil {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, aggregate)
+		})
+	}
+}
+
+func decode(t *testing.T, data string) map[string]interface{} {
+	decoder := json.NewDecoder(strings.NewReader(data))
+	decoder.UseNumber()
+
+	var object map[string]interface{}
+	require.NoError(t, decoder.Decode(&object))
+	return object
+}
+
+func TestAggregator(t *testing.T) {
+	count, _ := ParseAggregate("count")
+	avg, _ := ParseAggregate("avg(elapsedTime)")
+	p95, _ := ParseAggregate("p95(elapsedTime)")
+	max, _ := ParseAggregate("max(responseSize)")
+
+	aggregator := NewAggregator(&Spec{
+		GroupBy:    []string{"dst.name"},
+		Aggregates: []*Aggregate{count, avg, p95, max},
+		Bucket:     time.Minute,
+	})
+
+	aggregator.Add(decode(t, `{"dst": {"name": "carts"}, "timestamp": 60500, "elapsedTime": 10, "responseSize": 100}`))
+	aggregator.Add(decode(t, `{"dst": {"name": "carts"}, "timestamp": 61000, "elapsedTime": 30, "responseSize": 300}`))
+	aggregator.Add(decode(t, `{"dst": {"name": "orders"}, "timestamp": 62000, "elapsedTime": 20}`))
+	aggregator.Add(decode(t, `{"dst": {"name": "carts"}, "timestamp": 125000, "elapsedTime": 1}`))
+	aggregator.Add(decode(t, `{"timestamp": 1000}`))
+
+	result := aggregator.Result()
+	assert.Equal(t, []string{"dst.name", "bucket", "count", "avg(elapsedTime)", "p95(elapsedTime)", "max(responseSize)"}, result.Columns)
+	assert.Equal(t, [][]interface{}{
+		{nil, int64(0), int64(1), nil, nil, nil},
+		{"carts", int64(60000), int64(2), float64(20), float64(32), float64(300)},
+		{"orders", int64(60000), int64(1), float64(20), float64(20.75), nil},
+		{"carts", int64(120000), int64(1), float64(1), float64(1), nil},
+	}, result.Rows)
+	assert.Equal(t, int64(5), result.EntriesCount)
+	assert.False(t, result.Partial)
+}
+
+func TestAggregatorGroupsLimit(t *testing.T) {
+	count, _ := ParseAggregate("count")
+	aggregator := NewAggregator(&Spec{GroupBy: []string{"id"}, Aggregates: []*Aggregate{count}})
+
+	for i := 0; i <= MaxGroups; i++ {
+		aggregator.Add(map[string]interface{}{"id": float64(i)})
+	}
+
+	result := aggregator.Result()
+	assert.Len(t, result.Rows, MaxGroups)
+	assert.True(t, result.Partial)
+	assert.Equal(t, int64(MaxGroups+1), result.EntriesCount)
+}
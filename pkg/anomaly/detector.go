@@ -0,0 +1,304 @@
+package anomaly
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	EventSpike     = "spike"
+	EventDrop      = "drop"
+	EventErrorRate = "errorRate"
+
+	DefaultSensitivity = 3.0
+
+	alpha             = 0.05 // weight of the new observation in the EWMA
+	minSamples        = 30   // observations needed before a baseline is trusted
+	minSpikeCount     = 10   // entries per minute below which a spike is not worth an event
+	minDropMean       = 5.0  // expected entries per minute below which a drop to zero is not worth an event
+	minErrorEntries   = 10   // entries per minute below which the error rate is too noisy
+	minErrorRateSurge = 0.1  // the error rate must surge at least this much above the baseline
+	cooldown          = 10 * time.Minute
+	maxKeptEvents     = 500
+	maxCatchUpMinutes = 60
+	maxIdleMinutes    = 7 * 24 * 60
+)
+
+// SeriesKey identifies a traffic series, either a protocol and method or a protocol and destination service.
+type SeriesKey struct {
+	Protocol string `json:"protocol"`
+	Method   string `json:"method,omitempty"`
+	Service  string `json:"service,omitempty"`
+}
+
+func (k SeriesKey) String() string {
+	if k.Service != "" {
+		return fmt.Sprintf("%s %s", k.Protocol, k.Service)
+	}
+	return fmt.Sprintf("%s %s", k.Protocol, k.Method)
+}
+
+// Sample is what a series saw within a minute.
+type Sample struct {
+	EntriesCount  int
+	FailuresCount int
+}
+
+type Event struct {
+	Id       int       `json:"id"`
+	Type     string    `json:"type"`
+	Series   SeriesKey `json:"series"`
+	Time     int64     `json:"timestamp"`
+	Value    float64   `json:"value"`
+	Expected float64   `json:"expected"`
+	StdDev   float64   `json:"stdDev"`
+	Message  string    `json:"message"`
+}
+
+// ewma is an exponentially weighted moving average along with its variance.
+type ewma struct {
+	Mean     float64
+	Variance float64
+	Count    int
+}
+
+func (e *ewma) add(x float64) {
+	if e.Count == 0 {
+		e.Mean = x
+	} else {
+		diff := x - e.Mean
+		e.Mean += alpha * diff
+		e.Variance = (1 - alpha) * (e.Variance + alpha*diff*diff)
+	}
+	e.Count++
+}
+
+func (e *ewma) stdDev() float64 {
+	return math.Sqrt(e.Variance)
+}
+
+// baseline learns the usual value of a series for each hour of the day,
+// the global average is used until the hour of the day has enough observations.
+type baseline struct {
+	global ewma
+	hourly [24]ewma
+}
+
+func (b *baseline) get(t time.Time) (*ewma, bool) {
+	if hourly := &b.hourly[t.Hour()]; hourly.Count >= minSamples {
+		return hourly, true
+	}
+	return &b.global, b.global.Count >= minSamples
+}
+
+func (b *baseline) add(t time.Time, x float64) {
+	b.global.add(x)
+	b.hourly[t.Hour()].add(x)
+}
+
+type series struct {
+	entries   baseline
+	errorRate baseline
+	lastSeen  time.Time
+	lastEvent map[string]time.Time
+}
+
+type Detector struct {
+	lock        sync.Mutex
+	sensitivity float64
+	series      map[SeriesKey]*series
+	events      []*Event
+	nextEventId int
+	lastMinute  time.Time
+}
+
+var instance *Detector
+var once sync.Once
+
+func GetDefaultDetectorInstance() *Detector {
+	once.Do(func() {
+		instance = NewDetector(DefaultSensitivity)
+	})
+
+	return instance
+}
+
+func NewDetector(sensitivity float64) *Detector {
+	if sensitivity <= 0 {
+		sensitivity = DefaultSensitivity
+	}
+
+	return &Detector{
+		sensitivity: sensitivity,
+		series:      make(map[SeriesKey]*series),
+		nextEventId: 1,
+	}
+}
+
+// SetSensitivity sets how many standard deviations above the baseline count as a spike.
+func (d *Detector) SetSensitivity(sensitivity float64) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if sensitivity > 0 {
+		d.sensitivity = sensitivity
+	}
+}
+
+// Observe evaluates the samples of a minute against the baselines, then learns them.
+// The known series missing from the samples are observed as zero. It returns the raised events.
+func (d *Detector) Observe(minute time.Time, samples map[SeriesKey]Sample) []*Event {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	return d.observe(minute, samples)
+}
+
+func (d *Detector) observe(minute time.Time, samples map[SeriesKey]Sample) []*Event {
+	for k := range samples {
+		if _, ok := d.series[k]; !ok {
+			d.series[k] = &series{lastEvent: make(map[string]time.Time)}
+		}
+	}
+
+	keys := make([]SeriesKey, 0, len(d.series))
+	for k := range d.series {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].String() < keys[j].String()
+	})
+
+	events := make([]*Event, 0)
+	for _, k := range keys {
+		s := d.series[k]
+		sample, seen := samples[k]
+		if seen {
+			s.lastSeen = minute
+		} else if minute.Sub(s.lastSeen) > maxIdleMinutes*time.Minute {
+			delete(d.series, k)
+			continue
+		}
+
+		if event := d.evaluate(k, s, minute, sample); event != nil {
+			events = append(events, event)
+		}
+
+		s.entries.add(minute, float64(sample.EntriesCount))
+		if sample.EntriesCount >= minErrorEntries {
+			s.errorRate.add(minute, float64(sample.FailuresCount)/float64(sample.EntriesCount))
+		}
+	}
+
+	if minute.After(d.lastMinute) {
+		d.lastMinute = minute
+	}
+
+	return events
+}
+
+func (d *Detector) evaluate(k SeriesKey, s *series, minute time.Time, sample Sample) *Event {
+	entries := float64(sample.EntriesCount)
+
+	if b, ok := s.entries.get(minute); ok {
+		// the counts are roughly Poisson distributed, so the deviation is at least the square root of the mean
+		stdDev := math.Max(b.stdDev(), math.Max(math.Sqrt(b.Mean), 1))
+
+		if sample.EntriesCount == 0 && b.Mean >= minDropMean {
+			return d.raise(k, s, EventDrop, minute, entries, b.Mean, stdDev,
+				fmt.Sprintf("%s dropped to zero, %.0f entries per minute are expected.", k, b.Mean))
+		}
+
+		if sample.EntriesCount >= minSpikeCount && entries > b.Mean+d.sensitivity*stdDev {
+			return d.raise(k, s, EventSpike, minute, entries, b.Mean, stdDev,
+				fmt.Sprintf("%s spiked to %.0f entries per minute, %.0f are expected.", k, entries, b.Mean))
+		}
+	}
+
+	if sample.EntriesCount < minErrorEntries {
+		return nil
+	}
+
+	if b, ok := s.errorRate.get(minute); ok {
+		errorRate := float64(sample.FailuresCount) / entries
+		if errorRate > b.Mean+math.Max(d.sensitivity*b.stdDev(), minErrorRateSurge) {
+			return d.raise(k, s, EventErrorRate, minute, errorRate, b.Mean, b.stdDev(),
+				fmt.Sprintf("%s error rate surged to %.0f%%, %.0f%% is expected.", k, errorRate*100, b.Mean*100))
+		}
+	}
+
+	return nil
+}
+
+// raise records an event unless the same kind of event of the series is raised within the cooldown.
+func (d *Detector) raise(k SeriesKey, s *series, eventType string, minute time.Time, value float64, expected float64, stdDev float64, message string) *Event {
+	if last, ok := s.lastEvent[eventType]; ok && minute.Sub(last) < cooldown {
+		return nil
+	}
+	s.lastEvent[eventType] = minute
+
+	event := &Event{
+		Id:       d.nextEventId,
+		Type:     eventType,
+		Series:   k,
+		Time:     minute.UnixMilli(),
+		Value:    value,
+		Expected: expected,
+		StdDev:   stdDev,
+		Message:  message,
+	}
+	d.nextEventId++
+
+	d.events = append(d.events, event)
+	if len(d.events) > maxKeptEvents {
+		d.events = d.events[len(d.events)-maxKeptEvents:]
+	}
+
+	return event
+}
+
+// ObserveUntil observes every complete minute since the last observed one up to the given time,
+// fetching the samples of each minute with the given function.
+func (d *Detector) ObserveUntil(now time.Time, getSamples func(minute time.Time) map[SeriesKey]Sample) []*Event {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	lastComplete := now.Truncate(time.Minute).Add(-time.Minute)
+	minute := d.lastMinute.Add(time.Minute)
+	if d.lastMinute.IsZero() || lastComplete.Sub(minute) > maxCatchUpMinutes*time.Minute {
+		minute = lastComplete.Add(-(maxCatchUpMinutes - 1) * time.Minute)
+		if d.lastMinute.IsZero() {
+			minute = lastComplete
+		}
+	}
+
+	events := make([]*Event, 0)
+	for ; !minute.After(lastComplete); minute = minute.Add(time.Minute) {
+		events = append(events, d.observe(minute, getSamples(minute))...)
+	}
+
+	return events
+}
+
+// GetEvents returns the kept events raised at or after the given time, newest first.
+// A limit less than 1 returns all of them.
+func (d *Detector) GetEvents(sinceMs int64, limit int) []*Event {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	events := make([]*Event, 0)
+	for i := len(d.events) - 1; i >= 0; i-- {
+		if d.events[i].Time < sinceMs {
+			continue
+		}
+		events = append(events, d.events[i])
+		if limit > 0 && len(events) >= limit {
+			break
+		}
+	}
+
+	return events
+}
@@ -0,0 +1,129 @@
+package anomaly
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kubeshark/hub/pkg/providers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	httpGet   = SeriesKey{Protocol: "HTTP", Method: "GET"}
+	httpCarts = SeriesKey{Protocol: "HTTP", Service: "carts"}
+	start     = time.Date(2022, time.Month(1), 1, 10, 0, 0, 0, time.UTC)
+)
+
+// learn observes a steady traffic of a series long enough for its baseline to be trusted.
+func learn(d *Detector, k SeriesKey, sample Sample) time.Time {
+	minute := start
+	for i := 0; i < 2*minSamples; i++ {
+		events := d.Observe(minute, map[SeriesKey]Sample{k: sample})
+		if len(events) > 0 {
+			panic("unexpected event while learning")
+		}
+		minute = minute.Add(time.Minute)
+	}
+	return minute
+}
+
+func TestDetectorEvents(t *testing.T) {
+	tests := map[string]struct {
+		sample   Sample
+		expected string
+	}{
+		"usual traffic":     {sample: Sample{EntriesCount: 105, FailuresCount: 2}, expected: ""},
+		"spike":             {sample: Sample{EntriesCount: 300}, expected: EventSpike},
+		"drop to zero":      {sample: Sample{}, expected: EventDrop},
+		"error rate surge":  {sample: Sample{EntriesCount: 100, FailuresCount: 40}, expected: EventErrorRate},
+		"moderate increase": {sample: Sample{EntriesCount: 120, FailuresCount: 5}, expected: ""},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			d := NewDetector(DefaultSensitivity)
+			minute := learn(d, httpGet, Sample{EntriesCount: 100, FailuresCount: 1})
+
+			samples := map[SeriesKey]Sample{}
+			if test.sample.EntriesCount > 0 {
+				samples[httpGet] = test.sample
+			}
+
+			events := d.Observe(minute, samples)
+			if test.expected == "" {
+				assert.Empty(t, events)
+				return
+			}
+
+			require.Len(t, events, 1)
+			assert.Equal(t, test.expected, events[0].Type)
+			assert.Equal(t, httpGet, events[0].Series)
+			assert.Equal(t, minute.UnixMilli(), events[0].Time)
+			assert.NotEmpty(t, events[0].Message)
+		})
+	}
+}
+
+func TestDetectorIsQuietWhileLearning(t *testing.T) {
+	d := NewDetector(DefaultSensitivity)
+	d.Observe(start, map[SeriesKey]Sample{httpGet: {EntriesCount: 100}})
+	assert.Empty(t, d.Observe(start.Add(time.Minute), map[SeriesKey]Sample{httpGet: {EntriesCount: 1000}}))
+}
+
+func TestDetectorCooldown(t *testing.T) {
+	d := NewDetector(DefaultSensitivity)
+	minute := learn(d, httpGet, Sample{EntriesCount: 100})
+
+	assert.Len(t, d.Observe(minute, map[SeriesKey]Sample{httpGet: {EntriesCount: 1000}}), 1)
+	assert.Empty(t, d.Observe(minute.Add(time.Minute), map[SeriesKey]Sample{httpGet: {EntriesCount: 3000}}))
+
+	events := d.GetEvents(0, 0)
+	assert.Len(t, events, 1)
+	assert.Empty(t, d.GetEvents(minute.Add(time.Minute).UnixMilli(), 0))
+}
+
+func TestObserveUntil(t *testing.T) {
+	d := NewDetector(DefaultSensitivity)
+	observed := make([]time.Time, 0)
+	getSamples := func(minute time.Time) map[SeriesKey]Sample {
+		observed = append(observed, minute)
+		return map[SeriesKey]Sample{}
+	}
+
+	// the first call observes only the last complete minute
+	d.ObserveUntil(start.Add(30*time.Second), getSamples)
+	assert.Equal(t, []time.Time{start.Add(-time.Minute)}, observed)
+
+	// the following calls catch up on the missed minutes
+	observed = observed[:0]
+	d.ObserveUntil(start.Add(3*time.Minute+10*time.Second), getSamples)
+	assert.Equal(t, []time.Time{start, start.Add(time.Minute), start.Add(2 * time.Minute)}, observed)
+
+	// but not on more than an hour of them
+	observed = observed[:0]
+	d.ObserveUntil(start.Add(5*time.Hour), getSamples)
+	assert.Len(t, observed, maxCatchUpMinutes)
+	assert.Equal(t, start.Add(5*time.Hour-time.Minute), observed[len(observed)-1])
+}
+
+func TestSamplesFromBucket(t *testing.T) {
+	bucket := &providers.TimeFrameStatsValue{
+		ProtocolStats: map[string]providers.ProtocolStats{
+			"HTTP": {MethodsStats: map[string]*providers.SizeAndEntriesCount{
+				"GET": {EntriesCount: 3, FailuresCount: 1},
+			}},
+		},
+		Flows: []*providers.FlowStats{
+			{Protocol: "HTTP", Method: "GET", Service: "carts", EntriesCount: 2, FailuresCount: 1},
+			{Protocol: "HTTP", Method: "POST", Service: "carts", EntriesCount: 1},
+			{Protocol: "HTTP", Method: "GET", Service: providers.OtherFlowName, EntriesCount: 5},
+		},
+	}
+
+	assert.Equal(t, map[SeriesKey]Sample{
+		httpGet:   {EntriesCount: 3, FailuresCount: 1},
+		httpCarts: {EntriesCount: 3, FailuresCount: 1},
+	}, SamplesFromBucket(bucket))
+	assert.Empty(t, SamplesFromBucket(nil))
+}
@@ -0,0 +1,37 @@
+package anomaly
+
+import (
+	"github.com/kubeshark/hub/pkg/providers"
+)
+
+// SamplesFromBucket turns a minute bucket of the traffic stats into the samples of its protocol and method series
+// and, where the destination is known, of its protocol and service series.
+func SamplesFromBucket(bucket *providers.TimeFrameStatsValue) map[SeriesKey]Sample {
+	samples := make(map[SeriesKey]Sample)
+	if bucket == nil {
+		return samples
+	}
+
+	for protocol, protocolStats := range bucket.ProtocolStats {
+		for method, methodStats := range protocolStats.MethodsStats {
+			samples[SeriesKey{Protocol: protocol, Method: method}] = Sample{
+				EntriesCount:  methodStats.EntriesCount,
+				FailuresCount: methodStats.FailuresCount,
+			}
+		}
+	}
+
+	for _, flow := range bucket.Flows {
+		if flow.Service == "" || flow.Service == providers.OtherFlowName {
+			continue
+		}
+
+		k := SeriesKey{Protocol: flow.Protocol, Service: flow.Service}
+		sample := samples[k]
+		sample.EntriesCount += flow.EntriesCount
+		sample.FailuresCount += flow.FailuresCount
+		samples[k] = sample
+	}
+
+	return samples
+}
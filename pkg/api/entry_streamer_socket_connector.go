@@ -1,10 +1,12 @@
 package api
 
 import (
+	"encoding/json"
 	"fmt"
 
 	baseApi "github.com/kubeshark/base/pkg/api"
 	"github.com/kubeshark/base/pkg/models"
+	"github.com/kubeshark/hub/pkg/providers/annotations"
 	basenine "github.com/up9inc/basenine/client/go"
 )
 
@@ -22,18 +24,12 @@ func (e *DefaultEntryStreamerSocketConnector) SendEntry(socketId int, entry *bas
 	if params.EnableFullEntries {
 		message, _ = models.CreateFullEntryWebSocketMessage(entry)
 	} else {
-		protocol, ok := protocolsMap[entry.Protocol.ToString()]
-		if !ok {
-			return fmt.Errorf("protocol not found, protocol: %v", protocol)
+		base, err := summarizeEntry(entry)
+		if err != nil {
+			return err
 		}
 
-		extension, ok := extensionsMap[protocol.Name]
-		if !ok {
-			return fmt.Errorf("extension not found, extension: %v", protocol.Name)
-		}
-
-		base := extension.Dissector.Summarize(entry)
-		message, _ = models.CreateBaseEntryWebSocketMessage(base)
+		message, _ = CreateAnnotatedBaseEntryWebSocketMessage(base)
 	}
 
 	if err := SendToSocket(socketId, message); err != nil {
@@ -56,7 +52,7 @@ func (e *DefaultEntryStreamerSocketConnector) SendToastError(socketId int, err e
 	toastBytes, _ := models.CreateWebsocketToastMessage(&models.ToastMessage{
 		Type:      "error",
 		AutoClose: 5000,
-		Text:      fmt.Sprintf("Syntax error: %s", err.Error()),
+		Text:      err.Error(),
 	})
 	if err := SendToSocket(socketId, toastBytes); err != nil {
 		return err
@@ -69,3 +65,43 @@ func (e *DefaultEntryStreamerSocketConnector) CleanupSocket(socketId int) {
 	socketObj := connectedWebsockets[socketId]
 	socketCleanup(socketId, socketObj)
 }
+
+// WebSocketAnnotatedBaseEntryMessage is the entry message of the base entries along with their annotations.
+type WebSocketAnnotatedBaseEntryMessage struct {
+	*models.WebSocketMessageMetadata
+	Data *annotations.AnnotatedBaseEntry `json:"data,omitempty"`
+}
+
+func CreateAnnotatedBaseEntryWebSocketMessage(base *annotations.AnnotatedBaseEntry) ([]byte, error) {
+	message := &WebSocketAnnotatedBaseEntryMessage{
+		WebSocketMessageMetadata: &models.WebSocketMessageMetadata{
+			MessageType: models.WebSocketMessageTypeEntry,
+		},
+		Data: base,
+	}
+	return json.Marshal(message)
+}
+
+// SummarizeEntry summarizes an entry with the dissector of its protocol.
+func SummarizeEntry(entry *baseApi.Entry) (*baseApi.BaseEntry, error) {
+	protocol, ok := protocolsMap[entry.Protocol.ToString()]
+	if !ok {
+		return nil, fmt.Errorf("protocol not found, protocol: %v", entry.Protocol.ToString())
+	}
+
+	extension, ok := extensionsMap[protocol.Name]
+	if !ok {
+		return nil, fmt.Errorf("extension not found, extension: %v", protocol.Name)
+	}
+
+	return extension.Dissector.Summarize(entry), nil
+}
+
+func summarizeEntry(entry *baseApi.Entry) (*annotations.AnnotatedBaseEntry, error) {
+	base, err := SummarizeEntry(entry)
+	if err != nil {
+		return nil, err
+	}
+
+	return annotations.AnnotateBaseEntry(base), nil
+}
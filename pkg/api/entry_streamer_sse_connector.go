@@ -0,0 +1,103 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	baseApi "github.com/kubeshark/base/pkg/api"
+	"github.com/kubeshark/base/pkg/models"
+	basenine "github.com/up9inc/basenine/client/go"
+)
+
+const (
+	SSEEventError = "error"
+
+	sseEventsBufferSize = 100
+)
+
+// SSEEvent is a Server-Sent Event, its Id is the ID of the entry it carries, if any.
+type SSEEvent struct {
+	Id    string
+	Event string
+	Data  string
+}
+
+// SSEEntryStreamerConnector is the connector of an entry stream served over Server-Sent Events.
+// It queues the events for the handler of the request, which is the only one that can write them.
+// Once the context is done the events are dropped, so the streamer keeps draining Basenine until it closes.
+type SSEEntryStreamerConnector struct {
+	ctx       context.Context
+	events    chan *SSEEvent
+	closed    chan struct{}
+	closeOnce sync.Once
+	skipId    string
+}
+
+// NewSSEEntryStreamerConnector creates a connector that skips the entry of skipId, the last one a resumed stream has sent.
+func NewSSEEntryStreamerConnector(ctx context.Context, skipId string) *SSEEntryStreamerConnector {
+	return &SSEEntryStreamerConnector{
+		ctx:    ctx,
+		events: make(chan *SSEEvent, sseEventsBufferSize),
+		closed: make(chan struct{}),
+		skipId: skipId,
+	}
+}
+
+func (e *SSEEntryStreamerConnector) Events() <-chan *SSEEvent {
+	return e.events
+}
+
+// Closed is closed when the stream fails and the handler should end the response.
+func (e *SSEEntryStreamerConnector) Closed() <-chan struct{} {
+	return e.closed
+}
+
+func (e *SSEEntryStreamerConnector) send(event *SSEEvent) {
+	select {
+	case e.events <- event:
+	case <-e.ctx.Done():
+	}
+}
+
+func (e *SSEEntryStreamerConnector) sendJSON(id string, eventType string, data interface{}) error {
+	message, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	e.send(&SSEEvent{Id: id, Event: eventType, Data: string(message)})
+	return nil
+}
+
+func (e *SSEEntryStreamerConnector) SendEntry(socketId int, entry *baseApi.Entry, params *WebSocketParams) error {
+	if e.skipId != "" && entry.Id == e.skipId {
+		return nil
+	}
+
+	if params.EnableFullEntries {
+		return e.sendJSON(entry.Id, string(models.WebSocketMessageTypeFullEntry), entry)
+	}
+
+	base, err := summarizeEntry(entry)
+	if err != nil {
+		return err
+	}
+
+	return e.sendJSON(entry.Id, string(models.WebSocketMessageTypeEntry), base)
+}
+
+func (e *SSEEntryStreamerConnector) SendMetadata(socketId int, metadata *basenine.Metadata) error {
+	return e.sendJSON("", string(models.WebSocketMessageTypeQueryMetadata), metadata)
+}
+
+func (e *SSEEntryStreamerConnector) SendToastError(socketId int, err error) error {
+	return e.sendJSON("", SSEEventError, gin.H{"error": err.Error()})
+}
+
+func (e *SSEEntryStreamerConnector) CleanupSocket(socketId int) {
+	e.closeOnce.Do(func() {
+		close(e.closed)
+	})
+}
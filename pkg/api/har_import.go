@@ -0,0 +1,70 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	baseApi "github.com/kubeshark/base/pkg/api"
+	"github.com/kubeshark/hub/pkg/har"
+)
+
+const maxHarImportErrors = 10
+
+type HarImportResult struct {
+	EntriesCount  int      `json:"entriesCount"`
+	ImportedCount int      `json:"importedCount"`
+	FailedCount   int      `json:"failedCount"`
+	Errors        []string `json:"errors,omitempty"`
+}
+
+var importChannel chan<- *baseApi.OutputChannelItem
+
+// SetImportChannel sets the channel the imported entries are sent to, the same one the workers' entries arrive in,
+// so they go through the same analysis, insertion, stats, service map and OAS as the captured ones.
+func SetImportChannel(channel chan<- *baseApi.OutputChannelItem) {
+	importChannel = channel
+}
+
+// ImportHar decodes a HAR document and sends its entries on for processing.
+func ImportHar(reader io.Reader) (*HarImportResult, error) {
+	if importChannel == nil {
+		return nil, fmt.Errorf("importing is not available")
+	}
+
+	var inputHar har.HAR
+	if err := json.NewDecoder(reader).Decode(&inputHar); err != nil {
+		return nil, fmt.Errorf("invalid HAR: %v", err)
+	}
+
+	return importHarEntries(&inputHar, func(item *baseApi.OutputChannelItem) {
+		importChannel <- item
+	}), nil
+}
+
+func importHarEntries(inputHar *har.HAR, onItem func(item *baseApi.OutputChannelItem)) *HarImportResult {
+	result := &HarImportResult{EntriesCount: len(inputHar.Log.Entries)}
+
+	extension, ok := extensionsMap["http"]
+	if !ok {
+		result.FailedCount = result.EntriesCount
+		result.Errors = append(result.Errors, "the HTTP extension is not loaded")
+		return result
+	}
+
+	for i := range inputHar.Log.Entries {
+		item, err := har.NewOutputChannelItem(&inputHar.Log.Entries[i], extension.Protocol)
+		if err != nil {
+			result.FailedCount++
+			if len(result.Errors) < maxHarImportErrors {
+				result.Errors = append(result.Errors, fmt.Sprintf("entry %d: %v", i, err))
+			}
+			continue
+		}
+
+		onItem(item)
+		result.ImportedCount++
+	}
+
+	return result
+}
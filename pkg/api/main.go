@@ -16,9 +16,13 @@ import (
 	"github.com/kubeshark/hub/pkg/har"
 	"github.com/kubeshark/hub/pkg/holder"
 	"github.com/kubeshark/hub/pkg/oas"
+	"github.com/kubeshark/hub/pkg/otlp"
 	"github.com/kubeshark/hub/pkg/providers"
 	"github.com/kubeshark/hub/pkg/resolver"
 	"github.com/kubeshark/hub/pkg/servicemap"
+	"github.com/kubeshark/hub/pkg/suggestions"
+	"github.com/kubeshark/hub/pkg/top"
+	"github.com/kubeshark/hub/pkg/traces"
 	"github.com/kubeshark/hub/pkg/utils"
 	"github.com/rs/zerolog/log"
 )
@@ -47,13 +51,13 @@ func StartResolving(namespace string) {
 
 func StartReadingEntries(harChannel <-chan *baseApi.OutputChannelItem, workingDir *string, extensionsMap map[string]*baseApi.Extension) {
 	if workingDir != nil && *workingDir != "" {
-		startReadingFiles(*workingDir)
+		startReadingFiles(*workingDir, extensionsMap)
 	} else {
 		startReadingChannel(harChannel, extensionsMap)
 	}
 }
 
-func startReadingFiles(workingDir string) {
+func startReadingFiles(workingDir string, extensionsMap map[string]*baseApi.Extension) {
 	if err := os.MkdirAll(workingDir, os.ModePerm); err != nil {
 		log.Error().Err(err).Str("dir", workingDir).Msg("Failed to create directory!")
 		return
@@ -84,6 +88,12 @@ func startReadingFiles(workingDir string) {
 		var inputHar har.HAR
 		decErr := json.NewDecoder(bufio.NewReader(file)).Decode(&inputHar)
 		utils.CheckErr(decErr)
+		file.Close()
+
+		result := importHarEntries(&inputHar, func(item *baseApi.OutputChannelItem) {
+			handleItem(item, extensionsMap)
+		})
+		log.Info().Str("file", inputFilePath).Interface("result", result).Msg("Imported HAR file:")
 
 		rmErr := os.Remove(inputFilePath)
 		utils.CheckErr(rmErr)
@@ -92,38 +102,63 @@ func startReadingFiles(workingDir string) {
 
 func startReadingChannel(outputItems <-chan *baseApi.OutputChannelItem, extensionsMap map[string]*baseApi.Extension) {
 	for item := range outputItems {
-		extension := extensionsMap[item.Protocol.Name]
-		resolvedSource, resolvedDestination, namespace := resolveIP(item.ConnectionInfo)
-
-		if namespace == "" && item.Namespace != baseApi.UnknownNamespace {
-			namespace = item.Namespace
-		}
+		handleItem(item, extensionsMap)
+	}
+}
 
-		kubesharkEntry := extension.Dissector.Analyze(item, resolvedSource, resolvedDestination, namespace)
+func handleItem(item *baseApi.OutputChannelItem, extensionsMap map[string]*baseApi.Extension) {
+	extension := extensionsMap[item.Protocol.Name]
+	resolvedSource, resolvedDestination, peers := resolveIP(item.ConnectionInfo)
 
-		data, err := json.Marshal(kubesharkEntry)
-		if err != nil {
-			log.Error().Err(err).Msg("While marshaling entry!")
-			continue
-		}
+	// the namespace of the destination takes precedence over the one of the source
+	namespace := peers.Destination
+	if namespace == "" {
+		namespace = peers.Source
+	}
 
-		entryInserter := dependency.GetInstance(dependency.EntriesInserter).(EntryInserter)
-		if err := entryInserter.Insert(kubesharkEntry); err != nil {
-			log.Error().Err(err).Msg("While inserting entry!")
-		}
+	if namespace == "" && item.Namespace != baseApi.UnknownNamespace {
+		namespace = item.Namespace
+	}
 
-		summary := extension.Dissector.Summarize(kubesharkEntry)
-		providers.EntryAdded(len(data), summary)
+	kubesharkEntry := extension.Dissector.Analyze(item, resolvedSource, resolvedDestination, namespace)
+	if kubesharkEntry.Capture == har.Capture {
+		har.SetImportedPeerNames(kubesharkEntry)
+	}
 
-		serviceMapGenerator := dependency.GetInstance(dependency.ServiceMapGeneratorDependency).(servicemap.ServiceMapSink)
-		serviceMapGenerator.NewTCPEntry(kubesharkEntry.Source, kubesharkEntry.Destination, &item.Protocol)
+	data, err := json.Marshal(kubesharkEntry)
+	if err != nil {
+		log.Error().Err(err).Msg("While marshaling entry!")
+		return
+	}
 
-		oasGenerator := dependency.GetInstance(dependency.OasGeneratorDependency).(oas.OasGeneratorSink)
-		oasGenerator.HandleEntry(kubesharkEntry)
+	entryInserter := dependency.GetInstance(dependency.EntriesInserter).(EntryInserter)
+	if err := entryInserter.Insert(kubesharkEntry); err != nil {
+		log.Error().Err(err).Msg("While inserting entry!")
 	}
+
+	summary := extension.Dissector.Summarize(kubesharkEntry)
+	providers.EntryAdded(len(data), kubesharkEntry, summary, kubesharkEntry.Namespace, peers)
+
+	topTracker := dependency.GetInstance(dependency.TopTrackerDependency).(*top.Tracker)
+	topTracker.Add(top.NewSample(kubesharkEntry, summary))
+
+	suggestionsCatalog := dependency.GetInstance(dependency.QuerySuggestionsDependency).(*suggestions.Catalog)
+	suggestionsCatalog.Add(kubesharkEntry, summary)
+
+	tracesIndex := dependency.GetInstance(dependency.TracesIndexDependency).(*traces.Index)
+	tracesIndex.Add(kubesharkEntry, summary)
+
+	otlpExporter := dependency.GetInstance(dependency.OtlpExporterDependency).(*otlp.Exporter)
+	otlpExporter.Export(kubesharkEntry, summary, peers)
+
+	serviceMapGenerator := dependency.GetInstance(dependency.ServiceMapGeneratorDependency).(servicemap.ServiceMapSink)
+	serviceMapGenerator.NewTCPEntry(kubesharkEntry.Source, kubesharkEntry.Destination, &item.Protocol)
+
+	oasGenerator := dependency.GetInstance(dependency.OasGeneratorDependency).(oas.OasGeneratorSink)
+	oasGenerator.HandleEntry(kubesharkEntry)
 }
 
-func resolveIP(connectionInfo *baseApi.ConnectionInfo) (resolvedSource string, resolvedDestination string, namespace string) {
+func resolveIP(connectionInfo *baseApi.ConnectionInfo) (resolvedSource string, resolvedDestination string, peers providers.PeerNamespaces) {
 	if k8sResolver != nil {
 		unresolvedSource := connectionInfo.ClientIP
 		resolvedSourceObject := k8sResolver.Resolve(unresolvedSource)
@@ -134,7 +169,7 @@ func resolveIP(connectionInfo *baseApi.ConnectionInfo) (resolvedSource string, r
 			}
 		} else {
 			resolvedSource = resolvedSourceObject.FullAddress
-			namespace = resolvedSourceObject.Namespace
+			peers.Source = resolvedSourceObject.Namespace
 		}
 
 		unresolvedDestination := fmt.Sprintf("%s:%s", connectionInfo.ServerIP, connectionInfo.ServerPort)
@@ -146,14 +181,10 @@ func resolveIP(connectionInfo *baseApi.ConnectionInfo) (resolvedSource string, r
 			}
 		} else {
 			resolvedDestination = resolvedDestinationObject.FullAddress
-			// Overwrite namespace (if it was set according to the source)
-			// Only overwrite if non-empty
-			if resolvedDestinationObject.Namespace != "" {
-				namespace = resolvedDestinationObject.Namespace
-			}
+			peers.Destination = resolvedDestinationObject.Namespace
 		}
 	}
-	return resolvedSource, resolvedDestination, namespace
+	return resolvedSource, resolvedDestination, peers
 }
 
 func CheckIsServiceIP(address string) bool {
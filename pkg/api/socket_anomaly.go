@@ -0,0 +1,53 @@
+package api
+
+import (
+	"time"
+
+	"github.com/kubeshark/base/pkg/models"
+	"github.com/kubeshark/hub/pkg/anomaly"
+	"github.com/kubeshark/hub/pkg/dependency"
+	"github.com/kubeshark/hub/pkg/providers"
+	"github.com/rs/zerolog/log"
+)
+
+const anomalyToastAutoClose = 10000
+
+// StartAnomalyDetection observes the minute buckets of the traffic stats as they complete
+// and sends the raised anomaly events to the browser sockets as toasts.
+func StartAnomalyDetection() {
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			detector := dependency.GetInstance(dependency.AnomalyDetectorDependency).(*anomaly.Detector)
+			events := detector.ObserveUntil(time.Now(), func(minute time.Time) map[anomaly.SeriesKey]anomaly.Sample {
+				return anomaly.SamplesFromBucket(providers.GetMinuteBucket(minute))
+			})
+
+			for _, event := range events {
+				log.Info().Interface("anomaly", event).Msg("Traffic anomaly:")
+				broadcastAnomalyToast(event)
+			}
+		}
+	}()
+}
+
+func broadcastAnomalyToast(event *anomaly.Event) {
+	toastType := "warning"
+	if event.Type == anomaly.EventErrorRate || event.Type == anomaly.EventDrop {
+		toastType = "error"
+	}
+
+	toastBytes, err := models.CreateWebsocketToastMessage(&models.ToastMessage{
+		Type:      toastType,
+		AutoClose: anomalyToastAutoClose,
+		Text:      event.Message,
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("Couldn't marshal message:")
+		return
+	}
+
+	BroadcastToBrowserClients(toastBytes)
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/kubeshark/base/pkg/api"
@@ -17,7 +18,8 @@ type EntryInserter interface {
 }
 
 type BasenineEntryInserter struct {
-	connection *basenine.Connection
+	connection        *basenine.Connection
+	insertErrorsCount uint64
 }
 
 var instance *BasenineEntryInserter
@@ -38,12 +40,14 @@ func (e *BasenineEntryInserter) Insert(entry *api.Entry) error {
 
 	data, err := json.Marshal(entry)
 	if err != nil {
+		atomic.AddUint64(&e.insertErrorsCount, 1)
 		return fmt.Errorf("error marshling entry, err: %v", err)
 	}
 
 	if err := e.connection.SendText(string(data)); err != nil {
 		e.connection.Close()
 		e.connection = nil
+		atomic.AddUint64(&e.insertErrorsCount, 1)
 
 		return fmt.Errorf("error sending text to database, err: %v", err)
 	}
@@ -51,6 +55,11 @@ func (e *BasenineEntryInserter) Insert(entry *api.Entry) error {
 	return nil
 }
 
+// GetInsertErrorsCount returns the number of the entries that failed to be inserted into the database.
+func (e *BasenineEntryInserter) GetInsertErrorsCount() uint64 {
+	return atomic.LoadUint64(&e.insertErrorsCount)
+}
+
 func initializeConnection() *basenine.Connection {
 	for {
 		connection, err := basenine.NewConnection(db.BasenineHost, db.BaseninePort)
@@ -3,6 +3,7 @@ package api
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"time"
 
 	baseApi "github.com/kubeshark/base/pkg/api"
@@ -14,15 +15,21 @@ import (
 
 type EntryStreamer interface {
 	Get(ctx context.Context, socketId int, params *WebSocketParams) error
+	Stream(ctx context.Context, socketId int, params *WebSocketParams, entryStreamerSocketConnector EntryStreamerSocketConnector) error
 }
 
 type BasenineEntryStreamer struct{}
 
 func (e *BasenineEntryStreamer) Get(ctx context.Context, socketId int, params *WebSocketParams) error {
-	var connection *basenine.Connection
-
 	entryStreamerSocketConnector := dependency.GetInstance(dependency.EntryStreamerSocketConnector).(EntryStreamerSocketConnector)
 
+	return e.Stream(ctx, socketId, params, entryStreamerSocketConnector)
+}
+
+// Stream sends the entries matching the query, and the metadata of the query, to a connector until the context is done.
+func (e *BasenineEntryStreamer) Stream(ctx context.Context, socketId int, params *WebSocketParams, entryStreamerSocketConnector EntryStreamerSocketConnector) error {
+	var connection *basenine.Connection
+
 	connection, err := basenine.NewConnection(db.BasenineHost, db.BaseninePort)
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to establish a connection to Basenine:")
@@ -35,7 +42,7 @@ func (e *BasenineEntryStreamer) Get(ctx context.Context, socketId int, params *W
 
 	query := params.Query
 	if err = basenine.Validate(db.BasenineHost, db.BaseninePort, query); err != nil {
-		if err := entryStreamerSocketConnector.SendToastError(socketId, err); err != nil {
+		if err := entryStreamerSocketConnector.SendToastError(socketId, fmt.Errorf("Syntax error: %v", err)); err != nil {
 			return err
 		}
 
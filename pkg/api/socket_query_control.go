@@ -0,0 +1,312 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	baseApi "github.com/kubeshark/base/pkg/api"
+	"github.com/kubeshark/base/pkg/models"
+	"github.com/kubeshark/hub/pkg/db"
+	"github.com/kubeshark/hub/pkg/dependency"
+	"github.com/rs/zerolog/log"
+	basenine "github.com/up9inc/basenine/client/go"
+)
+
+const (
+	WebSocketMessageTypeQueryReplace  models.WebSocketMessageType = "queryReplace"
+	WebSocketMessageTypeQueryPause    models.WebSocketMessageType = "queryPause"
+	WebSocketMessageTypeQueryResume   models.WebSocketMessageType = "queryResume"
+	WebSocketMessageTypeQueryFetch    models.WebSocketMessageType = "queryFetch"
+	WebSocketMessageTypeQueryResponse models.WebSocketMessageType = "queryResponse"
+)
+
+const (
+	queryFetchDefaultTimeoutMs = 3000
+	queryFetchMaxTimeoutMs     = 60000
+)
+
+// WebSocketQueryControlMessage controls the entry stream of a browser socket without reconnecting it.
+// A replace carries the parameters of the new stream, a fetch the leftOff, fetch and timeoutMs of the older entries.
+type WebSocketQueryControlMessage struct {
+	*models.WebSocketMessageMetadata
+	RequestId string `json:"requestId"`
+	WebSocketParams
+}
+
+// WebSocketQueryResponseMessage answers a control message, matched to it by the request ID.
+type WebSocketQueryResponseMessage struct {
+	*models.WebSocketMessageMetadata
+	RequestId string                      `json:"requestId"`
+	Request   models.WebSocketMessageType `json:"request"`
+	Error     string                      `json:"error,omitempty"`
+	Data      *QueryFetchResult           `json:"data,omitempty"`
+}
+
+// QueryFetchResult holds the older entries of a fetch, newest first, and the metadata to fetch the next ones with.
+type QueryFetchResult struct {
+	Entries []interface{}      `json:"entries"`
+	Meta    *basenine.Metadata `json:"meta"`
+}
+
+func CreateQueryResponseMessage(request *WebSocketQueryControlMessage, err error, data *QueryFetchResult) ([]byte, error) {
+	message := &WebSocketQueryResponseMessage{
+		WebSocketMessageMetadata: &models.WebSocketMessageMetadata{
+			MessageType: WebSocketMessageTypeQueryResponse,
+		},
+		RequestId: request.RequestId,
+		Request:   request.MessageType,
+		Data:      data,
+	}
+	if err != nil {
+		message.Error = err.Error()
+	}
+	return json.Marshal(message)
+}
+
+// queryStreamConnector keeps the position of the entry stream of a browser socket, so a paused stream is resumed
+// where it stopped. The entries that arrive after the stream is cancelled are dropped, they belong to the old query.
+type queryStreamConnector struct {
+	EntryStreamerSocketConnector
+	ctx         context.Context
+	skipId      string
+	lock        sync.Mutex
+	lastEntryId string
+	lastLeftOff string
+}
+
+func (e *queryStreamConnector) SendEntry(socketId int, entry *baseApi.Entry, params *WebSocketParams) error {
+	if e.ctx.Err() != nil || (e.skipId != "" && entry.Id == e.skipId) {
+		return nil
+	}
+
+	e.lock.Lock()
+	e.lastEntryId = entry.Id
+	e.lock.Unlock()
+
+	return e.EntryStreamerSocketConnector.SendEntry(socketId, entry, params)
+}
+
+func (e *queryStreamConnector) SendMetadata(socketId int, metadata *basenine.Metadata) error {
+	if e.ctx.Err() != nil {
+		return nil
+	}
+
+	e.lock.Lock()
+	e.lastLeftOff = metadata.LeftOff
+	e.lock.Unlock()
+
+	return e.EntryStreamerSocketConnector.SendMetadata(socketId, metadata)
+}
+
+// getPosition returns the ID of the last sent entry, or the leftOff of the last metadata if there is none.
+func (e *queryStreamConnector) getPosition() string {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	if e.lastEntryId != "" {
+		return e.lastEntryId
+	}
+	return e.lastLeftOff
+}
+
+func (e *queryStreamConnector) getLastEntryId() string {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	return e.lastEntryId
+}
+
+func handleQueryControl(socketId int, messageType models.WebSocketMessageType, message []byte) {
+	var request WebSocketQueryControlMessage
+	if err := json.Unmarshal(message, &request); err != nil {
+		log.Error().Err(err).Int("socket-id", socketId).Msg("Couldn't unmarshal the query control message:")
+		return
+	}
+
+	var err error
+	var data *QueryFetchResult
+	switch messageType {
+	case WebSocketMessageTypeQueryReplace:
+		err = replaceQueryStream(socketId, &request.WebSocketParams)
+	case WebSocketMessageTypeQueryPause:
+		err = pauseQueryStream(socketId)
+	case WebSocketMessageTypeQueryResume:
+		err = resumeQueryStream(socketId)
+	case WebSocketMessageTypeQueryFetch:
+		data, err = fetchOlderEntries(socketId, &request.WebSocketParams)
+	}
+
+	responseBytes, marshalErr := CreateQueryResponseMessage(&request, err, data)
+	if marshalErr != nil {
+		log.Error().Err(marshalErr).Msg("Couldn't marshal message:")
+		return
+	}
+
+	if err := SendToSocket(socketId, responseBytes); err != nil {
+		log.Error().Err(err).Int("socket-id", socketId).Send()
+	}
+}
+
+// startQueryStream cancels the entry stream of a browser socket, if any, and starts a new one with the parameters.
+func startQueryStream(socketId int, params *WebSocketParams, skipId string) error {
+	cancelQueryStream(socketId)
+
+	ctx, cancelFunc := context.WithCancel(context.Background())
+	connector := &queryStreamConnector{
+		EntryStreamerSocketConnector: dependency.GetInstance(dependency.EntryStreamerSocketConnector).(EntryStreamerSocketConnector),
+		ctx:                          ctx,
+		skipId:                       skipId,
+	}
+
+	socketListLock.Lock()
+	client := browserClients[socketId]
+	if client == nil {
+		socketListLock.Unlock()
+		cancelFunc()
+		return fmt.Errorf("socket %v is disconnected", socketId)
+	}
+	client.dataStreamParams = params
+	client.dataStreamConnector = connector
+	client.dataStreamCancelFunc = cancelFunc
+	socketListLock.Unlock()
+
+	entriesStreamer := dependency.GetInstance(dependency.EntriesSocketStreamer).(EntryStreamer)
+	if err := entriesStreamer.Stream(ctx, socketId, params, connector); err != nil {
+		cancelFunc()
+
+		socketListLock.Lock()
+		if client := browserClients[socketId]; client != nil && client.dataStreamConnector == connector {
+			client.dataStreamCancelFunc = nil
+			client.dataStreamParams = nil
+			client.dataStreamConnector = nil
+		}
+		socketListLock.Unlock()
+		return err
+	}
+
+	return nil
+}
+
+// cancelQueryStream cancels the entry stream of a browser socket and returns whether there was one.
+func cancelQueryStream(socketId int) bool {
+	socketListLock.Lock()
+	defer socketListLock.Unlock()
+
+	client := browserClients[socketId]
+	if client == nil || client.dataStreamCancelFunc == nil {
+		return false
+	}
+
+	client.dataStreamCancelFunc()
+	client.dataStreamCancelFunc = nil
+	return true
+}
+
+func getQueryStream(socketId int) (*WebSocketParams, *queryStreamConnector) {
+	socketListLock.Lock()
+	defer socketListLock.Unlock()
+
+	client := browserClients[socketId]
+	if client == nil {
+		return nil, nil
+	}
+	return client.dataStreamParams, client.dataStreamConnector
+}
+
+func replaceQueryStream(socketId int, params *WebSocketParams) error {
+	// an invalid query keeps the current stream, rather than closing the socket as the streamer does
+	if err := basenine.Validate(db.BasenineHost, db.BaseninePort, params.Query); err != nil {
+		return fmt.Errorf("invalid query: %v", err)
+	}
+
+	return startQueryStream(socketId, params, "")
+}
+
+func pauseQueryStream(socketId int) error {
+	if !cancelQueryStream(socketId) {
+		return fmt.Errorf("no stream to pause")
+	}
+	return nil
+}
+
+// resumeQueryStream restarts a paused stream right after the last entry it has sent.
+func resumeQueryStream(socketId int) error {
+	params, connector := getQueryStream(socketId)
+	if params == nil {
+		return fmt.Errorf("no stream to resume")
+	}
+
+	socketListLock.Lock()
+	running := browserClients[socketId] != nil && browserClients[socketId].dataStreamCancelFunc != nil
+	socketListLock.Unlock()
+	if running {
+		return fmt.Errorf("the stream is not paused")
+	}
+
+	resumedParams := *params
+	resumedParams.Fetch = 0
+	position := connector.getPosition()
+	if position != "" {
+		resumedParams.LeftOff = position
+	}
+
+	return startQueryStream(socketId, &resumedParams, connector.getLastEntryId())
+}
+
+// getQueryFetchTimeout defaults a missing timeout of a fetch, as GetEntries does, and caps it.
+func getQueryFetchTimeout(timeoutMs int) time.Duration {
+	if timeoutMs <= 0 {
+		timeoutMs = queryFetchDefaultTimeoutMs
+	} else if timeoutMs > queryFetchMaxTimeoutMs {
+		timeoutMs = queryFetchMaxTimeoutMs
+	}
+
+	return time.Duration(timeoutMs) * time.Millisecond
+}
+
+// fetchOlderEntries fetches the entries of the current query that precede the leftOff of the request.
+func fetchOlderEntries(socketId int, request *WebSocketParams) (*QueryFetchResult, error) {
+	params, _ := getQueryStream(socketId)
+	if params == nil {
+		return nil, fmt.Errorf("no stream to fetch the entries of")
+	}
+	if request.Fetch <= 0 {
+		return nil, fmt.Errorf("invalid fetch: %d", request.Fetch)
+	}
+
+	data, _, lastMeta, err := basenine.Fetch(db.BasenineHost, db.BaseninePort,
+		request.LeftOff, -1, params.Query, request.Fetch, getQueryFetchTimeout(request.TimeoutMs))
+	if err != nil {
+		return nil, err
+	}
+
+	result := &QueryFetchResult{Entries: make([]interface{}, 0, len(data))}
+	if err := json.Unmarshal(lastMeta, &result.Meta); err != nil {
+		return nil, fmt.Errorf("error unmarshalling metadata, err: %v", err)
+	}
+
+	for _, row := range data {
+		var entry *baseApi.Entry
+		if err := json.Unmarshal(row, &entry); err != nil {
+			log.Debug().Err(err).Msg("Unmarshalling entry:")
+			continue
+		}
+
+		if params.EnableFullEntries {
+			result.Entries = append(result.Entries, entry)
+			continue
+		}
+
+		base, err := summarizeEntry(entry)
+		if err != nil {
+			log.Debug().Err(err).Msg("Summarizing entry:")
+			continue
+		}
+		result.Entries = append(result.Entries, base)
+	}
+
+	return result, nil
+}
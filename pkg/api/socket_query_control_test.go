@@ -0,0 +1,233 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	baseApi "github.com/kubeshark/base/pkg/api"
+	"github.com/kubeshark/hub/pkg/dependency"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	basenine "github.com/up9inc/basenine/client/go"
+)
+
+const testSocketId = 1
+
+// fakeStream is a stream started by the fake streamer, the test sends its entries through its connector.
+type fakeStream struct {
+	ctx       context.Context
+	params    WebSocketParams
+	connector EntryStreamerSocketConnector
+}
+
+type fakeEntryStreamer struct {
+	lock    sync.Mutex
+	streams []*fakeStream
+}
+
+func (s *fakeEntryStreamer) Get(ctx context.Context, socketId int, params *WebSocketParams) error {
+	return nil
+}
+
+func (s *fakeEntryStreamer) Stream(ctx context.Context, socketId int, params *WebSocketParams, connector EntryStreamerSocketConnector) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.streams = append(s.streams, &fakeStream{ctx: ctx, params: *params, connector: connector})
+	return nil
+}
+
+func (s *fakeEntryStreamer) getStreams() []*fakeStream {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.streams
+}
+
+// fakeSocketConnector records the ids of the entries sent to the socket.
+type fakeSocketConnector struct {
+	lock     sync.Mutex
+	entryIds []string
+}
+
+func (c *fakeSocketConnector) SendEntry(socketId int, entry *baseApi.Entry, params *WebSocketParams) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.entryIds = append(c.entryIds, entry.Id)
+	return nil
+}
+
+func (c *fakeSocketConnector) SendMetadata(socketId int, metadata *basenine.Metadata) error {
+	return nil
+}
+
+func (c *fakeSocketConnector) SendToastError(socketId int, err error) error {
+	return nil
+}
+
+func (c *fakeSocketConnector) CleanupSocket(socketId int) {}
+
+func (c *fakeSocketConnector) getEntryIds() []string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.entryIds
+}
+
+// useFakeStreamer connects a browser socket whose streams are started by a fake streamer.
+func useFakeStreamer(t *testing.T) (*fakeEntryStreamer, *fakeSocketConnector) {
+	streamer := &fakeEntryStreamer{}
+	connector := &fakeSocketConnector{}
+	dependency.RegisterGenerator(dependency.EntriesSocketStreamer, func() interface{} { return streamer })
+	dependency.RegisterGenerator(dependency.EntryStreamerSocketConnector, func() interface{} { return connector })
+
+	socketListLock.Lock()
+	browserClients[testSocketId] = &BrowserClient{}
+	socketListLock.Unlock()
+
+	t.Cleanup(func() {
+		cancelQueryStream(testSocketId)
+
+		socketListLock.Lock()
+		delete(browserClients, testSocketId)
+		socketListLock.Unlock()
+	})
+
+	return streamer, connector
+}
+
+func TestReplaceCancelsPreviousStream(t *testing.T) {
+	streamer, connector := useFakeStreamer(t)
+
+	require.NoError(t, startQueryStream(testSocketId, &WebSocketParams{Query: "http"}, ""))
+	require.NoError(t, startQueryStream(testSocketId, &WebSocketParams{Query: "redis"}, ""))
+
+	streams := streamer.getStreams()
+	require.Len(t, streams, 2)
+	assert.ErrorIs(t, streams[0].ctx.Err(), context.Canceled)
+	assert.NoError(t, streams[1].ctx.Err())
+	assert.Equal(t, "redis", streams[1].params.Query)
+
+	// the entries of the cancelled stream belong to the old query
+	require.NoError(t, streams[0].connector.SendEntry(testSocketId, &baseApi.Entry{Id: "1"}, &streams[0].params))
+	require.NoError(t, streams[1].connector.SendEntry(testSocketId, &baseApi.Entry{Id: "2"}, &streams[1].params))
+	assert.Equal(t, []string{"2"}, connector.getEntryIds())
+
+	params, _ := getQueryStream(testSocketId)
+	assert.Equal(t, "redis", params.Query)
+}
+
+func TestPauseAndResume(t *testing.T) {
+	streamer, connector := useFakeStreamer(t)
+
+	assert.EqualError(t, resumeQueryStream(testSocketId), "no stream to resume")
+	require.NoError(t, startQueryStream(testSocketId, &WebSocketParams{Query: "http", Fetch: 50}, ""))
+	assert.EqualError(t, resumeQueryStream(testSocketId), "the stream is not paused")
+
+	first := streamer.getStreams()[0]
+	require.NoError(t, first.connector.SendMetadata(testSocketId, &basenine.Metadata{LeftOff: "10"}))
+	require.NoError(t, first.connector.SendEntry(testSocketId, &baseApi.Entry{Id: "11"}, &first.params))
+
+	require.NoError(t, pauseQueryStream(testSocketId))
+	assert.EqualError(t, pauseQueryStream(testSocketId), "no stream to pause")
+	assert.ErrorIs(t, first.ctx.Err(), context.Canceled)
+
+	// an entry sent while paused is dropped, the resumed stream sends it again
+	require.NoError(t, first.connector.SendEntry(testSocketId, &baseApi.Entry{Id: "12"}, &first.params))
+
+	require.NoError(t, resumeQueryStream(testSocketId))
+	streams := streamer.getStreams()
+	require.Len(t, streams, 2)
+	resumed := streams[1]
+	assert.Equal(t, WebSocketParams{Query: "http", LeftOff: "11"}, resumed.params)
+
+	// the stream resumes from the last sent entry, which is skipped
+	for _, id := range []string{"11", "12", "13"} {
+		require.NoError(t, resumed.connector.SendEntry(testSocketId, &baseApi.Entry{Id: id}, &resumed.params))
+	}
+	assert.Equal(t, []string{"11", "12", "13"}, connector.getEntryIds())
+}
+
+func TestResumeWithoutEntriesContinuesFromLeftOff(t *testing.T) {
+	streamer, _ := useFakeStreamer(t)
+
+	require.NoError(t, startQueryStream(testSocketId, &WebSocketParams{Query: "http", LeftOff: "latest"}, ""))
+	first := streamer.getStreams()[0]
+	require.NoError(t, first.connector.SendMetadata(testSocketId, &basenine.Metadata{LeftOff: "10"}))
+
+	require.NoError(t, pauseQueryStream(testSocketId))
+	require.NoError(t, resumeQueryStream(testSocketId))
+
+	resumed := streamer.getStreams()[1]
+	assert.Equal(t, "10", resumed.params.LeftOff)
+}
+
+func TestQueryControlResponse(t *testing.T) {
+	useFakeStreamer(t)
+
+	connections := make(chan *websocket.Conn, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		connection, err := websocketUpgrader.Upgrade(w, r, nil)
+		require.NoError(t, err)
+		connections <- connection
+	}))
+	defer server.Close()
+
+	client, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	require.NoError(t, err)
+	defer client.Close()
+
+	websocketIdsLock.Lock()
+	connectedWebsockets[testSocketId] = &SocketConnection{connection: <-connections, lock: &sync.Mutex{}}
+	websocketIdsLock.Unlock()
+	defer func() {
+		websocketIdsLock.Lock()
+		delete(connectedWebsockets, testSocketId)
+		websocketIdsLock.Unlock()
+	}()
+
+	// in order, the pause succeeds once there is a stream
+	tests := []struct {
+		name    string
+		message string
+		error   string
+	}{
+		{name: "failed", message: `{"messageType":"queryPause","requestId":"1"}`, error: "no stream to pause"},
+		{name: "succeeded", message: `{"messageType":"queryPause","requestId":"2"}`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.error == "" {
+				require.NoError(t, startQueryStream(testSocketId, &WebSocketParams{Query: "http"}, ""))
+			}
+
+			var request WebSocketQueryControlMessage
+			require.NoError(t, json.Unmarshal([]byte(test.message), &request))
+			handleQueryControl(testSocketId, WebSocketMessageTypeQueryPause, []byte(test.message))
+
+			require.NoError(t, client.SetReadDeadline(time.Now().Add(time.Second)))
+			var response WebSocketQueryResponseMessage
+			require.NoError(t, client.ReadJSON(&response))
+			assert.Equal(t, WebSocketMessageTypeQueryResponse, response.MessageType)
+			assert.Equal(t, request.RequestId, response.RequestId)
+			assert.Equal(t, WebSocketMessageTypeQueryPause, response.Request)
+			assert.Equal(t, test.error, response.Error)
+		})
+	}
+}
+
+func TestGetQueryFetchTimeout(t *testing.T) {
+	assert.Equal(t, 3*time.Second, getQueryFetchTimeout(0))
+	assert.Equal(t, 3*time.Second, getQueryFetchTimeout(-1))
+	assert.Equal(t, 500*time.Millisecond, getQueryFetchTimeout(500))
+	assert.Equal(t, time.Minute, getQueryFetchTimeout(600000))
+}
@@ -8,7 +8,6 @@ import (
 	"github.com/gin-gonic/gin"
 	baseApi "github.com/kubeshark/base/pkg/api"
 	"github.com/kubeshark/base/pkg/models"
-	"github.com/kubeshark/hub/pkg/dependency"
 	"github.com/kubeshark/hub/pkg/providers/targettedPods"
 	"github.com/kubeshark/hub/pkg/providers/workers"
 	"github.com/rs/zerolog/log"
@@ -16,6 +15,9 @@ import (
 
 type BrowserClient struct {
 	dataStreamCancelFunc context.CancelFunc
+	dataStreamParams     *WebSocketParams
+	dataStreamConnector  *queryStreamConnector
+	serviceMapSubscribed bool
 }
 
 var browserClients = make(map[int]*BrowserClient, 0)
@@ -68,6 +70,13 @@ func (h *RoutesEventHandlers) WebSocketDisconnect(socketId int, isWorker bool) {
 	}
 }
 
+func GetConnectedBrowsersCount() int {
+	socketListLock.Lock()
+	defer socketListLock.Unlock()
+
+	return len(browserClients)
+}
+
 func BroadcastToBrowserClients(message []byte) {
 	for socketId := range browserClients {
 		go func(socketId int) {
@@ -92,23 +101,32 @@ func (h *RoutesEventHandlers) WebSocketMessage(socketId int, isWorker bool, mess
 	if isWorker {
 		HandleWorkerIncomingMessage(message, h.SocketOutChannel, BroadcastToBrowserClients)
 	} else {
-		// we initiate the basenine stream after the first websocket message we receive (it contains the entry query), we then store a cancelfunc to later cancel this stream
-		if browserClients[socketId] != nil && browserClients[socketId].dataStreamCancelFunc == nil {
+		var socketMessageBase models.WebSocketMessageMetadata
+		if err := json.Unmarshal(message, &socketMessageBase); err == nil {
+			switch socketMessageBase.MessageType {
+			case WebSocketMessageTypeServiceMapSubscribe:
+				handleServiceMapSubscribe(socketId, message)
+				return
+			case WebSocketMessageTypeServiceMapUnsubscribe:
+				setServiceMapSubscribed(socketId, false)
+				return
+			case WebSocketMessageTypeQueryReplace, WebSocketMessageTypeQueryPause, WebSocketMessageTypeQueryResume, WebSocketMessageTypeQueryFetch:
+				handleQueryControl(socketId, socketMessageBase.MessageType, message)
+				return
+			}
+		}
+
+		// we initiate the basenine stream after the first websocket message we receive (it contains the entry query),
+		// the later changes of the stream come as query control messages
+		if params, _ := getQueryStream(socketId); params == nil {
 			var params WebSocketParams
 			if err := json.Unmarshal(message, &params); err != nil {
 				log.Error().Err(err).Int("socket-id", socketId).Send()
 				return
 			}
 
-			entriesStreamer := dependency.GetInstance(dependency.EntriesSocketStreamer).(EntryStreamer)
-			ctx, cancelFunc := context.WithCancel(context.Background())
-			err := entriesStreamer.Get(ctx, socketId, &params)
-
-			if err != nil {
+			if err := startQueryStream(socketId, &params, ""); err != nil {
 				log.Error().Err(err).Int("socket-id", socketId).Msg("While initializing a Basenine stream for the browser socket!")
-				cancelFunc()
-			} else {
-				browserClients[socketId].dataStreamCancelFunc = cancelFunc
 			}
 		}
 	}
@@ -0,0 +1,160 @@
+package api
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/kubeshark/base/pkg/models"
+	"github.com/kubeshark/hub/pkg/dependency"
+	"github.com/kubeshark/hub/pkg/servicemap"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	WebSocketMessageTypeServiceMapSubscribe   models.WebSocketMessageType = "serviceMapSubscribe"
+	WebSocketMessageTypeServiceMapUnsubscribe models.WebSocketMessageType = "serviceMapUnsubscribe"
+	WebSocketMessageTypeServiceMapSnapshot    models.WebSocketMessageType = "serviceMapSnapshot"
+	WebSocketMessageTypeServiceMapDelta       models.WebSocketMessageType = "serviceMapDelta"
+)
+
+// WebSocketServiceMapSubscribeMessage subscribes a browser socket to the service map updates.
+// Sequence is the last delta the client has applied before a reconnect, zero asks for a snapshot.
+type WebSocketServiceMapSubscribeMessage struct {
+	*models.WebSocketMessageMetadata
+	Sequence uint64 `json:"sequence"`
+}
+
+type WebSocketServiceMapSnapshotMessage struct {
+	*models.WebSocketMessageMetadata
+	Data *servicemap.ServiceMapSnapshot `json:"data"`
+}
+
+type WebSocketServiceMapDeltaMessage struct {
+	*models.WebSocketMessageMetadata
+	Data *servicemap.ServiceMapDelta `json:"data"`
+}
+
+// serviceMapBroadcastLock keeps the snapshot of a new subscriber and the broadcasted deltas in order.
+var serviceMapBroadcastLock = sync.Mutex{}
+
+func CreateServiceMapSnapshotMessage(snapshot *servicemap.ServiceMapSnapshot) ([]byte, error) {
+	message := &WebSocketServiceMapSnapshotMessage{
+		WebSocketMessageMetadata: &models.WebSocketMessageMetadata{
+			MessageType: WebSocketMessageTypeServiceMapSnapshot,
+		},
+		Data: snapshot,
+	}
+	return json.Marshal(message)
+}
+
+func CreateServiceMapDeltaMessage(delta *servicemap.ServiceMapDelta) ([]byte, error) {
+	message := &WebSocketServiceMapDeltaMessage{
+		WebSocketMessageMetadata: &models.WebSocketMessageMetadata{
+			MessageType: WebSocketMessageTypeServiceMapDelta,
+		},
+		Data: delta,
+	}
+	return json.Marshal(message)
+}
+
+// StartServiceMapBroadcaster flushes the service map changes as a delta every interval
+// and sends it to the subscribed browser sockets.
+func StartServiceMapBroadcaster(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			broadcastServiceMapDelta()
+		}
+	}()
+}
+
+func broadcastServiceMapDelta() {
+	serviceMapBroadcastLock.Lock()
+	defer serviceMapBroadcastLock.Unlock()
+
+	updates := dependency.GetInstance(dependency.ServiceMapGeneratorDependency).(servicemap.ServiceMapUpdates)
+	delta := updates.FlushDelta()
+	if delta == nil {
+		return
+	}
+
+	message, err := CreateServiceMapDeltaMessage(delta)
+	if err != nil {
+		log.Error().Err(err).Msg("Couldn't marshal message:")
+		return
+	}
+
+	for _, socketId := range getServiceMapSubscribers() {
+		if err := SendToSocket(socketId, message); err != nil {
+			log.Error().Err(err).Int("socket-id", socketId).Send()
+		}
+	}
+}
+
+func getServiceMapSubscribers() []int {
+	socketListLock.Lock()
+	defer socketListLock.Unlock()
+
+	subscribers := make([]int, 0)
+	for socketId, client := range browserClients {
+		if client.serviceMapSubscribed {
+			subscribers = append(subscribers, socketId)
+		}
+	}
+	return subscribers
+}
+
+func setServiceMapSubscribed(socketId int, subscribed bool) {
+	socketListLock.Lock()
+	defer socketListLock.Unlock()
+
+	if client := browserClients[socketId]; client != nil {
+		client.serviceMapSubscribed = subscribed
+	}
+}
+
+func handleServiceMapSubscribe(socketId int, message []byte) {
+	var subscribeMessage WebSocketServiceMapSubscribeMessage
+	if err := json.Unmarshal(message, &subscribeMessage); err != nil {
+		log.Error().Err(err).Int("socket-id", socketId).Msg("Couldn't unmarshal the service map subscription:")
+		return
+	}
+
+	serviceMapBroadcastLock.Lock()
+	defer serviceMapBroadcastLock.Unlock()
+
+	updates := dependency.GetInstance(dependency.ServiceMapGeneratorDependency).(servicemap.ServiceMapUpdates)
+
+	if subscribeMessage.Sequence > 0 {
+		if deltas, ok := updates.GetDeltasSince(subscribeMessage.Sequence); ok {
+			for _, delta := range deltas {
+				deltaBytes, _ := CreateServiceMapDeltaMessage(delta)
+				if err := SendToSocket(socketId, deltaBytes); err != nil {
+					log.Error().Err(err).Int("socket-id", socketId).Send()
+					return
+				}
+			}
+
+			setServiceMapSubscribed(socketId, true)
+			return
+		}
+
+		log.Debug().Int("socket-id", socketId).Uint64("sequence", subscribeMessage.Sequence).Msg("Service map deltas are not kept anymore, sending a snapshot.")
+	}
+
+	snapshotBytes, err := CreateServiceMapSnapshotMessage(updates.GetSnapshot())
+	if err != nil {
+		log.Error().Err(err).Msg("Couldn't marshal message:")
+		return
+	}
+
+	if err := SendToSocket(socketId, snapshotBytes); err != nil {
+		log.Error().Err(err).Int("socket-id", socketId).Send()
+		return
+	}
+
+	setServiceMapSubscribed(socketId, true)
+}
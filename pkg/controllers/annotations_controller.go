@@ -0,0 +1,130 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kubeshark/base/pkg/models"
+	"github.com/kubeshark/hub/pkg/dependency"
+	"github.com/kubeshark/hub/pkg/entries"
+	"github.com/kubeshark/hub/pkg/providers/annotations"
+	"github.com/rs/zerolog/log"
+)
+
+func GetAnnotations(c *gin.Context) {
+	bookmarked, err := strconv.ParseBool(c.DefaultQuery("bookmarked", "false"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, annotations.GetAll(c.Query("tag"), bookmarked))
+}
+
+func GetAnnotation(c *gin.Context) {
+	annotation, err := annotations.Get(c.Param("id"))
+	if err != nil {
+		handleAnnotationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, annotation)
+}
+
+func PutAnnotation(c *gin.Context) {
+	var annotation annotations.Annotation
+	if err := c.ShouldBindJSON(&annotation); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, annotations.Set(c.Param("id"), &annotation))
+}
+
+func DeleteAnnotation(c *gin.Context) {
+	if err := annotations.Delete(c.Param("id")); err != nil {
+		handleAnnotationError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// PinEntry copies an entry out of Basenine, so it is kept after Basenine evicts it.
+func PinEntry(c *gin.Context) {
+	entriesProvider := dependency.GetInstance(dependency.EntriesProvider).(entries.EntriesProvider)
+	entry, err := entriesProvider.GetEntry(&models.SingleEntryRequest{}, c.Param("id"))
+	if HandleEntriesError(c, err) {
+		return
+	}
+
+	annotation, err := annotations.Pin(entry.Data)
+	if err != nil {
+		handleAnnotationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, annotation)
+}
+
+func UnpinEntry(c *gin.Context) {
+	if err := annotations.Unpin(c.Param("id")); err != nil {
+		handleAnnotationError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// GetBookmarkedEntries returns the summaries of the bookmarked entries along with their annotations,
+// the ones of the entries that are neither in Basenine nor pinned have only the annotation.
+func GetBookmarkedEntries(c *gin.Context) {
+	entriesProvider := dependency.GetInstance(dependency.EntriesProvider).(entries.EntriesProvider)
+
+	bookmarkedEntries := make([]*annotations.AnnotatedBaseEntry, 0)
+	for _, annotation := range annotations.GetAll(c.Query("tag"), true) {
+		bookmarkedEntry := &annotations.AnnotatedBaseEntry{Annotation: annotation}
+
+		entry, err := entriesProvider.GetEntry(&models.SingleEntryRequest{}, annotation.EntryId)
+		if err != nil {
+			if pinnedEntry, pinnedErr := annotations.GetPinnedEntry(annotation.EntryId); pinnedErr == nil {
+				entry, err = entries.NewEntryWrapper(pinnedEntry)
+			}
+		}
+		if err == nil {
+			bookmarkedEntry.BaseEntry = entry.Base
+		} else {
+			log.Debug().Err(err).Str("id", annotation.EntryId).Msg("While getting bookmarked entry:")
+		}
+
+		bookmarkedEntries = append(bookmarkedEntries, bookmarkedEntry)
+	}
+
+	c.JSON(http.StatusOK, bookmarkedEntries)
+}
+
+func GetPinnedEntries(c *gin.Context) {
+	pinnedEntries := make([]*annotations.AnnotatedBaseEntry, 0)
+	for _, entry := range annotations.GetPinnedEntries() {
+		entryWrapper, err := entries.NewEntryWrapper(entry)
+		if err != nil {
+			log.Error().Err(err).Str("id", entry.Id).Msg("While summarizing pinned entry:")
+			continue
+		}
+
+		pinnedEntries = append(pinnedEntries, annotations.AnnotateBaseEntry(entryWrapper.Base))
+	}
+
+	c.JSON(http.StatusOK, pinnedEntries)
+}
+
+func handleAnnotationError(c *gin.Context, err error) {
+	if errors.Is(err, annotations.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
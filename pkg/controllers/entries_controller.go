@@ -1,16 +1,49 @@
 package controllers
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/gin-contrib/sse"
 	"github.com/gin-gonic/gin"
+	baseApi "github.com/kubeshark/base/pkg/api"
 	"github.com/kubeshark/base/pkg/models"
+	"github.com/kubeshark/hub/pkg/api"
+	"github.com/kubeshark/hub/pkg/db"
 	"github.com/kubeshark/hub/pkg/dependency"
+	"github.com/kubeshark/hub/pkg/diff"
 	"github.com/kubeshark/hub/pkg/entries"
+	"github.com/kubeshark/hub/pkg/export"
+	"github.com/kubeshark/hub/pkg/har"
+	"github.com/kubeshark/hub/pkg/providers/annotations"
 	"github.com/kubeshark/hub/pkg/validation"
+	"github.com/kubeshark/hub/pkg/version"
 	"github.com/rs/zerolog/log"
+	basenine "github.com/up9inc/basenine/client/go"
 )
 
+const (
+	ExportFormatHar = "har"
+
+	exportLeftOffTrailer = "LeftOff"
+	exportErrorTrailer   = "ExportError"
+
+	streamFetchTimeoutMs = 3000
+)
+
+var errExportLimitReached = errors.New("export limit reached")
+
+// streamHeartbeatInterval is how often an idle entry stream writes a comment, so the proxies keep it open.
+var streamHeartbeatInterval = 15 * time.Second
+
 func HandleEntriesError(c *gin.Context, err error) bool {
 	if err != nil {
 		log.Error().Err(err).Msg("Couldn't get the entry!")
@@ -37,6 +70,13 @@ func GetEntries(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, validationError)
 	}
 
+	query, err := getQueryWithSavedQuery(c, entriesRequest.Query)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	entriesRequest.Query = query
+
 	if entriesRequest.TimeoutMs == 0 {
 		entriesRequest.TimeoutMs = 3000
 	}
@@ -68,10 +108,375 @@ func GetEntry(c *gin.Context) {
 
 	id := c.Param("id")
 
+	entry, err := getEntryWrapper(singleEntryRequest, id)
+	if !HandleEntriesError(c, err) {
+		c.JSON(http.StatusOK, annotations.AnnotateEntryWrapper(entry, id))
+	}
+}
+
+func getEntryWrapper(singleEntryRequest *models.SingleEntryRequest, id string) (*baseApi.EntryWrapper, error) {
 	entriesProvider := dependency.GetInstance(dependency.EntriesProvider).(entries.EntriesProvider)
 	entry, err := entriesProvider.GetEntry(singleEntryRequest, id)
+	if err != nil {
+		// the pinned entries are kept after Basenine evicts them
+		if pinnedEntry, pinnedErr := annotations.GetPinnedEntry(id); pinnedErr == nil {
+			return entries.NewEntryWrapper(pinnedEntry)
+		}
+	}
 
-	if !HandleEntriesError(c, err) {
-		c.JSON(http.StatusOK, entry)
+	return entry, err
+}
+
+// DiffEntries compares the entries a and b, leaving out the headers of ignoreHeaders
+// and, if ignoreNoisyHeaders is set, the ones that differ whatever the request, such as the dates and request IDs.
+func DiffEntries(c *gin.Context) {
+	idA, idB := c.Query("a"), c.Query("b")
+	if idA == "" || idB == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "the entries to compare, a and b, are required"})
+		return
+	}
+
+	options := &diff.Options{IgnoredHeaders: getListQuery(c, "ignoreHeaders")}
+	ignoreNoisyHeaders, err := strconv.ParseBool(c.DefaultQuery("ignoreNoisyHeaders", "false"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid ignoreNoisyHeaders: %v", err)})
+		return
+	}
+	if ignoreNoisyHeaders {
+		options.IgnoredHeaders = append(options.IgnoredHeaders, diff.NoisyHeaders...)
+	}
+
+	entryA, err := getEntryWrapper(&models.SingleEntryRequest{}, idA)
+	if HandleEntriesError(c, err) {
+		return
+	}
+	entryB, err := getEntryWrapper(&models.SingleEntryRequest{}, idB)
+	if HandleEntriesError(c, err) {
+		return
+	}
+
+	entriesDiff, err := diff.Compare(entryA, entryB, options)
+	if HandleEntriesError(c, err) {
+		return
+	}
+
+	c.JSON(http.StatusOK, entriesDiff)
+}
+
+// ExportEntries streams the entries matching the query, newest first. The HTTP entries are exported as a HAR
+// document while the entries of the other protocols are skipped and counted in the comment of the document.
+// As the status is sent first, an export that fails midway is closed as truncated, with the error in its metadata.
+// The entries can also be exported as rows of a list of fields, see exportEntryRows.
+func ExportEntries(c *gin.Context) {
+	format := c.DefaultQuery("format", ExportFormatHar)
+
+	query, err := getScanQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if format != ExportFormatHar {
+		exportEntryRows(c, format, query)
+		return
+	}
+
+	c.Header("Content-Type", "application/json; charset=utf-8")
+	c.Header("Content-Disposition", "attachment; filename=kubeshark.har")
+	c.Status(http.StatusOK)
+
+	writer := har.NewWriter(c.Writer, har.Creator{Name: "kubeshark", Version: version.Ver})
+
+	entriesProvider := dependency.GetInstance(dependency.EntriesProvider).(entries.EntriesProvider)
+	err = entriesProvider.Scan(c.Request.Context(), query, entries.LatestLeftOff, func(entry *baseApi.Entry) error {
+		if entry.Protocol.Name != "http" {
+			writer.Skip(entry.Protocol.Abbreviation)
+			return nil
+		}
+
+		harEntry, err := har.NewEntry(entry.Request, entry.Response, entry.StartTime, entry.ElapsedTime)
+		if err != nil {
+			log.Debug().Err(err).Str("id", entry.Id).Msg("While exporting entry:")
+			writer.Skip(entry.Protocol.Abbreviation)
+			return nil
+		}
+
+		return writer.WriteEntry(&har.ExportedEntry{
+			Entry:     *harEntry,
+			Kubeshark: getEntryMetadata(entry),
+		})
+	}, nil)
+	if err != nil && !errors.Is(err, c.Request.Context().Err()) {
+		log.Error().Err(err).Msg("While exporting entries:")
+		writer.Fail(err)
+	}
+
+	if err := writer.Close(); err != nil {
+		log.Error().Err(err).Msg("While exporting entries:")
+	}
+}
+
+// exportEntryRows streams the fields of the entries matching the query as NDJSON, CSV or Parquet rows.
+// An export starts from the leftOff cursor, the latest entry by default, and when a limit is given it stops
+// at the end of the Basenine page that reaches it. The cursor to resume from is sent in the LeftOff trailer,
+// empty once there are no more entries. An export that fails midway is ended with the rows written so far
+// and the error in the ExportError trailer, its LeftOff trailer resumes from the last complete page.
+func exportEntryRows(c *gin.Context, format string, query string) {
+	fields := getListQuery(c, "fields")
+	if len(fields) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no fields to export"})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "0"))
+	if err != nil || limit < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid limit: %s", c.Query("limit"))})
+		return
+	}
+
+	writer, err := export.NewWriter(format, c.Writer)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Type", writer.ContentType())
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=kubeshark.%s", format))
+	c.Header("Trailer", fmt.Sprintf("%s, %s", exportLeftOffTrailer, exportErrorTrailer))
+	c.Status(http.StatusOK)
+
+	leftOff := c.Query("leftOff")
+	if leftOff == "" {
+		leftOff = entries.LatestLeftOff
+	}
+	rowsCount := 0
+	limitReached := false
+
+	entriesProvider := dependency.GetInstance(dependency.EntriesProvider).(entries.EntriesProvider)
+	err = entriesProvider.Scan(c.Request.Context(), query, leftOff, func(entry *baseApi.Entry) error {
+		if limitReached {
+			return errExportLimitReached
+		}
+
+		object, err := getEntryObject(entry)
+		if err != nil {
+			log.Debug().Err(err).Str("id", entry.Id).Msg("While exporting entry:")
+			return nil
+		}
+
+		rowsCount++
+		return writer.Write(export.NewFieldsRow(fields, object))
+	}, func(metadata *basenine.Metadata) {
+		leftOff = metadata.LeftOff
+		limitReached = limit > 0 && rowsCount >= limit
+	})
+	switch {
+	case errors.Is(err, errExportLimitReached):
+	case err != nil && errors.Is(err, c.Request.Context().Err()):
+		return
+	case err != nil:
+		log.Error().Err(err).Msg("While exporting entries:")
+		c.Writer.Header().Set(exportErrorTrailer, err.Error())
+	default:
+		leftOff = ""
+	}
+
+	if err := writer.Flush(); err != nil {
+		log.Error().Err(err).Msg("While exporting entries:")
+		c.Writer.Header().Set(exportErrorTrailer, err.Error())
+	}
+	c.Writer.Header().Set(exportLeftOffTrailer, leftOff)
+}
+
+// getListQuery returns the items of a query parameter given either repeatedly or as a comma separated list.
+func getListQuery(c *gin.Context, key string) []string {
+	items := make([]string, 0)
+	for _, value := range c.QueryArray(key) {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+	}
+
+	return items
+}
+
+// getEntryObject decodes an entry the way Basenine stores it, so the fields are named as in the queries.
+func getEntryObject(entry *baseApi.Entry) (map[string]interface{}, error) {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return nil, err
+	}
+
+	var object map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&object); err != nil {
+		return nil, err
 	}
+
+	return object, nil
+}
+
+// getScanQuery validates the query of an export or an aggregation, joined with the saved query it refers to,
+// and narrows it down to the optional time range.
+func getScanQuery(c *gin.Context) (string, error) {
+	query, err := getQueryWithSavedQuery(c, c.Query("query"))
+	if err != nil {
+		return "", err
+	}
+	if query != "" {
+		if err := basenine.Validate(db.BasenineHost, db.BaseninePort, query); err != nil {
+			return "", fmt.Errorf("invalid query: %v", err)
+		}
+	}
+
+	startTimeMs, err := strconv.ParseInt(c.DefaultQuery("startTimeMs", "0"), 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("invalid start time: %v", err)
+	}
+	endTimeMs, err := strconv.ParseInt(c.DefaultQuery("endTimeMs", "0"), 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("invalid end time: %v", err)
+	}
+
+	return entries.WithTimeRange(query, startTimeMs, endTimeMs), nil
+}
+
+func getEntryMetadata(entry *baseApi.Entry) *har.EntryMetadata {
+	metadata := &har.EntryMetadata{
+		Id:        entry.Id,
+		Namespace: entry.Namespace,
+	}
+	if entry.Source != nil {
+		metadata.Source = entry.Source.Name
+	}
+	if entry.Destination != nil {
+		metadata.Destination = entry.Destination.Name
+	}
+
+	return metadata
+}
+
+// ImportEntries imports the entries of a HAR file, uploaded either as the file field of a form or as the body.
+func ImportEntries(c *gin.Context) {
+	var reader io.Reader = c.Request.Body
+	if c.ContentType() == "multipart/form-data" {
+		fileHeader, err := c.FormFile("file")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid file: %v", err)})
+			return
+		}
+
+		file, err := fileHeader.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid file: %v", err)})
+			return
+		}
+		defer file.Close()
+
+		reader = file
+	}
+
+	result, err := api.ImportHar(reader)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	log.Info().Interface("result", result).Msg("Imported HAR file:")
+	c.JSON(http.StatusOK, result)
+}
+
+// StreamEntries serves the entry stream of the WebSocket over Server-Sent Events, with the same leftOff, fetch and
+// enableFullEntries (or full) parameters. Every entry event has the ID of its entry, so a client that reconnects with
+// Last-Event-ID resumes the stream right after the last entry it got, without fetching the past entries again.
+func StreamEntries(c *gin.Context) {
+	params, lastEventId, err := getStreamParams(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := basenine.Validate(db.BasenineHost, db.BaseninePort, params.Query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid query: %v", err)})
+		return
+	}
+
+	streamEntries(c, params, lastEventId)
+}
+
+// streamEntries writes the events of the entry stream until the client is gone or the stream fails, the entry of
+// lastEventId being skipped as the client has it already.
+func streamEntries(c *gin.Context, params *api.WebSocketParams, lastEventId string) {
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	connector := api.NewSSEEntryStreamerConnector(ctx, lastEventId)
+	entriesStreamer := dependency.GetInstance(dependency.EntriesSocketStreamer).(api.EntryStreamer)
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	// the streamer sends the fetched entries before it returns, so it runs along the loop that writes them
+	go func() {
+		if err := entriesStreamer.Stream(ctx, 0, params, connector); err != nil {
+			log.Error().Err(err).Msg("While streaming entries:")
+		}
+	}()
+
+	heartbeat := time.NewTicker(streamHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case event := <-connector.Events():
+			c.Render(-1, sse.Event{Id: event.Id, Event: event.Event, Data: event.Data})
+			return event.Event != api.SSEEventError
+		case <-heartbeat.C:
+			_, err := io.WriteString(w, ": heartbeat\n\n")
+			return err == nil
+		case <-connector.Closed():
+			return false
+		case <-ctx.Done():
+			return false
+		}
+	})
+}
+
+// getStreamParams returns the parameters of an entry stream along with the Last-Event-ID of a reconnecting client,
+// which overrides the leftOff and fetch parameters.
+func getStreamParams(c *gin.Context) (*api.WebSocketParams, string, error) {
+	query, err := getQueryWithSavedQuery(c, c.Query("query"))
+	if err != nil {
+		return nil, "", err
+	}
+
+	params := &api.WebSocketParams{
+		LeftOff: c.Query("leftOff"),
+		Query:   query,
+	}
+
+	if params.EnableFullEntries, err = strconv.ParseBool(c.DefaultQuery("enableFullEntries", c.DefaultQuery("full", "false"))); err != nil {
+		return nil, "", fmt.Errorf("invalid full entries: %v", err)
+	}
+	if params.Fetch, err = strconv.Atoi(c.DefaultQuery("fetch", "0")); err != nil {
+		return nil, "", fmt.Errorf("invalid fetch: %v", err)
+	}
+	if params.TimeoutMs, err = strconv.Atoi(c.DefaultQuery("timeoutMs", strconv.Itoa(streamFetchTimeoutMs))); err != nil {
+		return nil, "", fmt.Errorf("invalid timeout: %v", err)
+	}
+
+	lastEventId := c.GetHeader("Last-Event-ID")
+	if lastEventId != "" {
+		params.LeftOff = lastEventId
+		params.Fetch = 0
+	}
+
+	return params, lastEventId, nil
 }
@@ -0,0 +1,152 @@
+package controllers
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	baseApi "github.com/kubeshark/base/pkg/api"
+	"github.com/kubeshark/hub/pkg/api"
+	"github.com/kubeshark/hub/pkg/dependency"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeEntryStreamer records the parameters of the stream and sends its events through the connector.
+type fakeEntryStreamer struct {
+	params chan api.WebSocketParams
+	stream func(connector api.EntryStreamerSocketConnector)
+}
+
+func (s *fakeEntryStreamer) Get(ctx context.Context, socketId int, params *api.WebSocketParams) error {
+	return nil
+}
+
+func (s *fakeEntryStreamer) Stream(ctx context.Context, socketId int, params *api.WebSocketParams, connector api.EntryStreamerSocketConnector) error {
+	s.params <- *params
+	s.stream(connector)
+	return nil
+}
+
+// startEntryStream serves the entry stream of the fake streamer, without validating the query against Basenine.
+func startEntryStream(t *testing.T, stream func(connector api.EntryStreamerSocketConnector)) (*fakeEntryStreamer, *httptest.Server) {
+	streamer := &fakeEntryStreamer{params: make(chan api.WebSocketParams, 1), stream: stream}
+	dependency.RegisterGenerator(dependency.EntriesSocketStreamer, func() interface{} { return streamer })
+
+	router := gin.New()
+	router.GET("/entries/stream", func(c *gin.Context) {
+		params, lastEventId, err := getStreamParams(c)
+		require.NoError(t, err)
+		streamEntries(c, params, lastEventId)
+	})
+
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+
+	return streamer, server
+}
+
+func getEntryStream(t *testing.T, url string, lastEventId string) *http.Response {
+	request, err := http.NewRequest(http.MethodGet, url, nil)
+	require.NoError(t, err)
+	if lastEventId != "" {
+		request.Header.Set("Last-Event-ID", lastEventId)
+	}
+
+	response, err := http.DefaultClient.Do(request)
+	require.NoError(t, err)
+	t.Cleanup(func() { response.Body.Close() })
+
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, "text/event-stream", response.Header.Get("Content-Type"))
+	return response
+}
+
+func getEventIds(body string) []string {
+	ids := make([]string, 0)
+	for _, line := range strings.Split(body, "\n") {
+		if strings.HasPrefix(line, "id:") {
+			ids = append(ids, strings.TrimPrefix(line, "id:"))
+		}
+	}
+
+	return ids
+}
+
+func TestStreamEntriesResumesFromLastEventId(t *testing.T) {
+	streamer, server := startEntryStream(t, func(connector api.EntryStreamerSocketConnector) {
+		params := &api.WebSocketParams{EnableFullEntries: true}
+		for _, id := range []string{"7", "8"} {
+			_ = connector.SendEntry(0, &baseApi.Entry{Id: id}, params)
+		}
+		_ = connector.SendToastError(0, errors.New("done"))
+	})
+
+	response := getEntryStream(t, server.URL+"/entries/stream?query=http&leftOff=5&fetch=10&full=true", "7")
+
+	params := <-streamer.params
+	assert.Equal(t, "http", params.Query)
+	assert.Equal(t, "7", params.LeftOff)
+	assert.Equal(t, 0, params.Fetch)
+
+	// the client has the entry of the Last-Event-ID already
+	body, err := io.ReadAll(response.Body)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"8"}, getEventIds(string(body)))
+}
+
+func TestStreamEntriesWithoutLastEventId(t *testing.T) {
+	streamer, server := startEntryStream(t, func(connector api.EntryStreamerSocketConnector) {
+		_ = connector.SendToastError(0, errors.New("done"))
+	})
+
+	getEntryStream(t, server.URL+"/entries/stream?query=http&leftOff=5&fetch=10", "")
+
+	params := <-streamer.params
+	assert.Equal(t, "5", params.LeftOff)
+	assert.Equal(t, 10, params.Fetch)
+}
+
+func TestStreamEntriesEndsOnError(t *testing.T) {
+	_, server := startEntryStream(t, func(connector api.EntryStreamerSocketConnector) {
+		_ = connector.SendToastError(0, errors.New("boom"))
+	})
+
+	response := getEntryStream(t, server.URL+"/entries/stream?query=http", "")
+
+	// the response ends right after the error event
+	body, err := io.ReadAll(response.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "event:error\ndata:{\"error\":\"boom\"}\n\n", string(body))
+}
+
+func TestStreamEntriesWritesHeartbeats(t *testing.T) {
+	interval := streamHeartbeatInterval
+	streamHeartbeatInterval = 10 * time.Millisecond
+	t.Cleanup(func() { streamHeartbeatInterval = interval })
+
+	_, server := startEntryStream(t, func(connector api.EntryStreamerSocketConnector) {})
+
+	response := getEntryStream(t, server.URL+"/entries/stream?query=http", "")
+
+	// the stream stays idle, its first line is a heartbeat
+	line := make(chan string, 1)
+	go func() {
+		text, _ := bufio.NewReader(response.Body).ReadString('\n')
+		line <- text
+	}()
+
+	select {
+	case line := <-line:
+		assert.Equal(t, ": heartbeat\n", line)
+	case <-time.After(time.Second):
+		assert.Fail(t, "no heartbeat was written")
+	}
+}
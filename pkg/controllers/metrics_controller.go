@@ -0,0 +1,90 @@
+package controllers
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kubeshark/base/pkg/models"
+	"github.com/kubeshark/hub/pkg/api"
+	"github.com/kubeshark/hub/pkg/dependency"
+	"github.com/kubeshark/hub/pkg/holder"
+	"github.com/kubeshark/hub/pkg/metrics"
+	"github.com/kubeshark/hub/pkg/oas"
+	"github.com/kubeshark/hub/pkg/providers"
+	"github.com/kubeshark/hub/pkg/providers/workers"
+	"github.com/kubeshark/hub/pkg/servicemap"
+)
+
+const metricsPrefix = "kubeshark_hub_"
+
+func GetMetrics(c *gin.Context) {
+	w := metrics.NewWriter()
+
+	writeIngestionMetrics(w)
+	writeWorkersMetrics(w)
+
+	w.Single(metricsPrefix+"connected_browsers", "Number of the connected browser sockets.", metrics.TypeGauge, float64(api.GetConnectedBrowsersCount()))
+
+	if inserter, ok := dependency.GetInstance(dependency.EntriesInserter).(*api.BasenineEntryInserter); ok {
+		w.Single(metricsPrefix+"basenine_insert_errors_total", "Number of the entries that failed to be inserted into Basenine.", metrics.TypeCounter, float64(inserter.GetInsertErrorsCount()))
+	}
+
+	resolverEntries := 0
+	if k8sResolver := holder.GetResolver(); k8sResolver != nil {
+		resolverEntries = k8sResolver.GetMap().Count()
+	}
+	w.Single(metricsPrefix+"resolver_entries", "Number of the addresses known to the Kubernetes resolver.", metrics.TypeGauge, float64(resolverEntries))
+
+	oasServices := 0
+	oasGenerator := dependency.GetInstance(dependency.OasGeneratorDependency).(oas.OasGenerator)
+	oasGenerator.GetServiceSpecs().Range(func(key, value interface{}) bool {
+		oasServices++
+		return true
+	})
+	w.Single(metricsPrefix+"oas_services", "Number of the services with an OAS spec.", metrics.TypeGauge, float64(oasServices))
+
+	serviceMapStatus := dependency.GetInstance(dependency.ServiceMapGeneratorDependency).(servicemap.ServiceMap).GetStatus()
+	w.Single(metricsPrefix+"service_map_nodes", "Number of the service map nodes.", metrics.TypeGauge, float64(serviceMapStatus.NodeCount))
+	w.Single(metricsPrefix+"service_map_edges", "Number of the service map edges.", metrics.TypeGauge, float64(serviceMapStatus.EdgeCount))
+	w.Single(metricsPrefix+"service_map_entries_processed", "Number of the entries processed by the service map since its last reset.", metrics.TypeGauge, float64(serviceMapStatus.EntriesProcessedCount))
+
+	c.Data(http.StatusOK, metrics.ContentType, w.Bytes())
+}
+
+func writeIngestionMetrics(w *metrics.Writer) {
+	counters := providers.GetIngestionCounters()
+	sort.Slice(counters, func(i, j int) bool {
+		if counters[i].Protocol != counters[j].Protocol {
+			return counters[i].Protocol < counters[j].Protocol
+		}
+		return counters[i].Method < counters[j].Method
+	})
+
+	w.Family(metricsPrefix+"entries_total", "Number of the ingested entries.", metrics.TypeCounter)
+	for _, counter := range counters {
+		w.Sample(metricsPrefix+"entries_total", float64(counter.EntriesCount), metrics.Label{Name: "protocol", Value: counter.Protocol}, metrics.Label{Name: "method", Value: counter.Method})
+	}
+
+	w.Family(metricsPrefix+"entries_bytes_total", "Size of the ingested entries in bytes.", metrics.TypeCounter)
+	for _, counter := range counters {
+		w.Sample(metricsPrefix+"entries_bytes_total", float64(counter.VolumeInBytes), metrics.Label{Name: "protocol", Value: counter.Protocol}, metrics.Label{Name: "method", Value: counter.Method})
+	}
+}
+
+func writeWorkersMetrics(w *metrics.Writer) {
+	w.Single(metricsPrefix+"connected_workers", "Number of the connected workers.", metrics.TypeGauge, float64(workers.GetConnectedCount()))
+
+	workersStatus := make([]*models.WorkerStatus, 0)
+	for _, value := range workers.GetStatus() {
+		workersStatus = append(workersStatus, value)
+	}
+	sort.Slice(workersStatus, func(i, j int) bool {
+		return workersStatus[i].NodeName < workersStatus[j].NodeName
+	})
+
+	w.Family(metricsPrefix+"worker_status", "Last status reported by the worker of each node.", metrics.TypeGauge)
+	for _, workerStatus := range workersStatus {
+		w.Sample(metricsPrefix+"worker_status", 1, metrics.Label{Name: "node", Value: workerStatus.NodeName}, metrics.Label{Name: "worker", Value: workerStatus.Name}, metrics.Label{Name: "status", Value: workerStatus.Status})
+	}
+}
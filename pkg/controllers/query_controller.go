@@ -1,13 +1,30 @@
 package controllers
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	baseApi "github.com/kubeshark/base/pkg/api"
+	"github.com/kubeshark/hub/pkg/aggregate"
+	"github.com/kubeshark/hub/pkg/app"
 	"github.com/kubeshark/hub/pkg/db"
+	"github.com/kubeshark/hub/pkg/dependency"
+	"github.com/kubeshark/hub/pkg/entries"
+	"github.com/kubeshark/hub/pkg/suggestions"
+	"github.com/rs/zerolog/log"
 	basenine "github.com/up9inc/basenine/client/go"
 )
 
+const (
+	aggregateDefaultTimeoutMs = 30000
+	aggregateMaxTimeoutMs     = 300000
+)
+
 type ValidateResponse struct {
 	Valid   bool   `json:"valid"`
 	Message string `json:"message"`
@@ -29,3 +46,120 @@ func PostValidate(c *gin.Context) {
 		Message: message,
 	})
 }
+
+// GetQueryFields returns the macros, the fields of the entries by protocol and the frequent values of the fields.
+func GetQueryFields(c *gin.Context) {
+	limit, err := getSuggestionsLimit(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	catalog := dependency.GetInstance(dependency.QuerySuggestionsDependency).(*suggestions.Catalog)
+	c.JSON(http.StatusOK, catalog.GetFields(suggestions.GetMacros(app.Extensions), limit))
+}
+
+// GetQuerySuggestions returns the macros, the fields and the values, with their queries, that start with the prefix.
+func GetQuerySuggestions(c *gin.Context) {
+	limit, err := getSuggestionsLimit(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	catalog := dependency.GetInstance(dependency.QuerySuggestionsDependency).(*suggestions.Catalog)
+	c.JSON(http.StatusOK, catalog.Suggest(suggestions.GetMacros(app.Extensions), c.Query("prefix"), limit))
+}
+
+func getSuggestionsLimit(c *gin.Context) (int, error) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(suggestions.DefaultLimit)))
+	if err != nil {
+		return 0, fmt.Errorf("invalid limit: %v", err)
+	}
+	if limit <= 0 || limit > suggestions.MaxLimit {
+		return 0, fmt.Errorf("invalid limit: %d, must be between 1 and %d", limit, suggestions.MaxLimit)
+	}
+
+	return limit, nil
+}
+
+// AggregateEntries groups the stored entries matching the query by fields and a time bucket and aggregates them.
+// The entries are scanned until the timeout, in which case the result is partial, or until the request is cancelled.
+func AggregateEntries(c *gin.Context) {
+	query, err := getScanQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	spec, timeout, err := getAggregateSpec(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+	defer cancel()
+
+	aggregator := aggregate.NewAggregator(spec)
+	entriesProvider := dependency.GetInstance(dependency.EntriesProvider).(entries.EntriesProvider)
+	err = entriesProvider.Scan(ctx, query, entries.LatestLeftOff, func(entry *baseApi.Entry) error {
+		object, err := getEntryObject(entry)
+		if err != nil {
+			log.Debug().Err(err).Str("id", entry.Id).Msg("While aggregating entry:")
+			return nil
+		}
+
+		aggregator.Add(object)
+		return nil
+	}, nil)
+
+	result := aggregator.Result()
+	switch {
+	case err == nil:
+	case errors.Is(err, context.DeadlineExceeded) && c.Request.Context().Err() == nil:
+		result.Partial = true
+		result.Reason = fmt.Sprintf("timed out after %v", timeout)
+	case errors.Is(err, context.Canceled):
+		return
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+func getAggregateSpec(c *gin.Context) (*aggregate.Spec, time.Duration, error) {
+	spec := &aggregate.Spec{
+		GroupBy:    getListQuery(c, "groupBy"),
+		Aggregates: make([]*aggregate.Aggregate, 0),
+	}
+
+	aggregates := getListQuery(c, "aggregates")
+	if len(aggregates) == 0 {
+		aggregates = []string{aggregate.FunctionCount}
+	}
+	for _, value := range aggregates {
+		parsed, err := aggregate.ParseAggregate(value)
+		if err != nil {
+			return nil, 0, err
+		}
+		spec.Aggregates = append(spec.Aggregates, parsed)
+	}
+
+	if value := c.Query("bucket"); value != "" {
+		bucket, err := time.ParseDuration(value)
+		if err != nil || bucket < time.Second {
+			return nil, 0, fmt.Errorf("invalid bucket: %s, must be a duration of at least 1s", value)
+		}
+		spec.Bucket = bucket
+	}
+
+	timeoutMs, err := strconv.Atoi(c.DefaultQuery("timeoutMs", strconv.Itoa(aggregateDefaultTimeoutMs)))
+	if err != nil || timeoutMs <= 0 || timeoutMs > aggregateMaxTimeoutMs {
+		return nil, 0, fmt.Errorf("invalid timeout: %s, must be between 1 and %d milliseconds", c.Query("timeoutMs"), aggregateMaxTimeoutMs)
+	}
+
+	return spec, time.Duration(timeoutMs) * time.Millisecond, nil
+}
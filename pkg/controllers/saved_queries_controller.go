@@ -0,0 +1,113 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kubeshark/hub/pkg/db"
+	"github.com/kubeshark/hub/pkg/entries"
+	"github.com/kubeshark/hub/pkg/providers/savedQueries"
+	basenine "github.com/up9inc/basenine/client/go"
+)
+
+func GetSavedQueries(c *gin.Context) {
+	c.JSON(http.StatusOK, savedQueries.GetAll(c.Query("tag")))
+}
+
+func GetSavedQuery(c *gin.Context) {
+	savedQuery, err := savedQueries.Get(c.Param("name"))
+	if err != nil {
+		handleSavedQueryError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, savedQuery)
+}
+
+func PostSavedQuery(c *gin.Context) {
+	savedQuery, ok := bindSavedQuery(c)
+	if !ok {
+		return
+	}
+
+	created, err := savedQueries.Create(savedQuery)
+	if err != nil {
+		handleSavedQueryError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, created)
+}
+
+func PutSavedQuery(c *gin.Context) {
+	savedQuery, ok := bindSavedQuery(c)
+	if !ok {
+		return
+	}
+
+	updated, err := savedQueries.Update(c.Param("name"), savedQuery)
+	if err != nil {
+		handleSavedQueryError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, updated)
+}
+
+func DeleteSavedQuery(c *gin.Context) {
+	if err := savedQueries.Delete(c.Param("name")); err != nil {
+		handleSavedQueryError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// bindSavedQuery binds the saved query of the body and validates its name and query.
+func bindSavedQuery(c *gin.Context) (*savedQueries.SavedQuery, bool) {
+	var savedQuery savedQueries.SavedQuery
+	if err := c.ShouldBindJSON(&savedQuery); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+
+	if err := savedQueries.ValidateName(savedQuery.Name); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+
+	if err := basenine.Validate(db.BasenineHost, db.BaseninePort, savedQuery.Query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid query: %v", err)})
+		return nil, false
+	}
+
+	return &savedQuery, true
+}
+
+func handleSavedQueryError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, savedQueries.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, savedQueries.ErrAlreadyExists):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// getQueryWithSavedQuery joins a query with the saved query the savedQuery parameter refers to by name, if any.
+func getQueryWithSavedQuery(c *gin.Context, query string) (string, error) {
+	name := c.Query("savedQuery")
+	if name == "" {
+		return query, nil
+	}
+
+	savedQuery, err := savedQueries.Get(name)
+	if err != nil {
+		return "", fmt.Errorf("%v: %s", err, name)
+	}
+
+	return entries.And(savedQuery.Query, query), nil
+}
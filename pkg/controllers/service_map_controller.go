@@ -1,22 +1,36 @@
 package controllers
 
 import (
+	"context"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 
+	baseApi "github.com/kubeshark/base/pkg/api"
+	"github.com/kubeshark/hub/pkg/app"
+	"github.com/kubeshark/hub/pkg/db"
 	"github.com/kubeshark/hub/pkg/dependency"
+	"github.com/kubeshark/hub/pkg/entries"
+	"github.com/kubeshark/hub/pkg/holder"
+	"github.com/kubeshark/hub/pkg/networkpolicy"
 	"github.com/kubeshark/hub/pkg/servicemap"
+	"github.com/kubeshark/hub/pkg/validation"
+	basenine "github.com/up9inc/basenine/client/go"
 
 	"github.com/gin-gonic/gin"
 )
 
 type ServiceMapController struct {
 	service servicemap.ServiceMap
+	builder *servicemap.HistoricalBuilder
 }
 
 func NewServiceMapController() *ServiceMapController {
 	serviceMapGenerator := dependency.GetInstance(dependency.ServiceMapGeneratorDependency).(servicemap.ServiceMap)
 	return &ServiceMapController{
 		service: serviceMapGenerator,
+		builder: servicemap.NewHistoricalBuilder(feedStoredEntries),
 	}
 }
 
@@ -37,3 +51,152 @@ func (s *ServiceMapController) Reset(c *gin.Context) {
 	s.service.Reset()
 	s.Status(c)
 }
+
+func (s *ServiceMapController) GetDependencies(c *gin.Context) {
+	depth, err := strconv.Atoi(c.DefaultQuery("depth", "0"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid depth: %v", err)})
+		return
+	}
+
+	response, err := s.service.GetDependencies(c.Query("node"), c.DefaultQuery("direction", servicemap.DirectionUpstream), depth)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (s *ServiceMapController) GetCycles(c *gin.Context) {
+	c.JSON(http.StatusOK, s.service.GetCycles())
+}
+
+func (s *ServiceMapController) GetFanRanking(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid limit: %v", err)})
+		return
+	}
+
+	ranking, err := s.service.GetFanRanking(c.DefaultQuery("by", servicemap.RankByFanIn), limit)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, ranking)
+}
+
+func (s *ServiceMapController) GetShortestPath(c *gin.Context) {
+	response, err := s.service.GetShortestPath(c.Query("from"), c.Query("to"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (s *ServiceMapController) StartBuild(c *gin.Context) {
+	request := &servicemap.ServiceMapBuildRequest{}
+	if err := c.Bind(request); err != nil {
+		c.JSON(http.StatusBadRequest, err)
+		return
+	}
+
+	if err := validation.Validate(request); err != nil {
+		c.JSON(http.StatusBadRequest, err)
+		return
+	}
+
+	if err := basenine.Validate(db.BasenineHost, db.BaseninePort, request.Query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, s.builder.Start(request))
+}
+
+func (s *ServiceMapController) ListBuilds(c *gin.Context) {
+	c.JSON(http.StatusOK, s.builder.List())
+}
+
+func (s *ServiceMapController) GetBuild(c *gin.Context) {
+	response, err := s.builder.Get(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (s *ServiceMapController) CancelBuild(c *gin.Context) {
+	status, err := s.builder.Cancel(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+func (s *ServiceMapController) GetNetworkPolicies(c *gin.Context) {
+	result, err := networkpolicy.Generate(s.service.GetEdges(), networkpolicy.NewWorkloadResolver(holder.GetResolver()),
+		c.DefaultQuery("groupBy", networkpolicy.GroupByWorkload), c.Query("namespace"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	policies, err := networkpolicy.ToYaml(result.Policies)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var comments strings.Builder
+	for _, warning := range result.Warnings {
+		comments.WriteString(fmt.Sprintf("# %s\n", warning))
+	}
+
+	c.Data(http.StatusOK, "application/yaml", append([]byte(comments.String()), policies...))
+}
+
+func (s *ServiceMapController) DryRunNetworkPolicies(c *gin.Context) {
+	policies, err := networkpolicy.ParsePolicies(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid policies: %v", err)})
+		return
+	}
+
+	report, err := networkpolicy.DryRun(s.service.GetEdges(), networkpolicy.NewWorkloadResolver(holder.GetResolver()), policies)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+func feedStoredEntries(ctx context.Context, request *servicemap.ServiceMapBuildRequest, sink servicemap.ServiceMapSink, progress func(current uint64, total uint64)) error {
+	entriesProvider := dependency.GetInstance(dependency.EntriesProvider).(entries.EntriesProvider)
+	query := entries.WithTimeRange(request.Query, request.StartTimeMs, request.EndTimeMs)
+
+	return entriesProvider.Scan(ctx, query, entries.LatestLeftOff, func(entry *baseApi.Entry) error {
+		if entry.Source == nil || entry.Destination == nil {
+			return nil
+		}
+
+		protocol, ok := app.ProtocolsMap[entry.Protocol.ToString()]
+		if !ok {
+			return nil
+		}
+
+		sink.NewTCPEntry(entry.Source, entry.Destination, protocol)
+		return nil
+	}, func(metadata *basenine.Metadata) {
+		progress(metadata.Current, metadata.Total)
+	})
+}
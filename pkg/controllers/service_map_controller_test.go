@@ -127,6 +127,7 @@ func (s *ServiceMapControllerSuite) TestGet() {
 			Destination: bNode,
 			Protocol:    ProtocolHttp,
 			Count:       1,
+			Ports:       []servicemap.ServiceMapEdgePort{{Port: Port, Count: 1}},
 		},
 	}, response.Edges)
 }
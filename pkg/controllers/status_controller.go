@@ -1,24 +1,37 @@
 package controllers
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	baseApi "github.com/kubeshark/base/pkg/api"
 	"github.com/kubeshark/base/pkg/models"
+	"github.com/kubeshark/hub/pkg/anomaly"
 	"github.com/kubeshark/hub/pkg/api"
+	"github.com/kubeshark/hub/pkg/dependency"
+	"github.com/kubeshark/hub/pkg/entries"
+	"github.com/kubeshark/hub/pkg/export"
 	"github.com/kubeshark/hub/pkg/holder"
 	"github.com/kubeshark/hub/pkg/kubernetes"
 	"github.com/kubeshark/hub/pkg/providers"
 	"github.com/kubeshark/hub/pkg/providers/targettedPods"
 	"github.com/kubeshark/hub/pkg/providers/workers"
+	"github.com/kubeshark/hub/pkg/top"
 	"github.com/kubeshark/hub/pkg/validation"
 	"github.com/rs/zerolog/log"
 	core "k8s.io/api/core/v1"
 )
 
+// topIdsTimeoutMs bounds the time of finding the ids of the slowest entries, those not found are left without an id.
+const topIdsTimeoutMs = 5000
+
+var errTopIdsFound = errors.New("top ids found")
+
 func HealthCheck(c *gin.Context) {
 	workersStatus := make([]*models.WorkerStatus, 0)
 	for _, value := range workers.GetStatus() {
@@ -87,7 +100,195 @@ func GetTrafficStats(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, providers.GetTrafficStats(startTime, endTime))
+
+	groupBy := c.Query("groupBy")
+	filters := c.QueryArray("filter")
+	if groupBy == "" && len(filters) == 0 {
+		c.JSON(http.StatusOK, providers.GetTrafficStats(startTime, endTime))
+		return
+	}
+
+	filter, err := providers.ParseStatsFilter(filters)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if groupBy == "" {
+		groupBy = providers.GroupByProtocol
+	}
+
+	response, err := providers.GetTrafficStatsBy(startTime, endTime, groupBy, filter)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func ExportTrafficStats(c *gin.Context) {
+	startTime, endTime, err := getStartEndTime(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var interval time.Duration
+	if value := c.Query("interval"); value != "" {
+		if interval, err = time.ParseDuration(value); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid interval: %v", err)})
+			return
+		}
+	}
+
+	format := c.DefaultQuery("format", export.FormatCSV)
+	writer, err := export.NewWriter(format, c.Writer)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// the headers are sent along with the first row, so an invalid interval can still be answered with an error
+	started := false
+	start := func() {
+		if !started {
+			c.Header("Content-Type", writer.ContentType())
+			c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=traffic-stats.%s", format))
+			c.Status(http.StatusOK)
+			started = true
+		}
+	}
+
+	err = providers.ExportTrafficStats(startTime, endTime, interval, func(row *providers.StatsExportRow) error {
+		start()
+		return writer.Write(row)
+	})
+	if err != nil && !started {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("While exporting traffic stats:")
+		return
+	}
+
+	start()
+	if err := writer.Flush(); err != nil {
+		log.Error().Err(err).Msg("While exporting traffic stats:")
+	}
+}
+
+func GetTrafficMatrix(c *gin.Context) {
+	startTime, endTime, err := getStartEndTime(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid limit: %v", err)})
+		return
+	}
+
+	filter, err := providers.ParseStatsFilter(c.QueryArray("filter"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, providers.GetTrafficMatrix(startTime, endTime, filter, limit))
+}
+
+func GetCostReport(c *gin.Context) {
+	startTime, endTime, err := getStartEndTime(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	prices, err := providers.ParseCostPrices(c.QueryArray("price"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, providers.GetCostReport(startTime, endTime, prices))
+}
+
+func GetAnomalies(c *gin.Context) {
+	sinceMs, err := strconv.ParseInt(c.DefaultQuery("sinceMs", "0"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid since time: %v", err)})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid limit: %v", err)})
+		return
+	}
+
+	detector := dependency.GetInstance(dependency.AnomalyDetectorDependency).(*anomaly.Detector)
+	c.JSON(http.StatusOK, detector.GetEvents(sinceMs, limit))
+}
+
+func GetTop(c *gin.Context) {
+	window := top.DefaultWindow
+	if value := c.Query("window"); value != "" {
+		var err error
+		if window, err = time.ParseDuration(value); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid window: %v", err)})
+			return
+		}
+	}
+
+	n, err := strconv.Atoi(c.DefaultQuery("n", "10"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid n: %v", err)})
+		return
+	}
+
+	now := time.Now()
+	tracker := dependency.GetInstance(dependency.TopTrackerDependency).(*top.Tracker)
+	response, err := tracker.Get(now, window, n)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// the ids are assigned once the entries are inserted, so the slowest entries are found in the database
+	if query, missing := top.GetMissingIdsQuery(response.Slowest); missing > 0 {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), topIdsTimeoutMs*time.Millisecond)
+		defer cancel()
+
+		found := 0
+		entriesProvider := dependency.GetInstance(dependency.EntriesProvider).(entries.EntriesProvider)
+		err := entriesProvider.Scan(ctx, query, entries.LatestLeftOff, func(entry *baseApi.Entry) error {
+			if tracker.SetEntryId(entry) {
+				if found++; found >= missing {
+					return errTopIdsFound
+				}
+			}
+			return nil
+		}, nil)
+
+		switch {
+		case err == nil || errors.Is(err, errTopIdsFound):
+		case errors.Is(err, context.Canceled):
+			return
+		default:
+			log.Warn().Err(err).Msg("While finding the ids of the slowest entries:")
+		}
+
+		if response, err = tracker.Get(now, window, n); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
 }
 
 func getStartEndTime(c *gin.Context) (time.Time, time.Time, error) {
@@ -0,0 +1,147 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	baseApi "github.com/kubeshark/base/pkg/api"
+	"github.com/kubeshark/hub/pkg/api"
+	"github.com/kubeshark/hub/pkg/dependency"
+	"github.com/kubeshark/hub/pkg/entries"
+	"github.com/kubeshark/hub/pkg/traces"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	tracesDefaultTimeoutMs = 5000
+	tracesMaxTimeoutMs     = 60000
+)
+
+var (
+	errTracesLimitReached = errors.New("traces limit reached")
+	errTraceIdsFound      = errors.New("trace ids found")
+)
+
+type TracesResponse struct {
+	Traces  []*traces.Summary `json:"traces"`
+	Partial bool              `json:"partial"`
+	Reason  string            `json:"reason,omitempty"`
+}
+
+// GetTrace returns the tree of the entries of a trace, ordered by their start times.
+// The entries whose ids are unknown are looked up in the database first, until the default timeout.
+func GetTrace(c *gin.Context) {
+	traceId := c.Param("traceId")
+	index := dependency.GetInstance(dependency.TracesIndexDependency).(*traces.Index)
+	if query, missing := index.GetMissingIdsQuery(traceId); missing > 0 {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), tracesDefaultTimeoutMs*time.Millisecond)
+		defer cancel()
+
+		found := 0
+		entriesProvider := dependency.GetInstance(dependency.EntriesProvider).(entries.EntriesProvider)
+		err := entriesProvider.Scan(ctx, query, entries.LatestLeftOff, func(entry *baseApi.Entry) error {
+			summary, err := api.SummarizeEntry(entry)
+			if err != nil {
+				return nil
+			}
+
+			if index.SetEntryId(entry, summary) == traceId {
+				if found++; found >= missing {
+					return errTraceIdsFound
+				}
+			}
+			return nil
+		}, nil)
+
+		switch {
+		case err == nil || errors.Is(err, errTraceIdsFound):
+		case errors.Is(err, context.Canceled):
+			return
+		default:
+			log.Warn().Err(err).Str("trace", traceId).Msg("While finding the entries of a trace:")
+		}
+	}
+
+	trace := index.Get(traceId)
+	if trace == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("trace not found: %s", traceId)})
+		return
+	}
+
+	c.JSON(http.StatusOK, trace)
+}
+
+// GetTraces lists the most recently updated traces or, given a query, the traces of the latest entries matching it.
+// The entries are scanned until enough traces are found or until the timeout, in which case the listing is partial.
+func GetTraces(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(traces.DefaultLimit)))
+	if err != nil || limit <= 0 || limit > traces.MaxLimit {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid limit: %s, must be between 1 and %d", c.Query("limit"), traces.MaxLimit)})
+		return
+	}
+
+	timeoutMs, err := strconv.Atoi(c.DefaultQuery("timeoutMs", strconv.Itoa(tracesDefaultTimeoutMs)))
+	if err != nil || timeoutMs <= 0 || timeoutMs > tracesMaxTimeoutMs {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid timeout: %s, must be between 1 and %d milliseconds", c.Query("timeoutMs"), tracesMaxTimeoutMs)})
+		return
+	}
+	timeout := time.Duration(timeoutMs) * time.Millisecond
+
+	query, err := getScanQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	index := dependency.GetInstance(dependency.TracesIndexDependency).(*traces.Index)
+	if query == "" {
+		c.JSON(http.StatusOK, TracesResponse{Traces: index.GetRecent(limit)})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+	defer cancel()
+
+	traceIds := make([]string, 0)
+	seen := make(map[string]bool)
+	entriesProvider := dependency.GetInstance(dependency.EntriesProvider).(entries.EntriesProvider)
+	err = entriesProvider.Scan(ctx, query, entries.LatestLeftOff, func(entry *baseApi.Entry) error {
+		summary, err := api.SummarizeEntry(entry)
+		if err != nil {
+			return nil
+		}
+
+		traceId := index.SetEntryId(entry, summary)
+		if traceId == "" || seen[traceId] {
+			return nil
+		}
+		seen[traceId] = true
+		traceIds = append(traceIds, traceId)
+
+		if len(traceIds) >= limit {
+			return errTracesLimitReached
+		}
+		return nil
+	}, nil)
+
+	response := TracesResponse{}
+	switch {
+	case err == nil || errors.Is(err, errTracesLimitReached):
+	case errors.Is(err, context.DeadlineExceeded) && c.Request.Context().Err() == nil:
+		response.Partial = true
+		response.Reason = fmt.Sprintf("timed out after %v", timeout)
+	case errors.Is(err, context.Canceled):
+		return
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	response.Traces = index.GetSummaries(traceIds)
+	c.JSON(http.StatusOK, response)
+}
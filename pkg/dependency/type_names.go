@@ -9,4 +9,9 @@ const (
 	EntriesProvider               ContainerType = "EntriesProvider"
 	EntriesSocketStreamer         ContainerType = "EntriesSocketStreamer"
 	EntryStreamerSocketConnector  ContainerType = "EntryStreamerSocketConnector"
+	AnomalyDetectorDependency     ContainerType = "AnomalyDetectorDependency"
+	TopTrackerDependency          ContainerType = "TopTrackerDependency"
+	QuerySuggestionsDependency    ContainerType = "QuerySuggestionsDependency"
+	TracesIndexDependency         ContainerType = "TracesIndexDependency"
+	OtlpExporterDependency        ContainerType = "OtlpExporterDependency"
 )
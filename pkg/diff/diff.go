@@ -0,0 +1,262 @@
+package diff
+
+import (
+	"encoding/json"
+	"strings"
+
+	baseApi "github.com/kubeshark/base/pkg/api"
+	"github.com/kubeshark/hub/pkg/har"
+	"github.com/wI2L/jsondiff"
+)
+
+// NoisyHeaders differ from one request to the other whatever the request, e.g. dates and request IDs.
+var NoisyHeaders = []string{
+	"date",
+	"expires",
+	"last-modified",
+	"age",
+	"etag",
+	"set-cookie",
+	"x-request-id",
+	"x-correlation-id",
+	"x-amzn-trace-id",
+	"x-b3-traceid",
+	"x-b3-spanid",
+	"x-b3-parentspanid",
+	"x-b3-sampled",
+	"traceparent",
+	"tracestate",
+	"x-envoy-upstream-service-time",
+}
+
+// Change is the value of a field in each of the two entries.
+type Change struct {
+	A interface{} `json:"a"`
+	B interface{} `json:"b"`
+}
+
+// ValuesDiff compares named values such as the headers, by the names only one of the entries has and the changed ones.
+type ValuesDiff struct {
+	Added   map[string]string  `json:"added,omitempty"`
+	Removed map[string]string  `json:"removed,omitempty"`
+	Changed map[string]*Change `json:"changed,omitempty"`
+}
+
+// BodyDiff is the JSON patch from the body of the first entry to the one of the second entry when both are JSON,
+// otherwise both bodies as text.
+type BodyDiff struct {
+	Patch jsondiff.Patch `json:"patch,omitempty"`
+	A     *string        `json:"a,omitempty"`
+	B     *string        `json:"b,omitempty"`
+}
+
+// Diff holds only what differs between two entries, an empty one means they are the same.
+type Diff struct {
+	A               string             `json:"a"`
+	B               string             `json:"b"`
+	Protocol        *Change            `json:"protocol,omitempty"`
+	RequestLine     map[string]*Change `json:"requestLine,omitempty"`
+	RequestHeaders  *ValuesDiff        `json:"requestHeaders,omitempty"`
+	QueryParameters *ValuesDiff        `json:"queryParameters,omitempty"`
+	RequestBody     *BodyDiff          `json:"requestBody,omitempty"`
+	Status          *Change            `json:"status,omitempty"`
+	ResponseHeaders *ValuesDiff        `json:"responseHeaders,omitempty"`
+	ResponseBody    *BodyDiff          `json:"responseBody,omitempty"`
+	Timing          map[string]*Change `json:"timing,omitempty"`
+	Peers           map[string]*Change `json:"peers,omitempty"`
+	// Request and Response are the JSON patches of the entries of the protocols that are not compared field by field.
+	Request  jsondiff.Patch `json:"request,omitempty"`
+	Response jsondiff.Patch `json:"response,omitempty"`
+}
+
+type Options struct {
+	// IgnoredHeaders are the names of the headers left out of the comparison, regardless of case.
+	IgnoredHeaders []string
+}
+
+// Compare compares two entries, field by field when both are HTTP entries.
+func Compare(a *baseApi.EntryWrapper, b *baseApi.EntryWrapper, options *Options) (*Diff, error) {
+	diff := &Diff{
+		A:           a.Data.Id,
+		B:           b.Data.Id,
+		RequestLine: make(map[string]*Change),
+		Timing:      make(map[string]*Change),
+		Peers:       make(map[string]*Change),
+	}
+
+	compareValue(diff.Peers, "src.ip", getIP(a.Data.Source), getIP(b.Data.Source))
+	compareValue(diff.Peers, "src.port", getPort(a.Data.Source), getPort(b.Data.Source))
+	compareValue(diff.Peers, "src.name", getName(a.Data.Source), getName(b.Data.Source))
+	compareValue(diff.Peers, "dst.ip", getIP(a.Data.Destination), getIP(b.Data.Destination))
+	compareValue(diff.Peers, "dst.port", getPort(a.Data.Destination), getPort(b.Data.Destination))
+	compareValue(diff.Peers, "dst.name", getName(a.Data.Destination), getName(b.Data.Destination))
+	compareValue(diff.Peers, "namespace", a.Data.Namespace, b.Data.Namespace)
+
+	compareValue(diff.Timing, "elapsedTime", a.Data.ElapsedTime, b.Data.ElapsedTime)
+	compareValue(diff.Timing, "requestSize", a.Data.RequestSize, b.Data.RequestSize)
+	compareValue(diff.Timing, "responseSize", a.Data.ResponseSize, b.Data.ResponseSize)
+
+	if a.Base != nil && b.Base != nil && a.Base.Status != b.Base.Status {
+		diff.Status = &Change{A: a.Base.Status, B: b.Base.Status}
+	}
+
+	if a.Data.Protocol != b.Data.Protocol {
+		diff.Protocol = &Change{A: a.Data.Protocol.Abbreviation, B: b.Data.Protocol.Abbreviation}
+	}
+
+	if a.Data.Protocol.Name == "http" && b.Data.Protocol.Name == "http" {
+		if err := compareHttp(diff, a.Data, b.Data, options); err == nil {
+			return diff, nil
+		}
+	}
+
+	var err error
+	if diff.Request, err = jsondiff.Compare(a.Data.Request, b.Data.Request); err != nil {
+		return nil, err
+	}
+	if diff.Response, err = jsondiff.Compare(a.Data.Response, b.Data.Response); err != nil {
+		return nil, err
+	}
+
+	return diff, nil
+}
+
+func compareHttp(diff *Diff, a *baseApi.Entry, b *baseApi.Entry, options *Options) error {
+	harA, err := har.NewEntry(a.Request, a.Response, a.StartTime, a.ElapsedTime)
+	if err != nil {
+		return err
+	}
+	harB, err := har.NewEntry(b.Request, b.Response, b.StartTime, b.ElapsedTime)
+	if err != nil {
+		return err
+	}
+
+	compareValue(diff.RequestLine, "method", harA.Request.Method, harB.Request.Method)
+	compareValue(diff.RequestLine, "url", harA.Request.URL, harB.Request.URL)
+	compareValue(diff.RequestLine, "httpVersion", harA.Request.HTTPVersion, harB.Request.HTTPVersion)
+
+	ignored := make(map[string]bool)
+	for _, name := range options.IgnoredHeaders {
+		ignored[strings.ToLower(name)] = true
+	}
+
+	diff.RequestHeaders = compareValues(getHeaders(harA.Request.Headers, ignored), getHeaders(harB.Request.Headers, ignored))
+	diff.ResponseHeaders = compareValues(getHeaders(harA.Response.Headers, ignored), getHeaders(harB.Response.Headers, ignored))
+	diff.QueryParameters = compareValues(getValues(harA.Request.QueryString), getValues(harB.Request.QueryString))
+
+	_, _, requestBodyA := harA.Request.PostData.B64Decoded()
+	_, _, requestBodyB := harB.Request.PostData.B64Decoded()
+	if diff.RequestBody, err = compareBodies(requestBodyA, requestBodyB); err != nil {
+		return err
+	}
+
+	_, _, responseBodyA := harA.Response.Content.B64Decoded()
+	_, _, responseBodyB := harB.Response.Content.B64Decoded()
+	if diff.ResponseBody, err = compareBodies(responseBodyA, responseBodyB); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func compareValue(changes map[string]*Change, name string, a interface{}, b interface{}) {
+	if a != b {
+		changes[name] = &Change{A: a, B: b}
+	}
+}
+
+func compareValues(a map[string]string, b map[string]string) *ValuesDiff {
+	diff := &ValuesDiff{
+		Added:   make(map[string]string),
+		Removed: make(map[string]string),
+		Changed: make(map[string]*Change),
+	}
+
+	for name, valueA := range a {
+		valueB, ok := b[name]
+		if !ok {
+			diff.Removed[name] = valueA
+		} else if valueA != valueB {
+			diff.Changed[name] = &Change{A: valueA, B: valueB}
+		}
+	}
+	for name, valueB := range b {
+		if _, ok := a[name]; !ok {
+			diff.Added[name] = valueB
+		}
+	}
+
+	if len(diff.Added) == 0 && len(diff.Removed) == 0 && len(diff.Changed) == 0 {
+		return nil
+	}
+	return diff
+}
+
+// getHeaders merges the headers by their lower case names, as HTTP/2 has them, and leaves out the ignored ones.
+func getHeaders(headers []har.NVP, ignored map[string]bool) map[string]string {
+	merged := make(map[string]string)
+	for _, header := range headers {
+		name := strings.ToLower(header.Name)
+		if ignored[name] {
+			continue
+		}
+		if value, ok := merged[name]; ok {
+			merged[name] = value + ", " + header.Value
+		} else {
+			merged[name] = header.Value
+		}
+	}
+	return merged
+}
+
+func getValues(nvps []har.NVP) map[string]string {
+	values := make(map[string]string)
+	for _, nvp := range nvps {
+		if value, ok := values[nvp.Name]; ok {
+			values[nvp.Name] = value + ", " + nvp.Value
+		} else {
+			values[nvp.Name] = nvp.Value
+		}
+	}
+	return values
+}
+
+func compareBodies(a string, b string) (*BodyDiff, error) {
+	if a == b {
+		return nil, nil
+	}
+
+	if json.Valid([]byte(a)) && json.Valid([]byte(b)) {
+		patch, err := jsondiff.CompareJSON([]byte(a), []byte(b))
+		if err != nil {
+			return nil, err
+		}
+		if len(patch) == 0 {
+			return nil, nil
+		}
+		return &BodyDiff{Patch: patch}, nil
+	}
+
+	return &BodyDiff{A: &a, B: &b}, nil
+}
+
+func getIP(tcp *baseApi.TCP) string {
+	if tcp == nil {
+		return ""
+	}
+	return tcp.IP
+}
+
+func getPort(tcp *baseApi.TCP) string {
+	if tcp == nil {
+		return ""
+	}
+	return tcp.Port
+}
+
+func getName(tcp *baseApi.TCP) string {
+	if tcp == nil {
+		return ""
+	}
+	return tcp.Name
+}
@@ -0,0 +1,95 @@
+package diff
+
+import (
+	"encoding/json"
+	"testing"
+
+	baseApi "github.com/kubeshark/base/pkg/api"
+	kubesharkhttp "github.com/kubeshark/base/pkg/extensions/http"
+	"github.com/kubeshark/hub/pkg/har"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newEntryWrapper(t *testing.T, id string, harEntry *har.Entry) *baseApi.EntryWrapper {
+	extension := &baseApi.Extension{}
+	dissector := kubesharkhttp.NewDissector()
+	dissector.Register(extension)
+
+	item, err := har.NewOutputChannelItem(harEntry, extension.Protocol)
+	require.NoError(t, err)
+
+	entry := dissector.Analyze(item, "front-end", "carts", "shop")
+	entry.Id = id
+
+	return &baseApi.EntryWrapper{Data: entry, Base: dissector.Summarize(entry)}
+}
+
+func newHarEntry(query string, requestId string, requestBody string, status int, responseBody string) *har.Entry {
+	return &har.Entry{
+		StartedDateTime: "2022-01-01T10:00:00.000Z",
+		Time:            status / 10,
+		ServerIPAddress: "10.0.0.2",
+		Request: har.Request{
+			Method:      "POST",
+			URL:         "http://carts:8080/items" + query,
+			HTTPVersion: "HTTP/1.1",
+			Headers:     []har.NVP{{Name: "Host", Value: "carts:8080"}, {Name: "X-Request-Id", Value: requestId}},
+			PostData:    har.PostData{MimeType: "application/json", Text: requestBody},
+		},
+		Response: har.Response{
+			Status:      status,
+			HTTPVersion: "HTTP/1.1",
+			Headers:     []har.NVP{{Name: "Content-Type", Value: "application/json"}},
+			Content:     har.Content{MimeType: "application/json", Text: responseBody},
+		},
+	}
+}
+
+func TestCompare(t *testing.T) {
+	a := newEntryWrapper(t, "1", newHarEntry("?id=1", "abc", `{"count":1,"color":"red"}`, 200, `{"ok":true}`))
+	b := newEntryWrapper(t, "2", newHarEntry("?id=2&debug=true", "def", `{"count":2,"color":"red"}`, 500, "internal error"))
+
+	diff, err := Compare(a, b, &Options{})
+	require.NoError(t, err)
+
+	assert.Equal(t, "1", diff.A)
+	assert.Equal(t, "2", diff.B)
+	assert.Nil(t, diff.Protocol)
+	assert.Equal(t, map[string]*Change{"url": {A: "http://carts:8080/items?id=1", B: "http://carts:8080/items?id=2&debug=true"}}, diff.RequestLine)
+	assert.Equal(t, &ValuesDiff{
+		Added:   map[string]string{},
+		Removed: map[string]string{},
+		Changed: map[string]*Change{"x-request-id": {A: "abc", B: "def"}},
+	}, diff.RequestHeaders)
+	assert.Equal(t, &ValuesDiff{
+		Added:   map[string]string{"debug": "true"},
+		Removed: map[string]string{},
+		Changed: map[string]*Change{"id": {A: "1", B: "2"}},
+	}, diff.QueryParameters)
+	require.NotNil(t, diff.RequestBody)
+	patch, err := json.Marshal(diff.RequestBody.Patch)
+	require.NoError(t, err)
+	assert.Equal(t, `[{"op":"replace","path":"/count","value":2}]`, string(patch))
+	assert.Equal(t, &Change{A: 200, B: 500}, diff.Status)
+	require.NotNil(t, diff.ResponseBody)
+	assert.Equal(t, `{"ok":true}`, *diff.ResponseBody.A)
+	assert.Equal(t, "internal error", *diff.ResponseBody.B)
+	assert.Equal(t, &Change{A: int64(20), B: int64(50)}, diff.Timing["elapsedTime"])
+	assert.Empty(t, diff.Peers)
+	assert.Empty(t, diff.Request)
+}
+
+func TestCompareIgnoredHeaders(t *testing.T) {
+	a := newEntryWrapper(t, "1", newHarEntry("", "abc", `{}`, 200, `{}`))
+	b := newEntryWrapper(t, "2", newHarEntry("", "def", `{}`, 200, `{}`))
+
+	diff, err := Compare(a, b, &Options{IgnoredHeaders: NoisyHeaders})
+	require.NoError(t, err)
+
+	assert.Nil(t, diff.RequestHeaders)
+	assert.Nil(t, diff.RequestBody)
+	assert.Nil(t, diff.ResponseBody)
+	assert.Nil(t, diff.Status)
+	assert.Empty(t, diff.RequestLine)
+}
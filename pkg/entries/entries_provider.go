@@ -1,6 +1,7 @@
 package entries
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -17,8 +18,15 @@ import (
 type EntriesProvider interface {
 	GetEntries(entriesRequest *models.EntriesRequest) ([]*baseApi.EntryWrapper, *basenine.Metadata, error)
 	GetEntry(singleEntryRequest *models.SingleEntryRequest, entryId string) (*baseApi.EntryWrapper, error)
+	Scan(ctx context.Context, query string, leftOff string, onEntry func(entry *baseApi.Entry) error, onMetadata func(metadata *basenine.Metadata)) error
 }
 
+const (
+	LatestLeftOff     = "latest"
+	scanPageSize      = 1000
+	scanPageTimeoutMs = 5000
+)
+
 type BasenineEntriesProvider struct{}
 
 func (e *BasenineEntriesProvider) GetEntries(entriesRequest *models.EntriesRequest) ([]*baseApi.EntryWrapper, *basenine.Metadata, error) {
@@ -77,6 +85,11 @@ func (e *BasenineEntriesProvider) GetEntry(singleEntryRequest *models.SingleEntr
 		return nil, errors.New(string(bytes))
 	}
 
+	return NewEntryWrapper(entry)
+}
+
+// NewEntryWrapper summarizes and represents a full entry with the dissector of its protocol.
+func NewEntryWrapper(entry *baseApi.Entry) (*baseApi.EntryWrapper, error) {
 	protocol, ok := app.ProtocolsMap[entry.Protocol.ToString()]
 	if !ok {
 		return nil, fmt.Errorf("protocol not found, protocol: %v", protocol)
@@ -88,8 +101,7 @@ func (e *BasenineEntriesProvider) GetEntry(singleEntryRequest *models.SingleEntr
 	}
 
 	base := extension.Dissector.Summarize(entry)
-	var representation []byte
-	representation, err = extension.Dissector.Represent(entry.Request, entry.Response)
+	representation, err := extension.Dissector.Represent(entry.Request, entry.Response)
 	if err != nil {
 		return nil, err
 	}
@@ -101,3 +113,56 @@ func (e *BasenineEntriesProvider) GetEntry(singleEntryRequest *models.SingleEntr
 		Base:           base,
 	}, nil
 }
+
+// Scan pages backwards through the stored entries matching the query, starting from leftOff.
+// It stops when Basenine runs out of data or its leftOff stops advancing, the context is cancelled or onEntry returns
+// an error. A page may come back empty when the query matches none of the entries scanned within the timeout of the
+// page, so an empty page does not end the scan.
+func (e *BasenineEntriesProvider) Scan(ctx context.Context, query string, leftOff string, onEntry func(entry *baseApi.Entry) error, onMetadata func(metadata *basenine.Metadata)) error {
+	if leftOff == "" {
+		leftOff = LatestLeftOff
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		data, _, lastMeta, err := basenine.Fetch(db.BasenineHost, db.BaseninePort,
+			leftOff, -1, query, scanPageSize, scanPageTimeoutMs*time.Millisecond)
+		if err != nil {
+			return err
+		}
+
+		for _, row := range data {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			var entry *baseApi.Entry
+			if err := json.Unmarshal(row, &entry); err != nil {
+				log.Debug().Err(err).Msg("Unmarshalling entry:")
+				continue
+			}
+
+			if err := onEntry(entry); err != nil {
+				return err
+			}
+		}
+
+		var metadata *basenine.Metadata
+		if err := json.Unmarshal(lastMeta, &metadata); err != nil {
+			return fmt.Errorf("error unmarshalling metadata, err: %v", err)
+		}
+
+		if onMetadata != nil {
+			onMetadata(metadata)
+		}
+
+		if metadata.NoMoreData || metadata.LeftOff == leftOff {
+			return nil
+		}
+
+		leftOff = metadata.LeftOff
+	}
+}
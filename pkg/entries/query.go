@@ -0,0 +1,35 @@
+package entries
+
+import (
+	"fmt"
+	"strings"
+)
+
+// WithTimeRange narrows the query down to the entries captured within [startTimeMs, endTimeMs].
+// Zero values leave the corresponding side of the range open.
+func WithTimeRange(query string, startTimeMs int64, endTimeMs int64) string {
+	conditions := make([]string, 0)
+	if strings.TrimSpace(query) != "" {
+		conditions = append(conditions, fmt.Sprintf("(%s)", query))
+	}
+	if startTimeMs > 0 {
+		conditions = append(conditions, fmt.Sprintf("timestamp >= %d", startTimeMs))
+	}
+	if endTimeMs > 0 {
+		conditions = append(conditions, fmt.Sprintf("timestamp <= %d", endTimeMs))
+	}
+
+	return strings.Join(conditions, " and ")
+}
+
+// And joins the non empty queries into a query that matches the entries all of them match.
+func And(queries ...string) string {
+	conditions := make([]string, 0)
+	for _, query := range queries {
+		if strings.TrimSpace(query) != "" {
+			conditions = append(conditions, fmt.Sprintf("(%s)", query))
+		}
+	}
+
+	return strings.Join(conditions, " and ")
+}
@@ -0,0 +1,81 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+const (
+	FormatCSV     = "csv"
+	FormatNDJSON  = "ndjson"
+	FormatParquet = "parquet"
+)
+
+// Row is a record that can be written both as a CSV line and as a JSON object.
+type Row interface {
+	CSVHeader() []string
+	CSVRecord() []string
+}
+
+// Writer writes the rows one by one, so an export can be streamed instead of being built in memory.
+type Writer interface {
+	Write(row Row) error
+	Flush() error
+	ContentType() string
+}
+
+func NewWriter(format string, w io.Writer) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		return &csvWriter{writer: csv.NewWriter(w)}, nil
+	case FormatNDJSON:
+		return &ndjsonWriter{encoder: json.NewEncoder(w)}, nil
+	case FormatParquet:
+		return &parquetWriter{w: w}, nil
+	default:
+		return nil, fmt.Errorf("invalid format: %s, must be %s, %s or %s", format, FormatCSV, FormatNDJSON, FormatParquet)
+	}
+}
+
+type csvWriter struct {
+	writer        *csv.Writer
+	headerWritten bool
+}
+
+func (w *csvWriter) Write(row Row) error {
+	if !w.headerWritten {
+		if err := w.writer.Write(row.CSVHeader()); err != nil {
+			return err
+		}
+		w.headerWritten = true
+	}
+
+	return w.writer.Write(row.CSVRecord())
+}
+
+func (w *csvWriter) Flush() error {
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+func (w *csvWriter) ContentType() string {
+	return "text/csv; charset=utf-8"
+}
+
+type ndjsonWriter struct {
+	encoder *json.Encoder
+}
+
+func (w *ndjsonWriter) Write(row Row) error {
+	return w.encoder.Encode(row)
+}
+
+func (w *ndjsonWriter) Flush() error {
+	return nil
+}
+
+func (w *ndjsonWriter) ContentType() string {
+	return "application/x-ndjson"
+}
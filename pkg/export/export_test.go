@@ -0,0 +1,147 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	parquetbuffer "github.com/xitongsys/parquet-go-source/buffer"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/reader"
+)
+
+type mockRow struct {
+	Name  string `json:"name"`
+	Count string `json:"count"`
+}
+
+func (r *mockRow) CSVHeader() []string {
+	return []string{"name", "count"}
+}
+
+func (r *mockRow) CSVRecord() []string {
+	return []string{r.Name, r.Count}
+}
+
+func TestWriter(t *testing.T) {
+	tests := map[string]struct {
+		format   string
+		expected string
+	}{
+		"csv":    {format: FormatCSV, expected: "name,count\na,1\n\"b,c\",2\n"},
+		"ndjson": {format: FormatNDJSON, expected: "{\"name\":\"a\",\"count\":\"1\"}\n{\"name\":\"b,c\",\"count\":\"2\"}\n"},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			buffer := &bytes.Buffer{}
+			writer, err := NewWriter(test.format, buffer)
+			require.NoError(t, err)
+
+			require.NoError(t, writer.Write(&mockRow{Name: "a", Count: "1"}))
+			require.NoError(t, writer.Write(&mockRow{Name: "b,c", Count: "2"}))
+			require.NoError(t, writer.Flush())
+
+			assert.Equal(t, test.expected, buffer.String())
+		})
+	}
+}
+
+func TestNewWriterInvalidFormat(t *testing.T) {
+	_, err := NewWriter("xml", &bytes.Buffer{})
+	assert.Error(t, err)
+}
+
+func TestParquetWriter(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	writer, err := NewWriter(FormatParquet, buffer)
+	require.NoError(t, err)
+
+	fields := []string{"src.name", "response.status"}
+	require.NoError(t, writer.Write(NewFieldsRow(fields, map[string]interface{}{"src": map[string]interface{}{"name": "carts"}})))
+	require.NoError(t, writer.Write(NewFieldsRow(fields, map[string]interface{}{"response": map[string]interface{}{"status": 200}})))
+	require.NoError(t, writer.Flush())
+
+	file, err := parquetbuffer.NewBufferFile(buffer.Bytes())
+	require.NoError(t, err)
+	parquetReader, err := reader.NewParquetReader(file, nil, 1)
+	require.NoError(t, err)
+	defer parquetReader.ReadStop()
+
+	assert.Equal(t, int64(2), parquetReader.GetNumRows())
+
+	columns := make([]string, 0)
+	for i := 1; i < len(parquetReader.SchemaHandler.Infos); i++ {
+		columns = append(columns, parquetReader.SchemaHandler.GetExName(i))
+	}
+	assert.Equal(t, fields, columns)
+}
+
+func TestParquetWriterWithoutRows(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	writer, err := NewWriter(FormatParquet, buffer)
+	require.NoError(t, err)
+
+	require.NoError(t, writer.Flush())
+	assert.Empty(t, buffer.Bytes())
+}
+
+func TestParquetWriterInfersColumnTypes(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	writer, err := NewWriter(FormatParquet, buffer)
+	require.NoError(t, err)
+
+	fields := []string{"timestamp", "elapsedTime", "latency", "outgoing", "method", "request"}
+	require.NoError(t, writer.Write(&FieldsRow{Fields: fields, Values: []interface{}{json.Number("1641031200000"), json.Number("2"), json.Number("200"), true, "GET", map[string]interface{}{"a": 1}}}))
+	require.NoError(t, writer.Write(&FieldsRow{Fields: fields, Values: []interface{}{json.Number("1641031200001"), json.Number("1.5"), json.Number("0.5"), nil, nil, nil}}))
+	require.NoError(t, writer.Flush())
+
+	file, err := parquetbuffer.NewBufferFile(buffer.Bytes())
+	require.NoError(t, err)
+	parquetReader, err := reader.NewParquetReader(file, nil, 1)
+	require.NoError(t, err)
+	defer parquetReader.ReadStop()
+
+	types := make([]parquet.Type, 0)
+	for _, element := range parquetReader.SchemaHandler.SchemaElements[1:] {
+		types = append(types, element.GetType())
+	}
+	assert.Equal(t, []parquet.Type{parquet.Type_INT64, parquet.Type_DOUBLE, parquet.Type_DOUBLE, parquet.Type_BOOLEAN, parquet.Type_BYTE_ARRAY, parquet.Type_BYTE_ARRAY}, types)
+
+	// the fractional values after the whole ones fit their columns
+	expected := [][]interface{}{
+		{int64(1641031200000), int64(1641031200001)},
+		{2.0, 1.5},
+		{200.0, 0.5},
+		{true, nil},
+		{"GET", ""},
+		{`{"a":1}`, ""},
+	}
+	for i, path := range parquetReader.SchemaHandler.ValueColumns {
+		values, _, _, err := parquetReader.ReadColumnByPath(path, 2)
+		require.NoError(t, err)
+		assert.Equal(t, expected[i], values, fields[i])
+	}
+}
+
+func TestParquetWriterReportsMismatchedValues(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	writer, err := NewWriter(FormatParquet, buffer)
+	require.NoError(t, err)
+
+	fields := []string{"response.status", "outgoing"}
+	require.NoError(t, writer.Write(&FieldsRow{Fields: fields, Values: []interface{}{json.Number("200"), true}}))
+	require.NoError(t, writer.Write(&FieldsRow{Fields: fields, Values: []interface{}{"OK", "yes"}}))
+	require.NoError(t, writer.Write(&FieldsRow{Fields: fields, Values: []interface{}{nil, nil}}))
+	assert.EqualError(t, writer.Flush(), "2 values do not fit the types of their columns and are written as nulls, the first is OK of response.status")
+
+	// the file is still written
+	file, err := parquetbuffer.NewBufferFile(buffer.Bytes())
+	require.NoError(t, err)
+	parquetReader, err := reader.NewParquetReader(file, nil, 1)
+	require.NoError(t, err)
+	defer parquetReader.ReadStop()
+	assert.Equal(t, int64(3), parquetReader.GetNumRows())
+}
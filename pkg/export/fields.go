@@ -0,0 +1,94 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+
+// FieldsRow is a row of the values of a chosen list of fields, in the order of the fields.
+type FieldsRow struct {
+	Fields []string
+	Values []interface{}
+}
+
+// NewFieldsRow picks the fields out of a decoded JSON object, the fields being dotted paths such as src.name.
+// A field that is missing from the object has a nil value.
+func NewFieldsRow(fields []string, object map[string]interface{}) *FieldsRow {
+	values := make([]interface{}, len(fields))
+	for i, field := range fields {
+		values[i] = GetField(object, field)
+	}
+
+	return &FieldsRow{Fields: fields, Values: values}
+}
+
+// GetField returns the value of a dotted path in a decoded JSON object or nil if there is none.
+func GetField(object map[string]interface{}, field string) interface{} {
+	var value interface{} = object
+	for _, key := range strings.Split(field, ".") {
+		current, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		if value, ok = current[key]; !ok {
+			return nil
+		}
+	}
+
+	return value
+}
+
+func (r *FieldsRow) RowValues() []interface{} {
+	return r.Values
+}
+
+func (r *FieldsRow) CSVHeader() []string {
+	return r.Fields
+}
+
+// CSVRecord writes the strings as they are, the missing values as empty strings and the other values as JSON.
+func (r *FieldsRow) CSVRecord() []string {
+	record := make([]string, len(r.Values))
+	for i, value := range r.Values {
+		switch value := value.(type) {
+		case nil:
+		case string:
+			record[i] = value
+		default:
+			data, err := json.Marshal(value)
+			if err == nil {
+				record[i] = string(data)
+			}
+		}
+	}
+
+	return record
+}
+
+// MarshalJSON writes the row as an object that keeps the order of the fields.
+func (r *FieldsRow) MarshalJSON() ([]byte, error) {
+	buffer := &bytes.Buffer{}
+	buffer.WriteByte('{')
+	for i, field := range r.Fields {
+		if i > 0 {
+			buffer.WriteByte(',')
+		}
+
+		key, err := json.Marshal(field)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(r.Values[i])
+		if err != nil {
+			return nil, err
+		}
+
+		buffer.Write(key)
+		buffer.WriteByte(':')
+		buffer.Write(value)
+	}
+	buffer.WriteByte('}')
+
+	return buffer.Bytes(), nil
+}
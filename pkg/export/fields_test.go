@@ -0,0 +1,29 @@
+package export
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFieldsRow(t *testing.T) {
+	var object map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"src": {"name": "carts", "port": "8080"},
+		"request": {"path": "/items", "headers": {"Accept": "*/*"}},
+		"response": {"status": 200},
+		"elapsedTime": 42
+	}`), &object))
+
+	fields := []string{"src.name", "request.path", "response.status", "elapsedTime", "request.headers", "dst.name", "src.name.first"}
+	row := NewFieldsRow(fields, object)
+
+	assert.Equal(t, fields, row.CSVHeader())
+	assert.Equal(t, []string{"carts", "/items", "200", "42", `{"Accept":"*/*"}`, "", ""}, row.CSVRecord())
+
+	data, err := json.Marshal(row)
+	require.NoError(t, err)
+	assert.Equal(t, `{"src.name":"carts","request.path":"/items","response.status":200,"elapsedTime":42,"request.headers":{"Accept":"*/*"},"dst.name":null,"src.name.first":null}`, string(data))
+}
@@ -0,0 +1,194 @@
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+// parquetRowGroupSize bounds the rows buffered before a row group is written out.
+const parquetRowGroupSize = 8 * 1024 * 1024
+
+const (
+	parquetTypeInt64   = "INT64"
+	parquetTypeDouble  = "DOUBLE"
+	parquetTypeBoolean = "BOOLEAN"
+	parquetTypeString  = "BYTE_ARRAY"
+)
+
+// parquetFieldTypes are the types of the numeric fields of the entries, which are known before any row is seen.
+var parquetFieldTypes = map[string]string{
+	"timestamp":       parquetTypeInt64,
+	"requestSize":     parquetTypeInt64,
+	"responseSize":    parquetTypeInt64,
+	"response.status": parquetTypeInt64,
+	"elapsedTime":     parquetTypeDouble,
+}
+
+// ValuesRow is a row that keeps the JSON values of its fields, so they can be written as typed columns.
+type ValuesRow interface {
+	Row
+	RowValues() []interface{}
+}
+
+// parquetWriter writes every column as an optional value, its schema is the header of the first row.
+// The columns of a ValuesRow have the types of the known fields of the entries, see parquetFieldTypes, and the other
+// columns are typed after the values of the first row, as DOUBLE (for any number), BOOLEAN or UTF8 strings, the
+// missing values and the objects being strings. A later value that does not fit the type of its column is written
+// as null and reported by Flush. Nothing is written when there are no rows, as there is no schema to write.
+type parquetWriter struct {
+	w      io.Writer
+	writer *writer.CSVWriter
+	types  []string
+	// mismatches counts the values written as nulls, mismatch is the first of them.
+	mismatches int
+	mismatch   string
+}
+
+func (w *parquetWriter) start(row Row) error {
+	header := row.CSVHeader()
+	w.types = make([]string, len(header))
+	for i := range w.types {
+		w.types[i] = parquetTypeString
+	}
+	if valuesRow, ok := row.(ValuesRow); ok {
+		for i, value := range valuesRow.RowValues() {
+			if parquetType, ok := parquetFieldTypes[header[i]]; ok {
+				w.types[i] = parquetType
+			} else {
+				w.types[i] = getParquetType(value)
+			}
+		}
+	}
+
+	schema := make([]string, 0, len(header))
+	for i, name := range header {
+		if w.types[i] == parquetTypeString {
+			schema = append(schema, fmt.Sprintf("name=%s, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=OPTIONAL", name))
+		} else {
+			schema = append(schema, fmt.Sprintf("name=%s, type=%s, repetitiontype=OPTIONAL", name, w.types[i]))
+		}
+	}
+
+	csvWriter, err := writer.NewCSVWriterFromWriter(schema, w.w, 1)
+	if err != nil {
+		return err
+	}
+	csvWriter.RowGroupSize = parquetRowGroupSize
+	csvWriter.CompressionType = parquet.CompressionCodec_SNAPPY
+
+	w.writer = csvWriter
+	return nil
+}
+
+// getParquetType types a column after its first value, a number being a DOUBLE as the later ones may be fractional.
+func getParquetType(value interface{}) string {
+	switch value := value.(type) {
+	case bool:
+		return parquetTypeBoolean
+	case int, int64, float64:
+		return parquetTypeDouble
+	case json.Number:
+		if _, err := value.Float64(); err == nil {
+			return parquetTypeDouble
+		}
+	}
+
+	return parquetTypeString
+}
+
+// toParquetValue converts a JSON value to the type of its column, or nil if it does not fit.
+func toParquetValue(value interface{}, parquetType string) interface{} {
+	switch parquetType {
+	case parquetTypeBoolean:
+		if value, ok := value.(bool); ok {
+			return value
+		}
+	case parquetTypeInt64:
+		switch value := value.(type) {
+		case int:
+			return int64(value)
+		case int64:
+			return value
+		case float64:
+			if value == math.Trunc(value) && math.Abs(value) < math.MaxInt64 {
+				return int64(value)
+			}
+		case json.Number:
+			if value, err := value.Int64(); err == nil {
+				return value
+			}
+		}
+	case parquetTypeDouble:
+		switch value := value.(type) {
+		case int:
+			return float64(value)
+		case int64:
+			return float64(value)
+		case float64:
+			return value
+		case json.Number:
+			if value, err := value.Float64(); err == nil {
+				return value
+			}
+		}
+	}
+
+	return nil
+}
+
+func (w *parquetWriter) Write(row Row) error {
+	if w.writer == nil {
+		if err := w.start(row); err != nil {
+			return err
+		}
+	}
+
+	var values []interface{}
+	if valuesRow, ok := row.(ValuesRow); ok {
+		values = valuesRow.RowValues()
+	}
+
+	header := row.CSVHeader()
+	record := row.CSVRecord()
+	parquetValues := make([]interface{}, len(record))
+	for i := range record {
+		if w.types[i] == parquetTypeString {
+			parquetValues[i] = record[i]
+		} else if i < len(values) {
+			parquetValues[i] = toParquetValue(values[i], w.types[i])
+			if parquetValues[i] == nil && values[i] != nil {
+				if w.mismatches == 0 {
+					w.mismatch = fmt.Sprintf("%v of %s", values[i], header[i])
+				}
+				w.mismatches++
+			}
+		}
+	}
+
+	return w.writer.Write(parquetValues)
+}
+
+// Flush writes out the file, then fails if any value did not fit the type of its column, as it is written as null.
+func (w *parquetWriter) Flush() error {
+	if w.writer == nil {
+		return nil
+	}
+
+	if err := w.writer.WriteStop(); err != nil {
+		return err
+	}
+	if w.mismatches > 0 {
+		return fmt.Errorf("%d values do not fit the types of their columns and are written as nulls, the first is %s", w.mismatches, w.mismatch)
+	}
+
+	return nil
+}
+
+func (w *parquetWriter) ContentType() string {
+	return "application/vnd.apache.parquet"
+}
@@ -0,0 +1,236 @@
+package har
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	baseApi "github.com/kubeshark/base/pkg/api"
+	kubesharkhttp "github.com/kubeshark/base/pkg/extensions/http"
+)
+
+const (
+	// Capture marks the entries imported from HAR files.
+	Capture baseApi.Capture = "har"
+	// ImportedSourceName is the source of the imported entries, as HAR does not record the client.
+	ImportedSourceName = "har-import"
+)
+
+func getBody(text string, encoding string) []byte {
+	if encoding == "base64" {
+		_, decoded, _ := b64Decoded(encoding, text)
+		if decoded != nil {
+			return decoded
+		}
+	}
+	return []byte(text)
+}
+
+func getPostBody(postData *PostData) []byte {
+	if postData.Text != "" || len(postData.Params) == 0 {
+		return []byte(postData.Text)
+	}
+
+	values := url.Values{}
+	for _, param := range postData.Params {
+		values.Add(param.Name, param.Value)
+	}
+	return []byte(values.Encode())
+}
+
+func setProto(version string, setter func(proto string, major int, minor int)) {
+	proto := strings.ToUpper(version)
+	if proto == "H2" || proto == "HTTP/2" {
+		proto = "HTTP/2.0"
+	}
+	if major, minor, ok := http.ParseHTTPVersion(proto); ok {
+		setter(proto, major, minor)
+		return
+	}
+	setter("HTTP/1.1", 1, 1)
+}
+
+func newHttpRequest(request *Request) (*http.Request, error) {
+	u, err := url.Parse(request.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid url: %v", err)
+	}
+
+	body := getPostBody(&request.PostData)
+	req, err := http.NewRequest(request.Method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, header := range request.Headers {
+		switch {
+		case strings.HasPrefix(header.Name, ":"):
+			if header.Name == ":authority" && req.Host == "" {
+				req.Host = header.Value
+			}
+		case strings.EqualFold(header.Name, "Host"):
+			req.Host = header.Value
+		default:
+			req.Header.Add(header.Name, header.Value)
+		}
+	}
+	if req.Host == "" {
+		req.Host = u.Host
+	}
+	if request.PostData.MimeType != "" && req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", request.PostData.MimeType)
+	}
+
+	setProto(request.HTTPVersion, func(proto string, major int, minor int) {
+		req.Proto, req.ProtoMajor, req.ProtoMinor = proto, major, minor
+	})
+
+	// the captured requests have the path in their URL and the host in their header
+	req.URL = &url.URL{Path: u.Path, RawPath: u.RawPath, RawQuery: u.RawQuery}
+	if req.URL.Path == "" {
+		req.URL.Path = "/"
+	}
+
+	return req, nil
+}
+
+func newHttpResponse(response *Response, req *http.Request) *http.Response {
+	body := getBody(response.Content.Text, response.Content.Encoding)
+
+	res := &http.Response{
+		StatusCode:    response.Status,
+		Status:        fmt.Sprintf("%d %s", response.Status, response.StatusText),
+		Header:        http.Header{},
+		Body:          &readCloser{Reader: bytes.NewReader(body)},
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+
+	for _, header := range response.Headers {
+		// the content of HAR is already decoded and its length is the one of the decoded content
+		if strings.HasPrefix(header.Name, ":") || strings.EqualFold(header.Name, "Content-Encoding") || strings.EqualFold(header.Name, "Content-Length") {
+			continue
+		}
+		res.Header.Add(header.Name, header.Value)
+	}
+	if response.Content.MimeType != "" && res.Header.Get("Content-Type") == "" {
+		res.Header.Set("Content-Type", response.Content.MimeType)
+	}
+
+	setProto(response.HTTPVersion, func(proto string, major int, minor int) {
+		res.Proto, res.ProtoMajor, res.ProtoMinor = proto, major, minor
+	})
+
+	return res
+}
+
+type readCloser struct {
+	*bytes.Reader
+}
+
+func (r *readCloser) Close() error {
+	return nil
+}
+
+func getServerPort(rawUrl string) string {
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return ""
+	}
+	if port := u.Port(); port != "" {
+		return port
+	}
+	if u.Scheme == "https" {
+		return "443"
+	}
+	return "80"
+}
+
+// NewOutputChannelItem turns a HAR entry into the item the HTTP dissector analyzes, as if it was captured.
+// The server IP of the entry becomes the destination IP of the item.
+func NewOutputChannelItem(entry *Entry, protocol *baseApi.Protocol) (*baseApi.OutputChannelItem, error) {
+	startTime, err := time.Parse(time.RFC3339Nano, entry.StartedDateTime)
+	if err != nil {
+		return nil, fmt.Errorf("invalid start time: %v", err)
+	}
+
+	req, err := newHttpRequest(&entry.Request)
+	if err != nil {
+		return nil, err
+	}
+	res := newHttpResponse(&entry.Response, req)
+
+	serverIP := strings.Trim(entry.ServerIPAddress, "[]")
+	if net.ParseIP(serverIP) == nil {
+		serverIP = ""
+	}
+
+	item := baseApi.OutputChannelItem{
+		Protocol: *protocol,
+		Capture:  Capture,
+		ConnectionInfo: &baseApi.ConnectionInfo{
+			ServerIP:   serverIP,
+			ServerPort: getServerPort(entry.Request.URL),
+		},
+		Timestamp: startTime.UnixMilli(),
+		Pair: &baseApi.RequestResponsePair{
+			Request: baseApi.GenericMessage{
+				IsRequest:   true,
+				CaptureTime: startTime,
+				CaptureSize: int(req.ContentLength),
+				Payload: &kubesharkhttp.HTTPPayload{
+					Type: kubesharkhttp.TypeHttpRequest,
+					Data: req,
+				},
+			},
+			Response: baseApi.GenericMessage{
+				IsRequest:   false,
+				CaptureTime: startTime.Add(time.Duration(entry.Time) * time.Millisecond),
+				CaptureSize: int(res.ContentLength),
+				Payload: &kubesharkhttp.HTTPPayload{
+					Type: kubesharkhttp.TypeHttpResponse,
+					Data: res,
+				},
+			},
+		},
+	}
+
+	// the dissectors analyze the items as they arrive from the workers, marshalled and unmarshalled
+	itemMarshalled, err := json.Marshal(item)
+	if err != nil {
+		return nil, err
+	}
+	var finalItem *baseApi.OutputChannelItem
+	if err := json.Unmarshal(itemMarshalled, &finalItem); err != nil {
+		return nil, err
+	}
+
+	return finalItem, nil
+}
+
+// SetImportedPeerNames names the unresolved peers of an analyzed imported entry,
+// the destination after the Host header of the request.
+func SetImportedPeerNames(entry *baseApi.Entry) {
+	if entry.Source != nil && entry.Source.Name == "" {
+		entry.Source.Name = ImportedSourceName
+	}
+
+	if entry.Destination == nil || entry.Destination.Name != "" {
+		return
+	}
+
+	headers, _ := entry.Request["headers"].(map[string]interface{})
+	host, _ := headers["Host"].(string)
+	if host == "" {
+		host, _ = headers[":authority"].(string)
+	}
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		host = hostname
+	}
+	entry.Destination.Name = host
+}
@@ -0,0 +1,73 @@
+package har
+
+import (
+	"testing"
+
+	baseApi "github.com/kubeshark/base/pkg/api"
+	kubesharkhttp "github.com/kubeshark/base/pkg/extensions/http"
+)
+
+func TestNewOutputChannelItem(t *testing.T) {
+	extension := &baseApi.Extension{}
+	dissector := kubesharkhttp.NewDissector()
+	dissector.Register(extension)
+
+	harEntry := &Entry{
+		StartedDateTime: "2022-01-01T10:00:00.000Z",
+		Time:            42,
+		ServerIPAddress: "10.0.0.2",
+		Request: Request{
+			Method:      "POST",
+			URL:         "http://carts.shop:8080/items?id=1",
+			HTTPVersion: "HTTP/1.1",
+			Headers:     []NVP{{Name: "Host", Value: "carts.shop:8080"}, {Name: "Accept", Value: "*/*"}},
+			PostData:    PostData{MimeType: "application/json", Text: `{"count":1}`},
+		},
+		Response: Response{
+			Status:      201,
+			StatusText:  "Created",
+			HTTPVersion: "HTTP/1.1",
+			Headers:     []NVP{{Name: "Content-Type", Value: "application/json"}, {Name: "Content-Encoding", Value: "gzip"}},
+			Content:     Content{MimeType: "application/json", Text: "eyJvayI6dHJ1ZX0=", Encoding: "base64"},
+		},
+	}
+
+	item, err := NewOutputChannelItem(harEntry, extension.Protocol)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	entry := dissector.Analyze(item, "", "", "")
+	SetImportedPeerNames(entry)
+	summary := dissector.Summarize(entry)
+
+	if entry.Capture != Capture || entry.Timestamp != 1641031200000 || entry.ElapsedTime != 42 {
+		t.Errorf("unexpected result - expected: %v, actual: %v %v %v", "har capture at 10:00 taking 42ms", entry.Capture, entry.Timestamp, entry.ElapsedTime)
+	}
+	if summary.Method != "POST" || summary.Summary != "/items" || summary.Status != 201 {
+		t.Errorf("unexpected result - expected: %v, actual: %v %v %v", "POST /items 201", summary.Method, summary.Summary, summary.Status)
+	}
+	if entry.Source.Name != ImportedSourceName || entry.Destination.Name != "carts.shop" || entry.Destination.IP != "10.0.0.2" || entry.Destination.Port != "8080" {
+		t.Errorf("unexpected result - expected: %v, actual: %v %v", "har-import to carts.shop at 10.0.0.2:8080", entry.Source, entry.Destination)
+	}
+
+	exported, err := NewEntry(entry.Request, entry.Response, entry.StartTime, entry.ElapsedTime)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if exported.Request.URL != "http://carts.shop:8080/items?id=1" || exported.Request.PostData.Text != `{"count":1}` {
+		t.Errorf("unexpected result - expected: %v, actual: %v %v", "the same request", exported.Request.URL, exported.Request.PostData.Text)
+	}
+	if _, body, _ := exported.Response.Content.B64Decoded(); string(body) != `{"ok":true}` {
+		t.Errorf("unexpected result - expected: %v, actual: %v", `{"ok":true}`, string(body))
+	}
+}
+
+func TestNewOutputChannelItemInvalid(t *testing.T) {
+	extension := &baseApi.Extension{}
+	kubesharkhttp.NewDissector().Register(extension)
+
+	if _, err := NewOutputChannelItem(&Entry{StartedDateTime: "yesterday"}, extension.Protocol); err == nil {
+		t.Errorf("unexpected result - expected: an error for an invalid start time, actual: nil")
+	}
+}
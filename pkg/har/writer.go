@@ -0,0 +1,149 @@
+package har
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+const Version = "1.2"
+
+// EntryMetadata is the custom _kubeshark field of the exported entries, the fields HAR has no place for.
+type EntryMetadata struct {
+	Id          string `json:"id,omitempty"`
+	Source      string `json:"src,omitempty"`
+	Destination string `json:"dst,omitempty"`
+	Namespace   string `json:"namespace,omitempty"`
+}
+
+type ExportedEntry struct {
+	Entry
+	Kubeshark *EntryMetadata `json:"_kubeshark,omitempty"`
+}
+
+// LogMetadata is the custom _kubeshark field of the exported log, it counts the skipped entries by protocol.
+// An export that failed midway is truncated, with the error it failed on.
+type LogMetadata struct {
+	EntriesCount int            `json:"entriesCount"`
+	Skipped      map[string]int `json:"skipped"`
+	Truncated    bool           `json:"truncated"`
+	Error        string         `json:"error,omitempty"`
+}
+
+// Writer streams a HAR document entry by entry, so an export is never held in memory as a whole.
+type Writer struct {
+	writer       *bufio.Writer
+	creator      Creator
+	entriesCount int
+	skipped      map[string]int
+	err          error
+	started      bool
+}
+
+func NewWriter(w io.Writer, creator Creator) *Writer {
+	return &Writer{
+		writer:  bufio.NewWriter(w),
+		creator: creator,
+		skipped: make(map[string]int),
+	}
+}
+
+func (w *Writer) start() error {
+	if w.started {
+		return nil
+	}
+	w.started = true
+
+	creator, err := json.Marshal(w.creator)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w.writer, `{"log":{"version":"%s","creator":%s,"browser":{"name":"","version":"","comment":""},"entries":[`, Version, creator)
+	return err
+}
+
+func (w *Writer) WriteEntry(entry *ExportedEntry) error {
+	if err := w.start(); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	if w.entriesCount > 0 {
+		if err := w.writer.WriteByte(','); err != nil {
+			return err
+		}
+	}
+	if _, err := w.writer.Write(data); err != nil {
+		return err
+	}
+	w.entriesCount++
+
+	return nil
+}
+
+// Skip counts an entry of a protocol HAR cannot represent.
+func (w *Writer) Skip(protocol string) {
+	w.skipped[protocol]++
+}
+
+// Fail records the error an export failed on, the document is closed as truncated.
+func (w *Writer) Fail(err error) {
+	w.err = err
+}
+
+// Close ends the document with the summary of the skipped entries and flushes it.
+func (w *Writer) Close() error {
+	if err := w.start(); err != nil {
+		return err
+	}
+
+	comment, err := json.Marshal(w.getComment())
+	if err != nil {
+		return err
+	}
+	logMetadata := &LogMetadata{EntriesCount: w.entriesCount, Skipped: w.skipped}
+	if w.err != nil {
+		logMetadata.Truncated = true
+		logMetadata.Error = w.err.Error()
+	}
+	metadata, err := json.Marshal(logMetadata)
+	if err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(w.writer, `],"comment":%s,"_kubeshark":%s}}`, comment, metadata); err != nil {
+		return err
+	}
+
+	return w.writer.Flush()
+}
+
+func (w *Writer) getComment() string {
+	if w.err != nil {
+		return fmt.Sprintf("Truncated after %d entries, the export failed: %v", w.entriesCount, w.err)
+	}
+	if len(w.skipped) == 0 {
+		return fmt.Sprintf("%d entries exported.", w.entriesCount)
+	}
+
+	protocols := make([]string, 0, len(w.skipped))
+	for protocol := range w.skipped {
+		protocols = append(protocols, protocol)
+	}
+	sort.Strings(protocols)
+
+	skipped := make([]string, 0, len(protocols))
+	for _, protocol := range protocols {
+		skipped = append(skipped, fmt.Sprintf("%d %s", w.skipped[protocol], protocol))
+	}
+
+	return fmt.Sprintf("%d entries exported, skipped the entries of the protocols HAR cannot represent: %s.", w.entriesCount, strings.Join(skipped, ", "))
+}
@@ -0,0 +1,104 @@
+package har
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestWriter(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	w := NewWriter(buffer, Creator{Name: "kubeshark", Version: "1.0"})
+
+	for _, url := range []string{"http://carts/items", "http://orders/"} {
+		entry := &ExportedEntry{
+			Entry:     Entry{Request: Request{Method: "GET", URL: url}},
+			Kubeshark: &EntryMetadata{Id: "1", Namespace: "shop"},
+		}
+		if err := w.WriteEntry(entry); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	w.Skip("AMQP")
+	w.Skip("AMQP")
+	w.Skip("REDIS")
+
+	if err := w.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var document struct {
+		HAR
+		Log struct {
+			Log
+			Kubeshark LogMetadata `json:"_kubeshark"`
+		} `json:"log"`
+	}
+	if err := json.Unmarshal(buffer.Bytes(), &document); err != nil {
+		t.Fatalf("invalid document: %v, %s", err, buffer.String())
+	}
+
+	if document.Log.Version != Version || document.Log.Creator.Name != "kubeshark" {
+		t.Errorf("unexpected result - expected: %v, actual: %v %v", "version and creator", document.Log.Version, document.Log.Creator)
+	}
+	if len(document.Log.Entries) != 2 || document.Log.Entries[1].Request.URL != "http://orders/" {
+		t.Errorf("unexpected result - expected: %v, actual: %v", 2, document.Log.Entries)
+	}
+	if document.Log.Kubeshark.EntriesCount != 2 || document.Log.Kubeshark.Skipped["AMQP"] != 2 || document.Log.Kubeshark.Skipped["REDIS"] != 1 {
+		t.Errorf("unexpected result - expected: %v, actual: %v", "2 AMQP and 1 REDIS skipped", document.Log.Kubeshark)
+	}
+
+	expectedComment := "2 entries exported, skipped the entries of the protocols HAR cannot represent: 2 AMQP, 1 REDIS."
+	if document.Log.Comment != expectedComment {
+		t.Errorf("unexpected result - expected: %v, actual: %v", expectedComment, document.Log.Comment)
+	}
+}
+
+func TestWriterWithoutEntries(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	if err := NewWriter(buffer, Creator{}).Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var document HAR
+	if err := json.Unmarshal(buffer.Bytes(), &document); err != nil {
+		t.Fatalf("invalid document: %v, %s", err, buffer.String())
+	}
+	if len(document.Log.Entries) != 0 {
+		t.Errorf("unexpected result - expected: %v, actual: %v", 0, len(document.Log.Entries))
+	}
+}
+
+func TestWriterFailed(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	w := NewWriter(buffer, Creator{})
+	if err := w.WriteEntry(&ExportedEntry{Entry: Entry{Request: Request{Method: "GET", URL: "http://carts/items"}}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	w.Fail(errors.New("connection reset"))
+	if err := w.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var document struct {
+		Log struct {
+			Log
+			Kubeshark LogMetadata `json:"_kubeshark"`
+		} `json:"log"`
+	}
+	if err := json.Unmarshal(buffer.Bytes(), &document); err != nil {
+		t.Fatalf("invalid document: %v, %s", err, buffer.String())
+	}
+
+	expected := LogMetadata{EntriesCount: 1, Skipped: map[string]int{}, Truncated: true, Error: "connection reset"}
+	if !reflect.DeepEqual(document.Log.Kubeshark, expected) {
+		t.Errorf("unexpected result - expected: %v, actual: %v", expected, document.Log.Kubeshark)
+	}
+
+	expectedComment := "Truncated after 1 entries, the export failed: connection reset"
+	if document.Log.Comment != expectedComment {
+		t.Errorf("unexpected result - expected: %v, actual: %v", expectedComment, document.Log.Comment)
+	}
+}
@@ -0,0 +1,86 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+	TypeCounter = "counter"
+	TypeGauge   = "gauge"
+)
+
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Writer writes the metrics in the Prometheus text exposition format.
+// The samples of a family must be written right after the family itself.
+type Writer struct {
+	buffer bytes.Buffer
+}
+
+func NewWriter() *Writer {
+	return &Writer{}
+}
+
+func (w *Writer) Family(name string, help string, metricType string) {
+	w.buffer.WriteString(fmt.Sprintf("# HELP %s %s\n", name, escapeHelp(help)))
+	w.buffer.WriteString(fmt.Sprintf("# TYPE %s %s\n", name, metricType))
+}
+
+func (w *Writer) Sample(name string, value float64, labels ...Label) {
+	w.buffer.WriteString(name)
+
+	if len(labels) > 0 {
+		w.buffer.WriteByte('{')
+		for i, label := range labels {
+			if i > 0 {
+				w.buffer.WriteByte(',')
+			}
+			w.buffer.WriteString(fmt.Sprintf("%s=\"%s\"", label.Name, escapeLabelValue(label.Value)))
+		}
+		w.buffer.WriteByte('}')
+	}
+
+	w.buffer.WriteByte(' ')
+	w.buffer.WriteString(formatValue(value))
+	w.buffer.WriteByte('\n')
+}
+
+// Single writes a family with a single sample without labels.
+func (w *Writer) Single(name string, help string, metricType string, value float64) {
+	w.Family(name, help, metricType)
+	w.Sample(name, value)
+}
+
+func (w *Writer) Bytes() []byte {
+	return w.buffer.Bytes()
+}
+
+func escapeHelp(help string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(help)
+}
+
+func escapeLabelValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`).Replace(value)
+}
+
+func formatValue(value float64) string {
+	switch {
+	case math.IsNaN(value):
+		return "NaN"
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	default:
+		return strconv.FormatFloat(value, 'g', -1, 64)
+	}
+}
@@ -0,0 +1,45 @@
+package metrics
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriter(t *testing.T) {
+	w := NewWriter()
+	w.Family("entries_total", "Entries\nwith a \\ in help.", TypeCounter)
+	w.Sample("entries_total", 3, Label{Name: "protocol", Value: "HTTP"}, Label{Name: "method", Value: "GET \"x\"\n"})
+	w.Sample("entries_total", 1e10)
+	w.Single("connected", "Connected.", TypeGauge, 2)
+
+	expected := `# HELP entries_total Entries\nwith a \\ in help.
+# TYPE entries_total counter
+entries_total{protocol="HTTP",method="GET \"x\"\n"} 3
+entries_total 1e+10
+# HELP connected Connected.
+# TYPE connected gauge
+connected 2
+`
+	assert.Equal(t, expected, string(w.Bytes()))
+}
+
+func TestFormatValue(t *testing.T) {
+	tests := map[string]struct {
+		value    float64
+		expected string
+	}{
+		"integer":  {value: 42, expected: "42"},
+		"fraction": {value: 0.25, expected: "0.25"},
+		"nan":      {value: math.NaN(), expected: "NaN"},
+		"inf":      {value: math.Inf(1), expected: "+Inf"},
+		"-inf":     {value: math.Inf(-1), expected: "-Inf"},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.expected, formatValue(test.value))
+		})
+	}
+}
@@ -0,0 +1,356 @@
+package networkpolicy
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+
+	"github.com/kubeshark/hub/pkg/servicemap"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	k8syaml "k8s.io/apimachinery/pkg/util/yaml"
+)
+
+const (
+	VerdictAllowed    = "allowed"
+	VerdictBlocked    = "blocked"
+	VerdictUnselected = "unselected" // no policy selects the destination, so every traffic is allowed
+	VerdictUnknown    = "unknown"    // the destination pods are unknown
+
+	yamlBufferSize = 4096
+)
+
+type DryRunFlow struct {
+	Source      string   `json:"source"`
+	Destination string   `json:"destination"`
+	Protocol    string   `json:"protocol"`
+	Port        string   `json:"port"`
+	Count       int      `json:"count"`
+	Verdict     string   `json:"verdict"`
+	Policies    []string `json:"policies"`
+}
+
+type DryRunSummary struct {
+	Policies   int `json:"policies"`
+	Flows      int `json:"flows"`
+	Allowed    int `json:"allowed"`
+	Blocked    int `json:"blocked"`
+	Unselected int `json:"unselected"`
+	Unknown    int `json:"unknown"`
+}
+
+type DryRunReport struct {
+	Summary DryRunSummary `json:"summary"`
+	Flows   []DryRunFlow  `json:"flows"`
+}
+
+// ParsePolicies reads the network policies from a multi-document YAML or JSON, lists are flattened.
+// Documents of other kinds are ignored.
+func ParsePolicies(reader io.Reader) ([]networkingv1.NetworkPolicy, error) {
+	decoder := k8syaml.NewYAMLOrJSONDecoder(reader, yamlBufferSize)
+
+	policies := make([]networkingv1.NetworkPolicy, 0)
+	for {
+		var document json.RawMessage
+		if err := decoder.Decode(&document); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, err
+		}
+		if len(bytes.TrimSpace(document)) == 0 || string(document) == "null" {
+			continue
+		}
+
+		var typeMeta struct {
+			metav1.TypeMeta `json:",inline"`
+			Items           []json.RawMessage `json:"items"`
+		}
+		if err := json.Unmarshal(document, &typeMeta); err != nil {
+			return nil, err
+		}
+
+		items := []json.RawMessage{document}
+		if typeMeta.Kind == "List" || typeMeta.Kind == "NetworkPolicyList" {
+			items = typeMeta.Items
+		}
+
+		for _, item := range items {
+			var policy networkingv1.NetworkPolicy
+			if err := json.Unmarshal(item, &policy); err != nil {
+				return nil, err
+			}
+			if policy.Kind != "NetworkPolicy" {
+				continue
+			}
+			if policy.Namespace == "" {
+				policy.Namespace = corev1.NamespaceDefault
+			}
+			policies = append(policies, policy)
+		}
+	}
+
+	return policies, nil
+}
+
+// DryRun evaluates the observed edges against the given policies and reports the ones that would have been blocked,
+// with a flow per destination port of an edge. The namespaces are expected to carry the kubernetes.io/metadata.name
+// label and nothing else.
+func DryRun(edges []servicemap.ServiceMapEdge, workloadResolver WorkloadResolver, policies []networkingv1.NetworkPolicy) (*DryRunReport, error) {
+	report := &DryRunReport{
+		Summary: DryRunSummary{Policies: len(policies)},
+		Flows:   make([]DryRunFlow, 0, len(edges)),
+	}
+
+	for _, edge := range edges {
+		ports := edge.Ports
+		if len(ports) == 0 {
+			ports = []servicemap.ServiceMapEdgePort{{Count: edge.Count}}
+		}
+
+		for _, port := range ports {
+			flow := DryRunFlow{
+				Source:      edge.Source.Name,
+				Destination: edge.Destination.Name,
+				Port:        port.Port,
+				Count:       port.Count,
+				Policies:    make([]string, 0),
+			}
+			if edge.Protocol != nil {
+				flow.Protocol = edge.Protocol.Name
+			}
+
+			verdict, err := evaluate(edge, port.Port, workloadResolver, policies, &flow)
+			if err != nil {
+				return nil, err
+			}
+			flow.Verdict = verdict
+
+			switch verdict {
+			case VerdictAllowed:
+				report.Summary.Allowed++
+			case VerdictBlocked:
+				report.Summary.Blocked++
+			case VerdictUnselected:
+				report.Summary.Unselected++
+			default:
+				report.Summary.Unknown++
+			}
+
+			report.Flows = append(report.Flows, flow)
+		}
+	}
+
+	report.Summary.Flows = len(report.Flows)
+
+	return report, nil
+}
+
+// evaluate returns the verdict of the traffic of an edge to a port and lists the policies allowing it in the flow.
+func evaluate(edge servicemap.ServiceMapEdge, port string, workloadResolver WorkloadResolver, policies []networkingv1.NetworkPolicy, flow *DryRunFlow) (string, error) {
+	if !edge.Destination.Resolved {
+		return VerdictUnknown, nil
+	}
+	destination, ok := workloadResolver.Resolve(edge.Destination.Name)
+	if !ok {
+		return VerdictUnknown, nil
+	}
+
+	selected := false
+	for _, policy := range policies {
+		if policy.Namespace != destination.Namespace || !isIngressPolicy(policy) {
+			continue
+		}
+
+		matches, err := selectorMatches(&policy.Spec.PodSelector, destination.Labels)
+		if err != nil {
+			return "", fmt.Errorf("policy %s/%s: %v", policy.Namespace, policy.Name, err)
+		}
+		if !matches {
+			continue
+		}
+		selected = true
+
+		for _, rule := range policy.Spec.Ingress {
+			allowed, err := ruleAllows(rule, policy.Namespace, edge, port, workloadResolver)
+			if err != nil {
+				return "", fmt.Errorf("policy %s/%s: %v", policy.Namespace, policy.Name, err)
+			}
+			if allowed {
+				flow.Policies = append(flow.Policies, policy.Namespace+"/"+policy.Name)
+				break
+			}
+		}
+	}
+
+	if !selected {
+		return VerdictUnselected, nil
+	}
+	if len(flow.Policies) > 0 {
+		return VerdictAllowed, nil
+	}
+	return VerdictBlocked, nil
+}
+
+func isIngressPolicy(policy networkingv1.NetworkPolicy) bool {
+	// policies without the types always affect the ingress
+	if len(policy.Spec.PolicyTypes) == 0 {
+		return true
+	}
+
+	for _, policyType := range policy.Spec.PolicyTypes {
+		if policyType == networkingv1.PolicyTypeIngress {
+			return true
+		}
+	}
+	return false
+}
+
+func selectorMatches(selector *metav1.LabelSelector, set map[string]string) (bool, error) {
+	s, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		return false, err
+	}
+	return s.Matches(labels.Set(set)), nil
+}
+
+func ruleAllows(rule networkingv1.NetworkPolicyIngressRule, namespace string, edge servicemap.ServiceMapEdge, port string, workloadResolver WorkloadResolver) (bool, error) {
+	if !portsAllow(rule.Ports, port) {
+		return false, nil
+	}
+
+	// an empty list of peers allows every source
+	if len(rule.From) == 0 {
+		return true, nil
+	}
+
+	for _, peer := range rule.From {
+		allowed, err := peerAllows(peer, namespace, edge.Source, workloadResolver)
+		if err != nil || allowed {
+			return allowed, err
+		}
+	}
+
+	return false, nil
+}
+
+// portsAllow tells whether the ports of a rule allow the given port, an unknown or invalid port only by allowing every port.
+func portsAllow(ports []networkingv1.NetworkPolicyPort, value string) bool {
+	if len(ports) == 0 {
+		return true
+	}
+
+	port, err := strconv.ParseInt(value, 10, 32)
+	if err != nil {
+		port = -1
+	}
+
+	for _, p := range ports {
+		if p.Protocol != nil && *p.Protocol != corev1.ProtocolTCP {
+			continue
+		}
+		if p.Port == nil {
+			return true
+		}
+		// the named ports can not be matched without the pod specs
+		if p.Port.Type != intstr.Int {
+			continue
+		}
+
+		endPort := p.Port.IntVal
+		if p.EndPort != nil {
+			endPort = *p.EndPort
+		}
+		if port >= int64(p.Port.IntVal) && port <= int64(endPort) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func peerAllows(peer networkingv1.NetworkPolicyPeer, namespace string, source servicemap.ServiceMapNode, workloadResolver WorkloadResolver) (bool, error) {
+	if peer.IPBlock != nil {
+		return ipBlockAllows(peer.IPBlock, source)
+	}
+
+	if !source.Resolved {
+		return false, nil
+	}
+	workload, ok := workloadResolver.Resolve(source.Name)
+	if !ok {
+		return false, nil
+	}
+
+	if peer.NamespaceSelector == nil {
+		if workload.Namespace != namespace {
+			return false, nil
+		}
+	} else {
+		matches, err := selectorMatches(peer.NamespaceSelector, map[string]string{NamespaceNameLabel: workload.Namespace})
+		if err != nil || !matches {
+			return false, err
+		}
+	}
+
+	if peer.PodSelector == nil {
+		return true, nil
+	}
+	return selectorMatches(peer.PodSelector, workload.Labels)
+}
+
+// ipBlockAllows matches the IP of the source, or the whole CIDR if the unresolved peers are collapsed.
+func ipBlockAllows(ipBlock *networkingv1.IPBlock, source servicemap.ServiceMapNode) (bool, error) {
+	_, block, err := net.ParseCIDR(ipBlock.CIDR)
+	if err != nil {
+		return false, err
+	}
+
+	// the name of an unresolved node is either its IP or its CIDR
+	address := source.Name
+	if source.Resolved && source.Entry != nil {
+		address = source.Entry.IP
+	}
+
+	sourceNet, err := toIPNet(address)
+	if err != nil {
+		return false, nil
+	}
+	if !cidrContains(block, sourceNet) {
+		return false, nil
+	}
+
+	for _, except := range ipBlock.Except {
+		_, exceptNet, err := net.ParseCIDR(except)
+		if err != nil {
+			return false, err
+		}
+		if exceptNet.Contains(sourceNet.IP) {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+func toIPNet(address string) (*net.IPNet, error) {
+	cidr, err := toCIDR(address)
+	if err != nil {
+		return nil, err
+	}
+	_, ipNet, err := net.ParseCIDR(cidr)
+	return ipNet, err
+}
+
+func cidrContains(outer *net.IPNet, inner *net.IPNet) bool {
+	outerOnes, outerBits := outer.Mask.Size()
+	innerOnes, innerBits := inner.Mask.Size()
+	return outerBits == innerBits && outerOnes <= innerOnes && outer.Contains(inner.IP)
+}
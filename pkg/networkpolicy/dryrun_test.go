@@ -0,0 +1,152 @@
+package networkpolicy
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kubeshark/hub/pkg/servicemap"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const uploadedPolicies = `
+apiVersion: networking.k8s.io/v1
+kind: NetworkPolicy
+metadata:
+  name: default-deny
+  namespace: storage
+spec:
+  podSelector: {}
+  policyTypes: ["Ingress"]
+---
+apiVersion: v1
+kind: List
+items:
+- apiVersion: networking.k8s.io/v1
+  kind: NetworkPolicy
+  metadata:
+    name: carts-from-frontend
+    namespace: shop
+  spec:
+    podSelector:
+      matchLabels:
+        app: carts
+    ingress:
+    - from:
+      - podSelector:
+          matchLabels:
+            app: frontend
+      ports:
+      - protocol: TCP
+        port: 80
+- apiVersion: networking.k8s.io/v1
+  kind: NetworkPolicy
+  metadata:
+    name: frontend-from-outside
+    namespace: shop
+  spec:
+    podSelector:
+      matchLabels:
+        app: frontend
+    ingress:
+    - from:
+      - ipBlock:
+          cidr: 10.0.0.0/8
+          except: ["10.9.0.0/16"]
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: ignored
+`
+
+func TestParsePolicies(t *testing.T) {
+	policies, err := ParsePolicies(strings.NewReader(uploadedPolicies))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"default-deny", "carts-from-frontend", "frontend-from-outside"},
+		[]string{policies[0].Name, policies[1].Name, policies[2].Name})
+
+	_, err = ParsePolicies(strings.NewReader("kind: [NetworkPolicy"))
+	assert.Error(t, err)
+}
+
+func TestDryRun(t *testing.T) {
+	policies, err := ParsePolicies(strings.NewReader(uploadedPolicies))
+	require.NoError(t, err)
+
+	report, err := DryRun(observedEdges, workloads, policies)
+	require.NoError(t, err)
+
+	verdicts := make(map[string]string)
+	for _, flow := range report.Flows {
+		verdicts[flow.Source+" -> "+flow.Destination] = flow.Verdict
+	}
+
+	tests := map[string]struct {
+		flow    string
+		verdict string
+	}{
+		"allowed by pod selector":    {flow: "frontend.shop -> carts.shop", verdict: VerdictAllowed},
+		"blocked by pod selector":    {flow: "orders.shop -> carts.shop", verdict: VerdictBlocked},
+		"unselected destination":     {flow: "frontend.shop -> orders.shop", verdict: VerdictUnselected},
+		"blocked by default deny":    {flow: "carts.shop -> db.storage", verdict: VerdictBlocked},
+		"allowed by ip block":        {flow: "10.1.2.3 -> frontend.shop", verdict: VerdictAllowed},
+		"blocked by ip block except": {flow: "10.9.0.0/24 -> frontend.shop", verdict: VerdictBlocked},
+		"external is not an ip":      {flow: "external -> frontend.shop", verdict: VerdictBlocked},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.verdict, verdicts[test.flow])
+		})
+	}
+
+	assert.Equal(t, 3, report.Summary.Policies)
+	assert.Equal(t, len(observedEdges), report.Summary.Flows)
+	assert.Equal(t, report.Summary.Flows, report.Summary.Allowed+report.Summary.Blocked+report.Summary.Unselected+report.Summary.Unknown)
+}
+
+func TestDryRunPorts(t *testing.T) {
+	policies, err := ParsePolicies(strings.NewReader(`
+kind: NetworkPolicy
+apiVersion: networking.k8s.io/v1
+metadata:
+  name: db-port
+  namespace: storage
+spec:
+  podSelector: {}
+  ingress:
+  - from:
+    - namespaceSelector:
+        matchLabels:
+          kubernetes.io/metadata.name: shop
+    ports:
+    - port: 5000
+      endPort: 6000
+`))
+	require.NoError(t, err)
+
+	report, err := DryRun(observedEdges[3:5], workloads, policies)
+	require.NoError(t, err)
+	assert.Equal(t, 2, report.Summary.Allowed)
+	assert.Equal(t, []string{"storage/db-port"}, report.Flows[0].Policies)
+}
+
+func TestDryRunServiceReachedOnTwoPorts(t *testing.T) {
+	policies, err := ParsePolicies(strings.NewReader(uploadedPolicies))
+	require.NoError(t, err)
+
+	twoPorts := edge(frontend, carts)
+	twoPorts.Count = 4
+	twoPorts.Ports = []servicemap.ServiceMapEdgePort{{Port: "80", Count: 3}, {Port: "9090", Count: 1}}
+
+	report, err := DryRun([]servicemap.ServiceMapEdge{twoPorts}, workloads, policies)
+	require.NoError(t, err)
+	require.Len(t, report.Flows, 2)
+	assert.Equal(t, DryRunFlow{Source: "frontend.shop", Destination: "carts.shop", Protocol: "http", Port: "80", Count: 3,
+		Verdict: VerdictAllowed, Policies: []string{"shop/carts-from-frontend"}}, report.Flows[0])
+	assert.Equal(t, "9090", report.Flows[1].Port)
+	assert.Equal(t, 1, report.Flows[1].Count)
+	assert.Equal(t, VerdictBlocked, report.Flows[1].Verdict)
+	assert.Equal(t, 2, report.Summary.Flows)
+}
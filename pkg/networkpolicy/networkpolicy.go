@@ -0,0 +1,305 @@
+package networkpolicy
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/kubeshark/hub/pkg/resolver"
+	"github.com/kubeshark/hub/pkg/servicemap"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	GroupByWorkload  = "workload"
+	GroupByNamespace = "namespace"
+
+	// NamespaceNameLabel is set on every namespace by Kubernetes 1.21+.
+	NamespaceNameLabel = "kubernetes.io/metadata.name"
+	managedByLabel     = "app.kubernetes.io/managed-by"
+	managedBy          = "kubeshark"
+	policyNamePrefix   = "kubeshark-allow-"
+)
+
+// Workload is what the generator needs to know about a resolved service map node.
+type Workload struct {
+	Namespace string
+	Labels    map[string]string
+}
+
+// WorkloadResolver looks up the namespace and the pod labels of a resolved service map node.
+type WorkloadResolver interface {
+	Resolve(name string) (*Workload, bool)
+}
+
+type k8sWorkloadResolver struct {
+	resolver *resolver.Resolver
+}
+
+// NewWorkloadResolver resolves the workloads through the Kubernetes resolver, which may be nil.
+func NewWorkloadResolver(k8sResolver *resolver.Resolver) WorkloadResolver {
+	return &k8sWorkloadResolver{resolver: k8sResolver}
+}
+
+func (r *k8sWorkloadResolver) Resolve(name string) (*Workload, bool) {
+	workload := &Workload{}
+
+	// the resolved names are in the form of service.namespace
+	if i := strings.Index(name, "."); i >= 0 {
+		workload.Namespace = name[i+1:]
+	}
+
+	if r.resolver != nil {
+		if info := r.resolver.Resolve(name); info != nil {
+			workload.Namespace = info.Namespace
+		}
+		workload.Labels, _ = r.resolver.GetLabels(name)
+	}
+
+	return workload, workload.Namespace != ""
+}
+
+type GenerateResult struct {
+	Policies []*networkingv1.NetworkPolicy
+	Warnings []string
+}
+
+// ingressPolicy collects the ingress rules of a single policy, keyed by their peer.
+// A peer with every port allowed has traffic of an unknown or invalid port.
+type ingressPolicy struct {
+	policy   *networkingv1.NetworkPolicy
+	peers    map[string]*networkingv1.NetworkPolicyPeer
+	ports    map[string]map[int32]bool
+	allPorts map[string]bool
+}
+
+// Generate turns the observed edges into the policies allowing exactly the traffic seen, per destination
+// workload or per destination namespace. An empty namespace generates the policies of every namespace.
+func Generate(edges []servicemap.ServiceMapEdge, workloadResolver WorkloadResolver, groupBy string, namespace string) (*GenerateResult, error) {
+	if groupBy != GroupByWorkload && groupBy != GroupByNamespace {
+		return nil, fmt.Errorf("invalid groupBy: %s", groupBy)
+	}
+
+	result := &GenerateResult{
+		Policies: make([]*networkingv1.NetworkPolicy, 0),
+		Warnings: make([]string, 0),
+	}
+	warned := make(map[string]bool)
+	warn := func(format string, a ...interface{}) {
+		warning := fmt.Sprintf(format, a...)
+		if !warned[warning] {
+			warned[warning] = true
+			result.Warnings = append(result.Warnings, warning)
+		}
+	}
+
+	policies := make(map[string]*ingressPolicy)
+	for _, edge := range edges {
+		destination := edge.Destination
+		if !destination.Resolved {
+			warn("Skipped the traffic to the unresolved destination %s.", destination.Name)
+			continue
+		}
+
+		workload, ok := workloadResolver.Resolve(destination.Name)
+		if !ok {
+			warn("Skipped the traffic to %s, its namespace is unknown.", destination.Name)
+			continue
+		}
+		if namespace != "" && workload.Namespace != namespace {
+			continue
+		}
+
+		var name string
+		var podSelector metav1.LabelSelector
+		if groupBy == GroupByWorkload {
+			if len(workload.Labels) == 0 {
+				warn("Skipped the traffic to %s, its pod labels are unknown.", destination.Name)
+				continue
+			}
+			name = policyNamePrefix + strings.TrimSuffix(destination.Name, "."+workload.Namespace)
+			podSelector = metav1.LabelSelector{MatchLabels: workload.Labels}
+		} else {
+			name = policyNamePrefix + "ingress"
+		}
+
+		policyKey := workload.Namespace + "/" + name
+		p, ok := policies[policyKey]
+		if !ok {
+			p = &ingressPolicy{
+				policy:   newNetworkPolicy(name, workload.Namespace, podSelector),
+				peers:    make(map[string]*networkingv1.NetworkPolicyPeer),
+				ports:    make(map[string]map[int32]bool),
+				allPorts: make(map[string]bool),
+			}
+			policies[policyKey] = p
+		}
+
+		peer, peerKey, err := toPeer(edge.Source, workloadResolver)
+		if err != nil {
+			warn("Skipped the traffic from %s to %s: %v", edge.Source.Name, destination.Name, err)
+			continue
+		}
+		if _, ok := p.peers[peerKey]; !ok {
+			p.peers[peerKey] = peer
+			p.ports[peerKey] = make(map[int32]bool)
+		}
+
+		if len(edge.Ports) == 0 {
+			warn("Allowed every port of %s to %s, the ports of the traffic are unknown.", destination.Name, edge.Source.Name)
+			p.allPorts[peerKey] = true
+			continue
+		}
+		for _, edgePort := range edge.Ports {
+			port, err := strconv.ParseInt(edgePort.Port, 10, 32)
+			if err != nil || port <= 0 {
+				warn("Allowed every port of %s to %s, the port %q of the traffic is invalid.", destination.Name, edge.Source.Name, edgePort.Port)
+				p.allPorts[peerKey] = true
+				continue
+			}
+			p.ports[peerKey][int32(port)] = true
+		}
+	}
+
+	keys := make([]string, 0, len(policies))
+	for k := range policies {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		p := policies[k]
+		if len(p.peers) == 0 {
+			continue
+		}
+		p.policy.Spec.Ingress = p.toIngressRules()
+		result.Policies = append(result.Policies, p.policy)
+	}
+
+	return result, nil
+}
+
+func newNetworkPolicy(name string, namespace string, podSelector metav1.LabelSelector) *networkingv1.NetworkPolicy {
+	return &networkingv1.NetworkPolicy{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "networking.k8s.io/v1",
+			Kind:       "NetworkPolicy",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    map[string]string{managedByLabel: managedBy},
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: podSelector,
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+		},
+	}
+}
+
+// toPeer returns the peer matching the source of an edge, unresolved sources are matched by their IP or CIDR.
+func toPeer(source servicemap.ServiceMapNode, workloadResolver WorkloadResolver) (*networkingv1.NetworkPolicyPeer, string, error) {
+	if !source.Resolved {
+		cidr, err := toCIDR(source.Name)
+		if err != nil {
+			return nil, "", err
+		}
+		return &networkingv1.NetworkPolicyPeer{IPBlock: &networkingv1.IPBlock{CIDR: cidr}}, "ipBlock:" + cidr, nil
+	}
+
+	workload, ok := workloadResolver.Resolve(source.Name)
+	if !ok {
+		return nil, "", fmt.Errorf("the namespace of %s is unknown", source.Name)
+	}
+
+	peer := &networkingv1.NetworkPolicyPeer{
+		NamespaceSelector: &metav1.LabelSelector{
+			MatchLabels: map[string]string{NamespaceNameLabel: workload.Namespace},
+		},
+	}
+	if len(workload.Labels) == 0 {
+		// without the labels the best we can do is to allow the whole namespace
+		return peer, "namespace:" + workload.Namespace, nil
+	}
+
+	peer.PodSelector = &metav1.LabelSelector{MatchLabels: workload.Labels}
+	return peer, "workload:" + source.Name, nil
+}
+
+func toCIDR(name string) (string, error) {
+	if _, ipNet, err := net.ParseCIDR(name); err == nil {
+		return ipNet.String(), nil
+	}
+
+	ip := net.ParseIP(name)
+	if ip == nil {
+		return "", fmt.Errorf("%s is neither an IP nor a CIDR", name)
+	}
+	if ip.To4() != nil {
+		return ip.String() + "/32", nil
+	}
+	return ip.String() + "/128", nil
+}
+
+func (p *ingressPolicy) toIngressRules() []networkingv1.NetworkPolicyIngressRule {
+	peerKeys := make([]string, 0, len(p.peers))
+	for k := range p.peers {
+		peerKeys = append(peerKeys, k)
+	}
+	sort.Strings(peerKeys)
+
+	rules := make([]networkingv1.NetworkPolicyIngressRule, 0, len(peerKeys))
+	for _, k := range peerKeys {
+		rule := networkingv1.NetworkPolicyIngressRule{
+			From: []networkingv1.NetworkPolicyPeer{*p.peers[k]},
+		}
+		if p.allPorts[k] {
+			rules = append(rules, rule)
+			continue
+		}
+
+		ports := make([]int, 0, len(p.ports[k]))
+		for port := range p.ports[k] {
+			ports = append(ports, int(port))
+		}
+		sort.Ints(ports)
+
+		protocol := corev1.ProtocolTCP
+		for _, port := range ports {
+			portValue := intstr.FromInt(port)
+			rule.Ports = append(rule.Ports, networkingv1.NetworkPolicyPort{
+				Protocol: &protocol,
+				Port:     &portValue,
+			})
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return rules
+}
+
+// ToYaml marshals the policies into a multi-document YAML.
+func ToYaml(policies []*networkingv1.NetworkPolicy) ([]byte, error) {
+	var buffer bytes.Buffer
+	for i, policy := range policies {
+		if i > 0 {
+			buffer.WriteString("---\n")
+		}
+
+		document, err := yaml.Marshal(policy)
+		if err != nil {
+			return nil, err
+		}
+		buffer.Write(document)
+	}
+
+	return buffer.Bytes(), nil
+}
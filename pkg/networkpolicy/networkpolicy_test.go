@@ -0,0 +1,185 @@
+package networkpolicy
+
+import (
+	"strings"
+	"testing"
+
+	baseApi "github.com/kubeshark/base/pkg/api"
+	"github.com/kubeshark/hub/pkg/servicemap"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	networkingv1 "k8s.io/api/networking/v1"
+)
+
+type fakeResolver map[string]*Workload
+
+func (r fakeResolver) Resolve(name string) (*Workload, bool) {
+	workload, ok := r[name]
+	return workload, ok
+}
+
+var workloads = fakeResolver{
+	"frontend.shop": {Namespace: "shop", Labels: map[string]string{"app": "frontend"}},
+	"carts.shop":    {Namespace: "shop", Labels: map[string]string{"app": "carts"}},
+	"orders.shop":   {Namespace: "shop", Labels: map[string]string{"app": "orders"}},
+	"db.storage":    {Namespace: "storage", Labels: map[string]string{"app": "db"}},
+	"legacy.shop":   {Namespace: "shop"},
+}
+
+func node(name string, ip string, port string) servicemap.ServiceMapNode {
+	return servicemap.ServiceMapNode{
+		Name:     name,
+		Entry:    &baseApi.TCP{Name: name, IP: ip, Port: port},
+		Resolved: !strings.HasPrefix(name, "10.") && name != servicemap.ExternalNodeName,
+	}
+}
+
+func edge(source servicemap.ServiceMapNode, destination servicemap.ServiceMapNode) servicemap.ServiceMapEdge {
+	return servicemap.ServiceMapEdge{
+		Source:      source,
+		Destination: destination,
+		Count:       1,
+		Protocol:    &baseApi.Protocol{ProtocolSummary: baseApi.ProtocolSummary{Name: "http"}},
+		Ports:       []servicemap.ServiceMapEdgePort{{Port: destination.Entry.Port, Count: 1}},
+	}
+}
+
+var (
+	frontend = node("frontend.shop", "10.0.0.1", "8080")
+	carts    = node("carts.shop", "10.0.0.2", "80")
+	orders   = node("orders.shop", "10.0.0.3", "80")
+	db       = node("db.storage", "10.0.1.1", "5432")
+	legacy   = node("legacy.shop", "10.0.0.4", "80")
+	outside  = node("10.1.2.3", "10.1.2.3", "40000")
+	bucket   = node("10.9.0.0/24", "10.9.0.7", "40000")
+	external = node(servicemap.ExternalNodeName, "8.8.8.8", "40000")
+
+	observedEdges = []servicemap.ServiceMapEdge{
+		edge(frontend, carts),
+		edge(frontend, orders),
+		edge(orders, carts),
+		edge(carts, db),
+		edge(orders, db),
+		edge(outside, frontend),
+		edge(bucket, frontend),
+		edge(external, frontend),
+		edge(legacy, carts),
+		edge(frontend, legacy),
+	}
+)
+
+func policyNames(policies []*networkingv1.NetworkPolicy) []string {
+	names := make([]string, 0)
+	for _, policy := range policies {
+		names = append(names, policy.Namespace+"/"+policy.Name)
+	}
+	return names
+}
+
+func TestGenerate(t *testing.T) {
+	tests := map[string]struct {
+		groupBy   string
+		namespace string
+		expected  []string
+		warnings  int
+	}{
+		"by workload": {
+			groupBy:  GroupByWorkload,
+			expected: []string{"shop/kubeshark-allow-carts", "shop/kubeshark-allow-frontend", "shop/kubeshark-allow-orders", "storage/kubeshark-allow-db"},
+			warnings: 2,
+		},
+		"by namespace": {
+			groupBy:  GroupByNamespace,
+			expected: []string{"shop/kubeshark-allow-ingress", "storage/kubeshark-allow-ingress"},
+			warnings: 1,
+		},
+		"single namespace": {
+			groupBy:   GroupByWorkload,
+			namespace: "storage",
+			expected:  []string{"storage/kubeshark-allow-db"},
+			warnings:  0,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			result, err := Generate(observedEdges, workloads, test.groupBy, test.namespace)
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, policyNames(result.Policies))
+			assert.Len(t, result.Warnings, test.warnings)
+		})
+	}
+}
+
+func TestGenerateInvalidGroupBy(t *testing.T) {
+	_, err := Generate(observedEdges, workloads, "pod", "")
+	assert.Error(t, err)
+}
+
+func TestGenerateIngressRules(t *testing.T) {
+	result, err := Generate(observedEdges, workloads, GroupByWorkload, "shop")
+	require.NoError(t, err)
+
+	var frontendPolicy, cartsPolicy *networkingv1.NetworkPolicy
+	for _, policy := range result.Policies {
+		switch policy.Name {
+		case "kubeshark-allow-frontend":
+			frontendPolicy = policy
+		case "kubeshark-allow-carts":
+			cartsPolicy = policy
+		}
+	}
+	require.NotNil(t, frontendPolicy)
+	require.NotNil(t, cartsPolicy)
+
+	assert.Equal(t, map[string]string{"app": "frontend"}, frontendPolicy.Spec.PodSelector.MatchLabels)
+	require.Len(t, frontendPolicy.Spec.Ingress, 2)
+	assert.Equal(t, "10.1.2.3/32", frontendPolicy.Spec.Ingress[0].From[0].IPBlock.CIDR)
+	assert.Equal(t, "10.9.0.0/24", frontendPolicy.Spec.Ingress[1].From[0].IPBlock.CIDR)
+	assert.Equal(t, int32(8080), frontendPolicy.Spec.Ingress[0].Ports[0].Port.IntVal)
+
+	// frontend, orders and the namespace wide rule of legacy
+	require.Len(t, cartsPolicy.Spec.Ingress, 3)
+	assert.Nil(t, cartsPolicy.Spec.Ingress[0].From[0].PodSelector)
+	assert.Equal(t, map[string]string{NamespaceNameLabel: "shop"}, cartsPolicy.Spec.Ingress[0].From[0].NamespaceSelector.MatchLabels)
+	assert.Equal(t, map[string]string{"app": "frontend"}, cartsPolicy.Spec.Ingress[1].From[0].PodSelector.MatchLabels)
+	assert.Equal(t, map[string]string{"app": "orders"}, cartsPolicy.Spec.Ingress[2].From[0].PodSelector.MatchLabels)
+}
+
+func TestGenerateServiceReachedOnTwoPorts(t *testing.T) {
+	twoPorts := edge(frontend, carts)
+	twoPorts.Ports = []servicemap.ServiceMapEdgePort{{Port: "80", Count: 3}, {Port: "9090", Count: 1}}
+	unknownPorts := edge(orders, carts)
+	unknownPorts.Ports = nil
+
+	result, err := Generate([]servicemap.ServiceMapEdge{twoPorts, unknownPorts}, workloads, GroupByWorkload, "")
+	require.NoError(t, err)
+	require.Len(t, result.Policies, 1)
+
+	ingress := result.Policies[0].Spec.Ingress
+	require.Len(t, ingress, 2)
+	assert.Equal(t, map[string]string{"app": "frontend"}, ingress[0].From[0].PodSelector.MatchLabels)
+	require.Len(t, ingress[0].Ports, 2)
+	assert.Equal(t, int32(80), ingress[0].Ports[0].Port.IntVal)
+	assert.Equal(t, int32(9090), ingress[0].Ports[1].Port.IntVal)
+
+	// the ports of the traffic from orders are unknown, every port is allowed
+	assert.Equal(t, map[string]string{"app": "orders"}, ingress[1].From[0].PodSelector.MatchLabels)
+	assert.Empty(t, ingress[1].Ports)
+	assert.Equal(t, []string{"Allowed every port of carts.shop to orders.shop, the ports of the traffic are unknown."}, result.Warnings)
+}
+
+func TestToYaml(t *testing.T) {
+	result, err := Generate(observedEdges, workloads, GroupByNamespace, "")
+	require.NoError(t, err)
+
+	document, err := ToYaml(result.Policies)
+	require.NoError(t, err)
+
+	policies, err := ParsePolicies(strings.NewReader(string(document)))
+	require.NoError(t, err)
+	require.Len(t, policies, 2)
+	assert.Equal(t, "kubeshark-allow-ingress", policies[0].Name)
+	assert.Equal(t, "storage", policies[1].Namespace)
+	assert.Equal(t, result.Policies[0].Spec, policies[0].Spec)
+}
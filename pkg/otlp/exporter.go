@@ -0,0 +1,319 @@
+package otlp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/kubeshark/base/pkg/api"
+	"github.com/kubeshark/hub/pkg/providers"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	DefaultBatchSize     = 512
+	DefaultQueueSize     = 4096
+	DefaultFlushInterval = 5 * time.Second
+	DefaultTimeout       = 10 * time.Second
+	DefaultMaxRetries    = 5
+	DefaultRetryInterval = time.Second
+
+	maxRetryInterval = 30 * time.Second
+	tracesPath       = "/v1/traces"
+)
+
+// Options configure the export of the entries to an OTLP/HTTP receiver, e.g. an OpenTelemetry collector.
+type Options struct {
+	// Endpoint is the base URL of the receiver, e.g. http://otel-collector:4318, the spans are sent to its /v1/traces.
+	Endpoint      string
+	Headers       map[string]string
+	BatchSize     int
+	QueueSize     int
+	FlushInterval time.Duration
+	Timeout       time.Duration
+	MaxRetries    int
+	// RetryInterval is the delay before the first retry of a batch, it is doubled on every retry.
+	RetryInterval time.Duration
+}
+
+// Stats counts the spans by their fate, the dropped ones overflowed the queue and the failed ones were rejected
+// or ran out of retries.
+type Stats struct {
+	Exported uint64 `json:"exported"`
+	Dropped  uint64 `json:"dropped"`
+	Failed   uint64 `json:"failed"`
+}
+
+// Exporter converts the entries into spans and sends them in batches, off the ingestion loop.
+// It drops the spans when the queue is full rather than slowing the ingestion down.
+type Exporter struct {
+	lock    sync.Mutex
+	options Options
+	client  *http.Client
+	queue   chan *ResourceSpan
+	stop    chan struct{}
+	done    chan struct{}
+	enabled bool
+
+	exported uint64
+	dropped  uint64
+	failed   uint64
+}
+
+var instance *Exporter
+var once sync.Once
+
+func GetDefaultExporterInstance() *Exporter {
+	once.Do(func() {
+		instance = &Exporter{}
+	})
+
+	return instance
+}
+
+func withDefaults(options Options) Options {
+	if options.BatchSize <= 0 {
+		options.BatchSize = DefaultBatchSize
+	}
+	if options.QueueSize <= 0 {
+		options.QueueSize = DefaultQueueSize
+	}
+	if options.FlushInterval <= 0 {
+		options.FlushInterval = DefaultFlushInterval
+	}
+	if options.Timeout <= 0 {
+		options.Timeout = DefaultTimeout
+	}
+	if options.MaxRetries < 0 {
+		options.MaxRetries = 0
+	}
+	if options.RetryInterval <= 0 {
+		options.RetryInterval = DefaultRetryInterval
+	}
+	return options
+}
+
+// ParseHeaders parses the comma separated name=value pairs of the headers sent to the receiver, e.g. its API key.
+func ParseHeaders(value string) (map[string]string, error) {
+	headers := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, fmt.Errorf("invalid header: %s, must be name=value", pair)
+		}
+		headers[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+	return headers, nil
+}
+
+// Start starts sending the exported entries to the endpoint of the options.
+func (e *Exporter) Start(options Options) error {
+	if options.Endpoint == "" {
+		return fmt.Errorf("the endpoint of the OTLP receiver is required")
+	}
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	if e.enabled {
+		return fmt.Errorf("the OTLP exporter is already started")
+	}
+
+	e.options = withDefaults(options)
+	e.options.Endpoint = strings.TrimSuffix(e.options.Endpoint, "/")
+	e.client = &http.Client{Timeout: e.options.Timeout}
+	e.queue = make(chan *ResourceSpan, e.options.QueueSize)
+	e.stop = make(chan struct{})
+	e.done = make(chan struct{})
+	e.enabled = true
+
+	go e.run(e.queue, e.stop, e.done)
+
+	log.Info().Str("endpoint", e.options.Endpoint).Msg("Exporting the entries to OTLP:")
+	return nil
+}
+
+// Stop sends the queued spans and stops the exporter.
+func (e *Exporter) Stop() {
+	e.lock.Lock()
+	if !e.enabled {
+		e.lock.Unlock()
+		return
+	}
+	e.enabled = false
+	stop, done := e.stop, e.done
+	e.lock.Unlock()
+
+	close(stop)
+	<-done
+}
+
+func (e *Exporter) IsEnabled() bool {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	return e.enabled
+}
+
+func (e *Exporter) GetStats() *Stats {
+	return &Stats{
+		Exported: atomic.LoadUint64(&e.exported),
+		Dropped:  atomic.LoadUint64(&e.dropped),
+		Failed:   atomic.LoadUint64(&e.failed),
+	}
+}
+
+// Export queues the span of an entry, it does nothing unless the exporter is started.
+// The peers are the namespaces the source and the destination of the entry are resolved to.
+func (e *Exporter) Export(entry *api.Entry, summary *api.BaseEntry, peers providers.PeerNamespaces) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	if !e.enabled {
+		return
+	}
+
+	select {
+	case e.queue <- NewResourceSpan(entry, summary, peers):
+	default:
+		atomic.AddUint64(&e.dropped, 1)
+	}
+}
+
+func (e *Exporter) run(queue <-chan *ResourceSpan, stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(e.options.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*ResourceSpan, 0, e.options.BatchSize)
+	flush := func() {
+		if len(batch) > 0 {
+			e.send(batch, stop)
+			batch = make([]*ResourceSpan, 0, e.options.BatchSize)
+		}
+	}
+
+	for {
+		select {
+		case span := <-queue:
+			batch = append(batch, span)
+			if len(batch) >= e.options.BatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-stop:
+			for {
+				select {
+				case span := <-queue:
+					batch = append(batch, span)
+					if len(batch) >= e.options.BatchSize {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// NewExportRequest groups the spans of a batch by their resources, in the order of their first spans.
+func NewExportRequest(batch []*ResourceSpan) *ExportTraceServiceRequest {
+	request := &ExportTraceServiceRequest{ResourceSpans: make([]*ResourceSpans, 0)}
+	byResource := make(map[string]*ScopeSpans)
+	for _, resourceSpan := range batch {
+		key := resourceSpan.resourceKey()
+		scopeSpans, ok := byResource[key]
+		if !ok {
+			scopeSpans = &ScopeSpans{Scope: &Scope{Name: scopeName}, Spans: make([]*Span, 0)}
+			byResource[key] = scopeSpans
+			request.ResourceSpans = append(request.ResourceSpans, &ResourceSpans{
+				Resource:   &Resource{Attributes: resourceSpan.Resource},
+				ScopeSpans: []*ScopeSpans{scopeSpans},
+			})
+		}
+		scopeSpans.Spans = append(scopeSpans.Spans, resourceSpan.Span)
+	}
+	return request
+}
+
+// send posts a batch, retrying with an exponential backoff on the network errors and the retryable statuses.
+// The retries are cut short when the exporter is stopped, so it does not hang on an unreachable receiver.
+func (e *Exporter) send(batch []*ResourceSpan, stop <-chan struct{}) {
+	body, err := json.Marshal(NewExportRequest(batch))
+	if err != nil {
+		log.Error().Err(err).Msg("While marshaling the OTLP spans:")
+		atomic.AddUint64(&e.failed, uint64(len(batch)))
+		return
+	}
+
+	interval := e.options.RetryInterval
+	for attempt := 0; ; attempt++ {
+		retryable, err := e.post(body)
+		if err == nil {
+			atomic.AddUint64(&e.exported, uint64(len(batch)))
+			return
+		}
+
+		if !retryable || attempt >= e.options.MaxRetries {
+			log.Error().Err(err).Int("spans", len(batch)).Int("attempts", attempt+1).Msg("While exporting the OTLP spans:")
+			atomic.AddUint64(&e.failed, uint64(len(batch)))
+			return
+		}
+
+		log.Debug().Err(err).Dur("retry-in", interval).Msg("Exporting the OTLP spans:")
+		select {
+		case <-time.After(interval):
+		case <-stop:
+			// the last attempt, the exporter is stopping
+			attempt = e.options.MaxRetries - 1
+		}
+		if interval *= 2; interval > maxRetryInterval {
+			interval = maxRetryInterval
+		}
+	}
+}
+
+func (e *Exporter) post(body []byte) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), e.options.Timeout)
+	defer cancel()
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, e.options.Endpoint+tracesPath, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	for name, value := range e.options.Headers {
+		request.Header.Set(name, value)
+	}
+
+	response, err := e.client.Do(request)
+	if err != nil {
+		return true, err
+	}
+	defer response.Body.Close()
+	message, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+
+	switch {
+	case response.StatusCode >= 200 && response.StatusCode < 300:
+		return false, nil
+	case response.StatusCode == http.StatusTooManyRequests, response.StatusCode == http.StatusBadGateway,
+		response.StatusCode == http.StatusServiceUnavailable, response.StatusCode == http.StatusGatewayTimeout:
+		return true, fmt.Errorf("OTLP receiver responded %d: %s", response.StatusCode, strings.TrimSpace(string(message)))
+	default:
+		return false, fmt.Errorf("OTLP receiver responded %d: %s", response.StatusCode, strings.TrimSpace(string(message)))
+	}
+}
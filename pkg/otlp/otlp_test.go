@@ -0,0 +1,307 @@
+package otlp
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kubeshark/base/pkg/api"
+	"github.com/kubeshark/hub/pkg/providers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var start = time.Date(2022, time.Month(1), 1, 10, 0, 0, 0, time.UTC).UnixMilli()
+
+// peers are the namespaces of the entries, the source front-end is not in the namespace of the destination orders
+var peers = providers.PeerNamespaces{Source: "web", Destination: "sock-shop"}
+
+// receiver is a local OTLP/HTTP receiver that answers with the statuses it is given, then with 200.
+type receiver struct {
+	lock     sync.Mutex
+	statuses []int
+	requests []*ExportTraceServiceRequest
+	headers  []http.Header
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if req.URL.Path != "/v1/traces" || req.Header.Get("Content-Type") != "application/json" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	if len(r.statuses) > 0 {
+		status := r.statuses[0]
+		r.statuses = r.statuses[1:]
+		w.WriteHeader(status)
+		return
+	}
+
+	var request *ExportTraceServiceRequest
+	if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	r.requests = append(r.requests, request)
+	r.headers = append(r.headers, req.Header)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (r *receiver) getRequests() []*ExportTraceServiceRequest {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.requests
+}
+
+func newEntry(protocol string, request map[string]interface{}) *api.Entry {
+	return &api.Entry{
+		Protocol:    api.ProtocolSummary{Name: protocol, Version: "1.1"},
+		Source:      &api.TCP{IP: "10.0.0.1", Port: "43210", Name: "front-end"},
+		Destination: &api.TCP{IP: "10.0.0.2", Port: "80", Name: "orders"},
+		Namespace:   "sock-shop",
+		Timestamp:   start,
+		ElapsedTime: 42,
+		Request:     request,
+	}
+}
+
+func withResponse(entry *api.Entry, response map[string]interface{}) *api.Entry {
+	entry.Response = response
+	return entry
+}
+
+func newSummary(protocol string, method string, summary string, status int) *api.BaseEntry {
+	return &api.BaseEntry{
+		Protocol: api.Protocol{ProtocolSummary: api.ProtocolSummary{Name: protocol}},
+		Method:   method,
+		Summary:  summary,
+		Status:   status,
+	}
+}
+
+func getAttributes(attributes []*KeyValue) map[string]interface{} {
+	values := make(map[string]interface{})
+	for _, attribute := range attributes {
+		switch {
+		case attribute.Value.StringValue != nil:
+			values[attribute.Key] = *attribute.Value.StringValue
+		case attribute.Value.IntValue != nil:
+			values[attribute.Key] = *attribute.Value.IntValue
+		case attribute.Value.BoolValue != nil:
+			values[attribute.Key] = *attribute.Value.BoolValue
+		}
+	}
+	return values
+}
+
+func TestNewResourceSpan(t *testing.T) {
+	tests := map[string]struct {
+		entry      *api.Entry
+		summary    *api.BaseEntry
+		name       string
+		attributes map[string]interface{}
+		failed     bool
+	}{
+		"http": {
+			entry: newEntry("http", map[string]interface{}{
+				"url":     "/orders?id=1",
+				"headers": map[string]interface{}{"Accept": "*/*"},
+			}),
+			summary: newSummary("http", "GET", "/orders?id=1", 503),
+			name:    "GET /orders",
+			attributes: map[string]interface{}{
+				"http.request.method":       "GET",
+				"url.path":                  "/orders",
+				"url.full":                  "/orders?id=1",
+				"network.protocol.version":  "1.1",
+				"http.response.status_code": "503",
+			},
+			failed: true,
+		},
+		"kafka": {
+			entry:   newEntry("kafka", map[string]interface{}{"apiKeyName": "Produce", "clientID": "orders-producer"}),
+			summary: newSummary("kafka", "Produce", "orders", 0),
+			name:    "Produce orders",
+			attributes: map[string]interface{}{
+				"messaging.system":           "kafka",
+				"messaging.operation":        "publish",
+				"messaging.destination.name": "orders",
+				"messaging.client_id":        "orders-producer",
+			},
+		},
+		"amqp": {
+			entry:   newEntry("amqp", map[string]interface{}{"method": "basic deliver", "exchange": "shipping", "routingKey": "order.created"}),
+			summary: newSummary("amqp", "basic deliver", "shipping", 0),
+			name:    "basic deliver shipping",
+			attributes: map[string]interface{}{
+				"messaging.system":                           "rabbitmq",
+				"messaging.operation":                        "receive",
+				"messaging.destination.name":                 "shipping",
+				"messaging.rabbitmq.destination.routing_key": "order.created",
+			},
+		},
+		"redis": {
+			entry:   newEntry("redis", map[string]interface{}{"command": "GET", "key": "cart:1"}),
+			summary: newSummary("redis", "GET", "cart:1", 0),
+			name:    "GET cart:1",
+			attributes: map[string]interface{}{
+				"db.system":    "redis",
+				"db.operation": "GET",
+				"db.statement": "GET cart:1",
+			},
+		},
+		"redis error": {
+			entry: withResponse(newEntry("redis", map[string]interface{}{"command": "GET", "key": "cart:1"}),
+				map[string]interface{}{"type": "Error", "value": "WRONGTYPE Operation against a key holding the wrong kind of value"}),
+			summary: newSummary("redis", "GET", "cart:1", 0),
+			name:    "GET cart:1",
+			attributes: map[string]interface{}{
+				"db.system":    "redis",
+				"db.operation": "GET",
+			},
+			failed: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			resourceSpan := NewResourceSpan(test.entry, test.summary, peers)
+			assert.Equal(t, map[string]interface{}{
+				"service.name":       "front-end",
+				"k8s.namespace.name": "web",
+			}, getAttributes(resourceSpan.Resource))
+
+			span := resourceSpan.Span
+			assert.Equal(t, test.name, span.Name)
+			assert.Equal(t, spanKindClient, span.Kind)
+			assert.Equal(t, "1641031200000000000", span.StartTimeUnixNano)
+			assert.Equal(t, "1641031200042000000", span.EndTimeUnixNano)
+			assert.Len(t, span.TraceId, 32)
+			assert.Len(t, span.SpanId, 16)
+			assert.Empty(t, span.ParentSpanId)
+
+			attributes := getAttributes(span.Attributes)
+			assert.Equal(t, "orders", attributes["server.address"])
+			assert.Equal(t, "80", attributes["server.port"])
+			assert.Equal(t, "front-end", attributes["client.address"])
+			assert.Equal(t, "sock-shop", attributes["kubeshark.destination.namespace"])
+			for key, value := range test.attributes {
+				assert.Equal(t, value, attributes[key], key)
+			}
+
+			if test.failed {
+				assert.Equal(t, statusCodeError, span.Status.Code)
+			} else {
+				assert.Equal(t, 0, span.Status.Code)
+			}
+		})
+	}
+}
+
+func TestNewResourceSpanReusesTraceContext(t *testing.T) {
+	entry := newEntry("http", map[string]interface{}{
+		"headers": map[string]interface{}{"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+	})
+	span := NewResourceSpan(entry, newSummary("http", "GET", "/orders", 200), peers).Span
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.TraceId)
+	assert.Equal(t, "00f067aa0ba902b7", span.ParentSpanId)
+	assert.NotEqual(t, "00f067aa0ba902b7", span.SpanId)
+
+	// the 64 bit trace ids are padded to 128 bits
+	entry = newEntry("http", map[string]interface{}{
+		"headers": map[string]interface{}{"X-B3-TraceId": "463ac35c9f6413ad", "X-B3-SpanId": "a2fb4a1d1a96d312"},
+	})
+	span = NewResourceSpan(entry, newSummary("http", "GET", "/orders", 200), peers).Span
+	assert.Equal(t, "0000000000000000463ac35c9f6413ad", span.TraceId)
+	assert.Equal(t, "a2fb4a1d1a96d312", span.ParentSpanId)
+
+	// the entries of a request id share a trace
+	requestId := map[string]interface{}{"headers": map[string]interface{}{"x-request-id": "abc"}}
+	first := NewResourceSpan(newEntry("http", requestId), newSummary("http", "GET", "/orders", 200), peers).Span
+	second := NewResourceSpan(newEntry("http", requestId), newSummary("http", "GET", "/carts", 200), peers).Span
+	assert.Equal(t, first.TraceId, second.TraceId)
+	assert.Empty(t, first.ParentSpanId)
+}
+
+func TestNewExportRequestGroupsByResource(t *testing.T) {
+	other := newEntry("redis", map[string]interface{}{})
+	other.Source = &api.TCP{IP: "10.0.0.3", Name: "carts"}
+
+	request := NewExportRequest([]*ResourceSpan{
+		NewResourceSpan(newEntry("http", map[string]interface{}{}), newSummary("http", "GET", "/orders", 200), peers),
+		NewResourceSpan(other, newSummary("redis", "GET", "cart:1", 0), peers),
+		NewResourceSpan(newEntry("http", map[string]interface{}{}), newSummary("http", "POST", "/orders", 201), peers),
+	})
+
+	require.Len(t, request.ResourceSpans, 2)
+	assert.Equal(t, "front-end", getAttributes(request.ResourceSpans[0].Resource.Attributes)["service.name"])
+	require.Len(t, request.ResourceSpans[0].ScopeSpans, 1)
+	assert.Len(t, request.ResourceSpans[0].ScopeSpans[0].Spans, 2)
+	assert.Equal(t, scopeName, request.ResourceSpans[0].ScopeSpans[0].Scope.Name)
+	assert.Equal(t, "carts", getAttributes(request.ResourceSpans[1].Resource.Attributes)["service.name"])
+}
+
+func TestExporterBatchesAndRetries(t *testing.T) {
+	r := &receiver{statuses: []int{http.StatusServiceUnavailable}}
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	exporter := &Exporter{}
+	exporter.Export(newEntry("http", map[string]interface{}{}), newSummary("http", "GET", "/orders", 200), peers)
+
+	require.NoError(t, exporter.Start(Options{
+		Endpoint:      server.URL + "/",
+		Headers:       map[string]string{"Authorization": "Bearer token"},
+		BatchSize:     2,
+		FlushInterval: time.Hour,
+		MaxRetries:    1,
+		RetryInterval: time.Millisecond,
+	}))
+	assert.Error(t, exporter.Start(Options{Endpoint: server.URL}))
+
+	for i := 0; i < 3; i++ {
+		exporter.Export(newEntry("http", map[string]interface{}{}), newSummary("http", "GET", "/orders", 200), peers)
+	}
+
+	// the first batch is sent once full, the second one when the exporter stops
+	require.Eventually(t, func() bool { return len(r.getRequests()) == 1 }, time.Second, time.Millisecond)
+	exporter.Stop()
+	assert.False(t, exporter.IsEnabled())
+
+	requests := r.getRequests()
+	require.Len(t, requests, 2)
+	assert.Len(t, requests[0].ResourceSpans[0].ScopeSpans[0].Spans, 2)
+	assert.Len(t, requests[1].ResourceSpans[0].ScopeSpans[0].Spans, 1)
+	assert.Equal(t, "Bearer token", r.headers[0].Get("Authorization"))
+	assert.Equal(t, &Stats{Exported: 3}, exporter.GetStats())
+}
+
+func TestExporterGivesUpOnRejectedBatches(t *testing.T) {
+	r := &receiver{statuses: []int{http.StatusBadRequest}}
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	exporter := &Exporter{}
+	require.NoError(t, exporter.Start(Options{Endpoint: server.URL, RetryInterval: time.Millisecond}))
+	exporter.Export(newEntry("http", map[string]interface{}{}), newSummary("http", "GET", "/orders", 200), peers)
+	exporter.Stop()
+
+	assert.Empty(t, r.getRequests())
+	assert.Equal(t, &Stats{Failed: 1}, exporter.GetStats())
+}
+
+func TestParseHeaders(t *testing.T) {
+	headers, err := ParseHeaders("api-key = secret, x-tenant=a=b,")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"api-key": "secret", "x-tenant": "a=b"}, headers)
+
+	_, err = ParseHeaders("api-key")
+	assert.Error(t, err)
+}
@@ -0,0 +1,295 @@
+package otlp
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/kubeshark/base/pkg/api"
+	"github.com/kubeshark/hub/pkg/providers"
+	"github.com/kubeshark/hub/pkg/traces"
+)
+
+const (
+	scopeName = "github.com/kubeshark/hub"
+
+	spanKindClient  = 3
+	statusCodeError = 2
+)
+
+// The messages below are the OTLP/JSON encoding of an ExportTraceServiceRequest,
+// with the hex encoded ids and the 64 bit integers as strings.
+
+type ExportTraceServiceRequest struct {
+	ResourceSpans []*ResourceSpans `json:"resourceSpans"`
+}
+
+type ResourceSpans struct {
+	Resource   *Resource     `json:"resource"`
+	ScopeSpans []*ScopeSpans `json:"scopeSpans"`
+}
+
+type Resource struct {
+	Attributes []*KeyValue `json:"attributes"`
+}
+
+type ScopeSpans struct {
+	Scope *Scope  `json:"scope"`
+	Spans []*Span `json:"spans"`
+}
+
+type Scope struct {
+	Name string `json:"name"`
+}
+
+type Span struct {
+	TraceId           string      `json:"traceId"`
+	SpanId            string      `json:"spanId"`
+	ParentSpanId      string      `json:"parentSpanId,omitempty"`
+	Name              string      `json:"name"`
+	Kind              int         `json:"kind"`
+	StartTimeUnixNano string      `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string      `json:"endTimeUnixNano"`
+	Attributes        []*KeyValue `json:"attributes"`
+	Status            *Status     `json:"status"`
+}
+
+type Status struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type KeyValue struct {
+	Key   string    `json:"key"`
+	Value *AnyValue `json:"value"`
+}
+
+type AnyValue struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	IntValue    *string `json:"intValue,omitempty"`
+	BoolValue   *bool   `json:"boolValue,omitempty"`
+}
+
+func stringAttribute(key string, value string) *KeyValue {
+	return &KeyValue{Key: key, Value: &AnyValue{StringValue: &value}}
+}
+
+func intAttribute(key string, value int64) *KeyValue {
+	s := strconv.FormatInt(value, 10)
+	return &KeyValue{Key: key, Value: &AnyValue{IntValue: &s}}
+}
+
+func boolAttribute(key string, value bool) *KeyValue {
+	return &KeyValue{Key: key, Value: &AnyValue{BoolValue: &value}}
+}
+
+// ResourceSpan is the span of an entry along with the attributes of the resource it is reported by.
+type ResourceSpan struct {
+	Resource []*KeyValue
+	Span     *Span
+}
+
+// resourceKey identifies the resource of a span in a batch, its attributes are always in the same order.
+func (s *ResourceSpan) resourceKey() string {
+	values := make([]string, 0, len(s.Resource))
+	for _, attribute := range s.Resource {
+		values = append(values, fmt.Sprintf("%s=%s", attribute.Key, *attribute.Value.StringValue))
+	}
+	return strings.Join(values, "\x00")
+}
+
+// NewResourceSpan converts an entry into a client span of its source service, with the OpenTelemetry
+// semantic conventions of its protocol. The resource is in the namespace of the source, the namespace of
+// the destination is an attribute of the span. The span is a child of the span that sent the request, when
+// its trace context is propagated by the request headers.
+func NewResourceSpan(entry *api.Entry, summary *api.BaseEntry, peers providers.PeerNamespaces) *ResourceSpan {
+	source := providers.GetPeerName(entry.Source)
+	resource := []*KeyValue{stringAttribute("service.name", source)}
+	if peers.Source != "" {
+		resource = append(resource, stringAttribute("k8s.namespace.name", peers.Source))
+	}
+
+	span := &Span{
+		SpanId:            newId(8),
+		Name:              getSpanName(entry, summary),
+		Kind:              spanKindClient,
+		StartTimeUnixNano: strconv.FormatInt(entry.Timestamp*1e6, 10),
+		EndTimeUnixNano:   strconv.FormatInt((entry.Timestamp+entry.ElapsedTime)*1e6, 10),
+		Attributes:        getPeerAttributes(entry),
+		Status:            &Status{},
+	}
+	if peers.Destination != "" {
+		span.Attributes = append(span.Attributes, stringAttribute("kubeshark.destination.namespace", peers.Destination))
+	}
+
+	setTraceContext(span, traces.GetContext(entry))
+
+	switch entry.Protocol.Name {
+	case "http":
+		span.Attributes = append(span.Attributes, getHttpAttributes(entry, summary)...)
+	case "kafka":
+		span.Attributes = append(span.Attributes, getKafkaAttributes(entry, summary)...)
+	case "amqp":
+		span.Attributes = append(span.Attributes, getAmqpAttributes(entry, summary)...)
+	case "redis":
+		span.Attributes = append(span.Attributes, getRedisAttributes(entry, summary)...)
+	}
+
+	if statusClass, failed := providers.GetStatusClass(entry, summary); failed {
+		span.Status.Code = statusCodeError
+		span.Status.Message = statusClass
+	}
+
+	return &ResourceSpan{Resource: resource, Span: span}
+}
+
+// setTraceContext reuses the propagated trace id and parents the span to the propagated span id.
+// The request ids are not trace ids, the trace id is derived from them so their entries share a trace.
+func setTraceContext(span *Span, context *traces.Context) {
+	switch {
+	case context == nil:
+		span.TraceId = newId(16)
+	case context.Propagation == traces.PropagationRequestId:
+		sum := sha256.Sum256([]byte(context.TraceId))
+		span.TraceId = hex.EncodeToString(sum[:16])
+	default:
+		span.TraceId = fmt.Sprintf("%032s", context.TraceId)
+		span.ParentSpanId = context.SpanId
+	}
+}
+
+func newId(size int) string {
+	id := make([]byte, size)
+	_, _ = rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+func getSpanName(entry *api.Entry, summary *api.BaseEntry) string {
+	if entry.Protocol.Name == "http" {
+		return strings.Join(strings.Fields(summary.Method+" "+strings.SplitN(summary.Summary, "?", 2)[0]), " ")
+	}
+
+	name := strings.Join(strings.Fields(summary.Method+" "+summary.Summary), " ")
+	if name == "" {
+		return entry.Protocol.Name
+	}
+	return name
+}
+
+func getPeerAttributes(entry *api.Entry) []*KeyValue {
+	attributes := make([]*KeyValue, 0)
+	if entry.Destination != nil {
+		attributes = append(attributes,
+			stringAttribute("server.address", providers.GetPeerName(entry.Destination)),
+			stringAttribute("network.peer.address", entry.Destination.IP),
+		)
+		if port, err := strconv.ParseInt(entry.Destination.Port, 10, 64); err == nil {
+			attributes = append(attributes, intAttribute("server.port", port), intAttribute("network.peer.port", port))
+		}
+		if entry.Destination.Name != "" {
+			attributes = append(attributes, stringAttribute("peer.service", entry.Destination.Name))
+		}
+	}
+	if entry.Source != nil {
+		attributes = append(attributes, stringAttribute("client.address", providers.GetPeerName(entry.Source)))
+		if port, err := strconv.ParseInt(entry.Source.Port, 10, 64); err == nil {
+			attributes = append(attributes, intAttribute("client.port", port))
+		}
+	}
+
+	return append(attributes, boolAttribute("kubeshark.outgoing", entry.Outgoing))
+}
+
+func getString(object map[string]interface{}, key string) string {
+	value, _ := object[key].(string)
+	return value
+}
+
+func getHttpAttributes(entry *api.Entry, summary *api.BaseEntry) []*KeyValue {
+	attributes := []*KeyValue{
+		stringAttribute("http.request.method", summary.Method),
+		stringAttribute("url.path", strings.SplitN(summary.Summary, "?", 2)[0]),
+		stringAttribute("network.protocol.name", "http"),
+	}
+	if url := getString(entry.Request, "url"); url != "" {
+		attributes = append(attributes, stringAttribute("url.full", url))
+	}
+	if entry.Protocol.Version != "" {
+		attributes = append(attributes, stringAttribute("network.protocol.version", entry.Protocol.Version))
+	}
+	if summary.Status != 0 {
+		attributes = append(attributes, intAttribute("http.response.status_code", int64(summary.Status)))
+	}
+	if entry.RequestSize > 0 {
+		attributes = append(attributes, intAttribute("http.request.size", int64(entry.RequestSize)))
+	}
+	if entry.ResponseSize > 0 {
+		attributes = append(attributes, intAttribute("http.response.size", int64(entry.ResponseSize)))
+	}
+
+	return attributes
+}
+
+func getKafkaAttributes(entry *api.Entry, summary *api.BaseEntry) []*KeyValue {
+	attributes := []*KeyValue{
+		stringAttribute("messaging.system", "kafka"),
+		stringAttribute("messaging.operation", getMessagingOperation(summary.Method, "Produce", "Fetch")),
+	}
+	// the summary of a request to several topics lists them, the destination is a single topic
+	if (summary.Method == "Produce" || summary.Method == "Fetch") && summary.Summary != "" && !strings.Contains(summary.Summary, ", ") {
+		attributes = append(attributes, stringAttribute("messaging.destination.name", summary.Summary))
+	}
+	if clientId := getString(entry.Request, "clientID"); clientId != "" {
+		attributes = append(attributes, stringAttribute("messaging.client_id", clientId))
+	}
+
+	return attributes
+}
+
+func getAmqpAttributes(entry *api.Entry, summary *api.BaseEntry) []*KeyValue {
+	attributes := []*KeyValue{
+		stringAttribute("messaging.system", "rabbitmq"),
+		stringAttribute("messaging.operation", getMessagingOperation(summary.Method, "basic publish", "basic deliver")),
+	}
+	if exchange := getString(entry.Request, "exchange"); exchange != "" {
+		attributes = append(attributes, stringAttribute("messaging.destination.name", exchange))
+	} else if queue := getString(entry.Request, "queue"); queue != "" {
+		attributes = append(attributes, stringAttribute("messaging.destination.name", queue))
+	}
+	if routingKey := getString(entry.Request, "routingKey"); routingKey != "" {
+		attributes = append(attributes, stringAttribute("messaging.rabbitmq.destination.routing_key", routingKey))
+	}
+
+	return attributes
+}
+
+// getMessagingOperation maps the methods that publish and receive messages to their operations,
+// the other methods, e.g. the declarations, are reported as they are.
+func getMessagingOperation(method string, publish string, receive string) string {
+	switch method {
+	case publish:
+		return "publish"
+	case receive:
+		return "receive"
+	default:
+		return method
+	}
+}
+
+func getRedisAttributes(entry *api.Entry, summary *api.BaseEntry) []*KeyValue {
+	attributes := []*KeyValue{
+		stringAttribute("db.system", "redis"),
+	}
+	if summary.Method != "" {
+		attributes = append(attributes, stringAttribute("db.operation", summary.Method))
+	}
+	// the key, not the value, as the statement of the command, the values may be sensitive
+	if summary.Summary != "" {
+		attributes = append(attributes, stringAttribute("db.statement", strings.TrimSpace(summary.Method+" "+summary.Summary)))
+	}
+
+	return attributes
+}
@@ -0,0 +1,186 @@
+package annotations
+
+import (
+	"errors"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	baseApi "github.com/kubeshark/base/pkg/api"
+	"github.com/kubeshark/base/pkg/models"
+	"github.com/kubeshark/hub/pkg/utils"
+	"github.com/rs/zerolog/log"
+)
+
+const FilePath = models.DataDirPath + "annotations.json"
+
+var ErrNotFound = errors.New("annotation not found")
+
+// Annotation marks an entry with a note and tags, as a bookmark to come back to or as pinned,
+// in which case a copy of the entry is kept apart from Basenine.
+type Annotation struct {
+	EntryId    string   `json:"entryId"`
+	Note       string   `json:"note"`
+	Tags       []string `json:"tags"`
+	Author     string   `json:"author"`
+	Bookmarked bool     `json:"bookmarked"`
+	Pinned     bool     `json:"pinned"`
+	CreatedAt  int64    `json:"createdAt"`
+	UpdatedAt  int64    `json:"updatedAt"`
+}
+
+// AnnotatedBaseEntry is the summary of an entry along with its annotation, if any.
+type AnnotatedBaseEntry struct {
+	*baseApi.BaseEntry
+	Annotation *Annotation `json:"annotation,omitempty"`
+}
+
+type AnnotatedEntryWrapper struct {
+	*baseApi.EntryWrapper
+	Annotation *Annotation `json:"annotation,omitempty"`
+}
+
+// The annotations are never mutated once in the map, they are replaced by updated copies,
+// and copies are returned so the callers never share them.
+var (
+	lock        = &sync.Mutex{}
+	syncOnce    sync.Once
+	annotations map[string]*Annotation
+
+	filePath             = FilePath
+	pinnedEntriesDirPath = PinnedEntriesDirPath
+)
+
+func initAnnotations() {
+	syncOnce.Do(func() {
+		if err := utils.ReadJsonFile(filePath, &annotations); err != nil {
+			if !os.IsNotExist(err) {
+				log.Error().Err(err).Msg("While reading annotations from file.")
+			}
+		}
+
+		if annotations == nil {
+			annotations = make(map[string]*Annotation)
+		}
+	})
+}
+
+func (a *Annotation) copy() *Annotation {
+	copied := *a
+	copied.Tags = append([]string{}, a.Tags...)
+	return &copied
+}
+
+func AnnotateBaseEntry(base *baseApi.BaseEntry) *AnnotatedBaseEntry {
+	annotation, _ := Get(base.Id)
+	return &AnnotatedBaseEntry{BaseEntry: base, Annotation: annotation}
+}
+
+func AnnotateEntryWrapper(entryWrapper *baseApi.EntryWrapper, entryId string) *AnnotatedEntryWrapper {
+	annotation, _ := Get(entryId)
+	return &AnnotatedEntryWrapper{EntryWrapper: entryWrapper, Annotation: annotation}
+}
+
+func Get(entryId string) (*Annotation, error) {
+	initAnnotations()
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	annotation, ok := annotations[entryId]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return annotation.copy(), nil
+}
+
+// GetAll returns the annotations, the latest updated first, only the ones with the tag if it is not empty
+// and only the bookmarks if bookmarked is set.
+func GetAll(tag string, bookmarked bool) []*Annotation {
+	initAnnotations()
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	result := make([]*Annotation, 0, len(annotations))
+	for _, annotation := range annotations {
+		if bookmarked && !annotation.Bookmarked {
+			continue
+		}
+		if tag != "" && !hasTag(annotation, tag) {
+			continue
+		}
+		result = append(result, annotation.copy())
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].UpdatedAt != result[j].UpdatedAt {
+			return result[i].UpdatedAt > result[j].UpdatedAt
+		}
+		return result[i].EntryId < result[j].EntryId
+	})
+
+	return result
+}
+
+func hasTag(annotation *Annotation, tag string) bool {
+	for _, annotationTag := range annotation.Tags {
+		if annotationTag == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// Set creates or replaces the annotation of an entry, an entry stays pinned until it is unpinned.
+func Set(entryId string, annotation *Annotation) *Annotation {
+	initAnnotations()
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	updated := *annotation
+	updated.EntryId = entryId
+	updated.Tags = utils.UniqueStringSlice(updated.Tags)
+	updated.UpdatedAt = time.Now().UnixMilli()
+	updated.CreatedAt = updated.UpdatedAt
+	updated.Pinned = false
+	if existing, ok := annotations[entryId]; ok {
+		updated.CreatedAt = existing.CreatedAt
+		updated.Pinned = existing.Pinned
+	}
+	annotations[entryId] = &updated
+
+	save()
+	return updated.copy()
+}
+
+// Delete removes the annotation of an entry along with its pinned copy.
+func Delete(entryId string) error {
+	initAnnotations()
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	annotation, ok := annotations[entryId]
+	if !ok {
+		return ErrNotFound
+	}
+
+	if annotation.Pinned {
+		if err := removePinnedEntry(entryId); err != nil {
+			return err
+		}
+	}
+	delete(annotations, entryId)
+
+	save()
+	return nil
+}
+
+func save() {
+	if err := utils.SaveJsonFile(filePath, annotations); err != nil {
+		log.Error().Err(err).Msg("While saving annotations.")
+	}
+}
@@ -0,0 +1,138 @@
+package annotations
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+
+	baseApi "github.com/kubeshark/base/pkg/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// useDataDir keeps the annotations and the pinned entries in a data dir, they are read again on their next use.
+func useDataDir(dir string) {
+	filePath = filepath.Join(dir, "annotations.json")
+	pinnedEntriesDirPath = filepath.Join(dir, "pinned-entries")
+	annotations = nil
+	syncOnce = sync.Once{}
+}
+
+func TestSet(t *testing.T) {
+	useDataDir(t.TempDir())
+
+	created := Set("1", &Annotation{Note: "slow", Tags: []string{"perf", "perf"}, Pinned: true})
+	assert.Equal(t, "1", created.EntryId)
+	assert.Equal(t, []string{"perf"}, created.Tags)
+	assert.False(t, created.Pinned)
+	assert.Equal(t, created.CreatedAt, created.UpdatedAt)
+
+	updated := Set("1", &Annotation{Note: "slower", Bookmarked: true})
+	assert.Equal(t, "slower", updated.Note)
+	assert.Equal(t, created.CreatedAt, updated.CreatedAt)
+
+	Set("2", &Annotation{Tags: []string{"perf"}})
+	assert.Len(t, GetAll("", false), 2)
+	assert.Len(t, GetAll("perf", false), 1)
+	assert.Equal(t, []*Annotation{updated}, GetAll("", true))
+
+	_, err := Get("3")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestGetReturnsCopies(t *testing.T) {
+	useDataDir(t.TempDir())
+
+	Set("1", &Annotation{Note: "slow", Tags: []string{"perf"}})
+
+	annotation, err := Get("1")
+	require.NoError(t, err)
+	annotation.Note = "changed"
+	annotation.Tags[0] = "changed"
+	GetAll("", false)[0].Pinned = true
+
+	annotation, err = Get("1")
+	require.NoError(t, err)
+	assert.Equal(t, "slow", annotation.Note)
+	assert.Equal(t, []string{"perf"}, annotation.Tags)
+	assert.False(t, annotation.Pinned)
+}
+
+func TestPinAndUnpin(t *testing.T) {
+	useDataDir(t.TempDir())
+
+	entry := &baseApi.Entry{Id: "1", Timestamp: 1}
+	annotated := Set("1", &Annotation{Note: "slow"})
+
+	pinned, err := Pin(entry)
+	require.NoError(t, err)
+	assert.True(t, pinned.Pinned)
+	assert.Equal(t, "slow", pinned.Note)
+
+	// the annotation returned before pinning is not changed by it
+	assert.False(t, annotated.Pinned)
+	annotation, err := Get("1")
+	require.NoError(t, err)
+	assert.True(t, annotation.Pinned)
+
+	// an entry that is not annotated is annotated by pinning it
+	_, err = Pin(&baseApi.Entry{Id: "2", Timestamp: 2})
+	require.NoError(t, err)
+
+	pinnedEntry, err := GetPinnedEntry("1")
+	require.NoError(t, err)
+	assert.Equal(t, entry, pinnedEntry)
+	assert.Len(t, GetPinnedEntries(), 2)
+	assert.Equal(t, "2", GetPinnedEntries()[0].Id)
+
+	require.NoError(t, Unpin("1"))
+	assert.ErrorIs(t, Unpin("1"), ErrNotFound)
+	_, err = GetPinnedEntry("1")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	annotation, err = Get("1")
+	require.NoError(t, err)
+	assert.False(t, annotation.Pinned)
+	assert.Equal(t, "slow", annotation.Note)
+
+	_, err = Pin(&baseApi.Entry{Id: "../1"})
+	assert.Error(t, err)
+}
+
+func TestDelete(t *testing.T) {
+	useDataDir(t.TempDir())
+
+	_, err := Pin(&baseApi.Entry{Id: "1"})
+	require.NoError(t, err)
+
+	require.NoError(t, Delete("1"))
+	assert.ErrorIs(t, Delete("1"), ErrNotFound)
+
+	_, err = Get("1")
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = GetPinnedEntry("1")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestPersistence(t *testing.T) {
+	dir := t.TempDir()
+	useDataDir(dir)
+
+	annotation := Set("1", &Annotation{Note: "slow", Tags: []string{"perf"}})
+	pinned, err := Pin(&baseApi.Entry{Id: "2", Timestamp: 2})
+	require.NoError(t, err)
+
+	useDataDir(dir)
+
+	loaded, err := Get("1")
+	require.NoError(t, err)
+	assert.Equal(t, annotation, loaded)
+
+	loaded, err = Get("2")
+	require.NoError(t, err)
+	assert.Equal(t, pinned, loaded)
+
+	entries := GetPinnedEntries()
+	require.Len(t, entries, 1)
+	assert.Equal(t, "2", entries[0].Id)
+}
@@ -0,0 +1,141 @@
+package annotations
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+
+	baseApi "github.com/kubeshark/base/pkg/api"
+	"github.com/kubeshark/base/pkg/models"
+	"github.com/kubeshark/hub/pkg/utils"
+	"github.com/rs/zerolog/log"
+)
+
+// PinnedEntriesDirPath keeps a copy of every pinned entry, so it survives the eviction of Basenine's size limit.
+const PinnedEntriesDirPath = models.DataDirPath + "pinned-entries/"
+
+var entryIdPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+func getPinnedEntryPath(entryId string) (string, error) {
+	if !entryIdPattern.MatchString(entryId) {
+		return "", fmt.Errorf("invalid entry id: %q", entryId)
+	}
+	return filepath.Join(pinnedEntriesDirPath, entryId+".json"), nil
+}
+
+// Pin copies an entry to the pinned entries and marks it as pinned, annotating it if it is not.
+func Pin(entry *baseApi.Entry) (*Annotation, error) {
+	path, err := getPinnedEntryPath(entry.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	initAnnotations()
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	if err := os.MkdirAll(pinnedEntriesDirPath, 0755); err != nil {
+		return nil, err
+	}
+	if err := utils.SaveJsonFile(path, entry); err != nil {
+		return nil, err
+	}
+
+	var pinned *Annotation
+	if existing, ok := annotations[entry.Id]; ok {
+		pinned = existing.copy()
+	} else {
+		now := time.Now().UnixMilli()
+		pinned = &Annotation{EntryId: entry.Id, Tags: []string{}, CreatedAt: now, UpdatedAt: now}
+	}
+	pinned.Pinned = true
+	annotations[entry.Id] = pinned
+
+	save()
+	return pinned.copy(), nil
+}
+
+// Unpin removes the pinned copy of an entry, its annotation stays.
+func Unpin(entryId string) error {
+	initAnnotations()
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	annotation, ok := annotations[entryId]
+	if !ok || !annotation.Pinned {
+		return ErrNotFound
+	}
+
+	if err := removePinnedEntry(entryId); err != nil {
+		return err
+	}
+	unpinned := annotation.copy()
+	unpinned.Pinned = false
+	annotations[entryId] = unpinned
+
+	save()
+	return nil
+}
+
+func removePinnedEntry(entryId string) error {
+	path, err := getPinnedEntryPath(entryId)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func GetPinnedEntry(entryId string) (*baseApi.Entry, error) {
+	path, err := getPinnedEntryPath(entryId)
+	if err != nil {
+		return nil, err
+	}
+
+	var entry *baseApi.Entry
+	if err := utils.ReadJsonFile(path, &entry); err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return entry, nil
+}
+
+// GetPinnedEntries returns the pinned entries, the latest first.
+func GetPinnedEntries() []*baseApi.Entry {
+	initAnnotations()
+
+	lock.Lock()
+	entryIds := make([]string, 0)
+	for entryId, annotation := range annotations {
+		if annotation.Pinned {
+			entryIds = append(entryIds, entryId)
+		}
+	}
+	lock.Unlock()
+
+	pinnedEntries := make([]*baseApi.Entry, 0, len(entryIds))
+	for _, entryId := range entryIds {
+		entry, err := GetPinnedEntry(entryId)
+		if err != nil {
+			log.Error().Err(err).Str("id", entryId).Msg("While reading pinned entry:")
+			continue
+		}
+		pinnedEntries = append(pinnedEntries, entry)
+	}
+
+	sort.Slice(pinnedEntries, func(i, j int) bool {
+		return pinnedEntries[i].Timestamp > pinnedEntries[j].Timestamp
+	})
+
+	return pinnedEntries
+}
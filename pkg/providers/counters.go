@@ -0,0 +1,53 @@
+package providers
+
+import (
+	"sync"
+
+	"github.com/kubeshark/base/pkg/api"
+)
+
+// IngestionCounter is the cumulative count of the entries of a protocol and method since the start of the hub.
+// Unlike the general stats, the counters are never reset, so they can be exported as monotonic counters.
+type IngestionCounter struct {
+	Protocol      string
+	Method        string
+	EntriesCount  int
+	VolumeInBytes int
+}
+
+type ingestionKey struct {
+	protocol string
+	method   string
+}
+
+var (
+	ingestionCounters       = map[ingestionKey]*IngestionCounter{}
+	ingestionCountersLocker = sync.Mutex{}
+)
+
+func addToIngestionCounters(size int, summery *api.BaseEntry) {
+	ingestionCountersLocker.Lock()
+	defer ingestionCountersLocker.Unlock()
+
+	k := ingestionKey{protocol: summery.Protocol.Abbreviation, method: summery.Method}
+	counter, found := ingestionCounters[k]
+	if !found {
+		counter = &IngestionCounter{Protocol: k.protocol, Method: k.method}
+		ingestionCounters[k] = counter
+	}
+
+	counter.EntriesCount++
+	counter.VolumeInBytes += size
+}
+
+// GetIngestionCounters returns a copy of the ingestion counters.
+func GetIngestionCounters() []IngestionCounter {
+	ingestionCountersLocker.Lock()
+	defer ingestionCountersLocker.Unlock()
+
+	counters := make([]IngestionCounter, 0, len(ingestionCounters))
+	for _, counter := range ingestionCounters {
+		counters = append(counters, *counter)
+	}
+	return counters
+}
@@ -0,0 +1,32 @@
+package providers
+
+import (
+	"fmt"
+
+	"github.com/kubeshark/base/pkg/api"
+)
+
+// GetPeerName names the source or the destination of an entry by its resolved name, or its IP if it is unresolved.
+func GetPeerName(tcp *api.TCP) string {
+	if tcp == nil {
+		return ""
+	}
+	if tcp.Name != "" {
+		return tcp.Name
+	}
+	return tcp.IP
+}
+
+func GetPeerIP(tcp *api.TCP) string {
+	if tcp == nil {
+		return ""
+	}
+	return tcp.IP
+}
+
+// GetEntryQuery returns the query that finds an entry in the database, the entries are referred to by it
+// while they are ingested, as their ids are assigned only once they are inserted.
+func GetEntryQuery(timestamp int64, elapsedTime int64, sourceIP string, destIP string) string {
+	return fmt.Sprintf(`timestamp == %d and elapsedTime == %d and src.ip == "%s" and dst.ip == "%s"`,
+		timestamp, elapsedTime, sourceIP, destIP)
+}
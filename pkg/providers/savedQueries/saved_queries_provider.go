@@ -0,0 +1,188 @@
+package savedQueries
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/kubeshark/base/pkg/models"
+	"github.com/kubeshark/hub/pkg/utils"
+	"github.com/rs/zerolog/log"
+)
+
+const FilePath = models.DataDirPath + "saved-queries.json"
+
+var (
+	ErrNotFound      = errors.New("saved query not found")
+	ErrAlreadyExists = errors.New("saved query already exists")
+
+	namePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+)
+
+type SavedQuery struct {
+	Name        string   `json:"name"`
+	Query       string   `json:"query"`
+	Description string   `json:"description"`
+	Owner       string   `json:"owner"`
+	Tags        []string `json:"tags"`
+	CreatedAt   int64    `json:"createdAt"`
+	UpdatedAt   int64    `json:"updatedAt"`
+}
+
+var (
+	lock         = &sync.Mutex{}
+	syncOnce     sync.Once
+	savedQueries map[string]*SavedQuery
+
+	filePath = FilePath
+)
+
+func initSavedQueries() {
+	syncOnce.Do(func() {
+		if err := utils.ReadJsonFile(filePath, &savedQueries); err != nil {
+			if !os.IsNotExist(err) {
+				log.Error().Err(err).Msg("While reading saved queries from file.")
+			}
+		}
+
+		if savedQueries == nil {
+			savedQueries = make(map[string]*SavedQuery)
+		}
+	})
+}
+
+// ValidateName checks that a name can be used in a URL and as the reference of a query, e.g. errors-of-carts.
+func ValidateName(name string) error {
+	if !namePattern.MatchString(name) {
+		return fmt.Errorf("invalid name: %q, must start with a letter or a digit followed by letters, digits, '_', '.' or '-'", name)
+	}
+	return nil
+}
+
+// GetAll returns the saved queries sorted by name, only the ones with the tag if it is not empty.
+func GetAll(tag string) []*SavedQuery {
+	initSavedQueries()
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	result := make([]*SavedQuery, 0, len(savedQueries))
+	for _, savedQuery := range savedQueries {
+		if tag == "" || hasTag(savedQuery, tag) {
+			result = append(result, savedQuery)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+
+	return result
+}
+
+func hasTag(savedQuery *SavedQuery, tag string) bool {
+	for _, savedQueryTag := range savedQuery.Tags {
+		if savedQueryTag == tag {
+			return true
+		}
+	}
+	return false
+}
+
+func Get(name string) (*SavedQuery, error) {
+	initSavedQueries()
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	savedQuery, ok := savedQueries[name]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return savedQuery, nil
+}
+
+func Create(savedQuery *SavedQuery) (*SavedQuery, error) {
+	initSavedQueries()
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	if _, ok := savedQueries[savedQuery.Name]; ok {
+		return nil, ErrAlreadyExists
+	}
+
+	created := *savedQuery
+	created.Tags = utils.UniqueStringSlice(created.Tags)
+	created.CreatedAt = time.Now().UnixMilli()
+	created.UpdatedAt = created.CreatedAt
+	savedQueries[created.Name] = &created
+
+	if err := save(); err != nil {
+		delete(savedQueries, created.Name)
+		return nil, err
+	}
+	return &created, nil
+}
+
+// Update replaces the saved query of the name, it is renamed if the name of the new one is different.
+// The saved query is left as it was if the change cannot be saved.
+func Update(name string, savedQuery *SavedQuery) (*SavedQuery, error) {
+	initSavedQueries()
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	existing, ok := savedQueries[name]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if _, ok := savedQueries[savedQuery.Name]; ok && savedQuery.Name != name {
+		return nil, ErrAlreadyExists
+	}
+
+	updated := *savedQuery
+	updated.Tags = utils.UniqueStringSlice(updated.Tags)
+	updated.CreatedAt = existing.CreatedAt
+	updated.UpdatedAt = time.Now().UnixMilli()
+	delete(savedQueries, name)
+	savedQueries[updated.Name] = &updated
+
+	if err := save(); err != nil {
+		delete(savedQueries, updated.Name)
+		savedQueries[name] = existing
+		return nil, err
+	}
+	return &updated, nil
+}
+
+func Delete(name string) error {
+	initSavedQueries()
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	existing, ok := savedQueries[name]
+	if !ok {
+		return ErrNotFound
+	}
+	delete(savedQueries, name)
+
+	if err := save(); err != nil {
+		savedQueries[name] = existing
+		return err
+	}
+	return nil
+}
+
+func save() error {
+	if err := utils.SaveJsonFile(filePath, savedQueries); err != nil {
+		log.Error().Err(err).Msg("While saving saved queries.")
+		return fmt.Errorf("saving the saved queries: %v", err)
+	}
+	return nil
+}
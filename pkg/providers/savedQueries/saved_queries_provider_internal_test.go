@@ -0,0 +1,121 @@
+package savedQueries
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// useFile keeps the saved queries in a file, they are read again on their next use.
+func useFile(path string) {
+	filePath = path
+	savedQueries = nil
+	syncOnce = sync.Once{}
+}
+
+func TestCreateGetAndDelete(t *testing.T) {
+	useFile(filepath.Join(t.TempDir(), "saved-queries.json"))
+
+	created, err := Create(&SavedQuery{Name: "errors", Query: "response.status >= 500", Tags: []string{"http", "http"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"http"}, created.Tags)
+	assert.NotZero(t, created.CreatedAt)
+	assert.Equal(t, created.CreatedAt, created.UpdatedAt)
+
+	_, err = Create(&SavedQuery{Name: "errors"})
+	assert.ErrorIs(t, err, ErrAlreadyExists)
+
+	_, err = Create(&SavedQuery{Name: "carts", Query: `dst.name == "carts"`})
+	require.NoError(t, err)
+
+	savedQuery, err := Get("errors")
+	require.NoError(t, err)
+	assert.Equal(t, created, savedQuery)
+
+	names := make([]string, 0)
+	for _, savedQuery := range GetAll("") {
+		names = append(names, savedQuery.Name)
+	}
+	assert.Equal(t, []string{"carts", "errors"}, names)
+	assert.Equal(t, []*SavedQuery{created}, GetAll("http"))
+
+	require.NoError(t, Delete("errors"))
+	assert.ErrorIs(t, Delete("errors"), ErrNotFound)
+	_, err = Get("errors")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestUpdate(t *testing.T) {
+	useFile(filepath.Join(t.TempDir(), "saved-queries.json"))
+
+	created, err := Create(&SavedQuery{Name: "errors", Query: "response.status >= 500"})
+	require.NoError(t, err)
+	_, err = Create(&SavedQuery{Name: "carts", Query: `dst.name == "carts"`})
+	require.NoError(t, err)
+
+	updated, err := Update("errors", &SavedQuery{Name: "errors", Query: "response.status >= 400"})
+	require.NoError(t, err)
+	assert.Equal(t, "response.status >= 400", updated.Query)
+	assert.Equal(t, created.CreatedAt, updated.CreatedAt)
+
+	renamed, err := Update("errors", &SavedQuery{Name: "failures", Query: "response.status >= 400"})
+	require.NoError(t, err)
+	assert.Equal(t, "failures", renamed.Name)
+	_, err = Get("errors")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// renaming onto another saved query is a conflict
+	_, err = Update("failures", &SavedQuery{Name: "carts"})
+	assert.ErrorIs(t, err, ErrAlreadyExists)
+	savedQuery, err := Get("carts")
+	require.NoError(t, err)
+	assert.Equal(t, `dst.name == "carts"`, savedQuery.Query)
+
+	_, err = Update("unknown", &SavedQuery{Name: "unknown"})
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "saved-queries.json")
+	useFile(path)
+
+	created, err := Create(&SavedQuery{Name: "errors", Query: "response.status >= 500", Tags: []string{"http"}})
+	require.NoError(t, err)
+
+	useFile(path)
+
+	savedQuery, err := Get("errors")
+	require.NoError(t, err)
+	assert.Equal(t, created, savedQuery)
+}
+
+func TestFailedSaveRollsBack(t *testing.T) {
+	dir := t.TempDir()
+	useFile(filepath.Join(dir, "saved-queries.json"))
+
+	created, err := Create(&SavedQuery{Name: "errors", Query: "response.status >= 500"})
+	require.NoError(t, err)
+
+	// the file cannot be written in a missing directory
+	filePath = filepath.Join(dir, "missing", "saved-queries.json")
+
+	_, err = Create(&SavedQuery{Name: "carts"})
+	assert.Error(t, err)
+	_, err = Get("carts")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	_, err = Update("errors", &SavedQuery{Name: "failures", Query: "response.status >= 400"})
+	assert.Error(t, err)
+	_, err = Get("failures")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	assert.Error(t, Delete("errors"))
+
+	savedQuery, err := Get("errors")
+	require.NoError(t, err)
+	assert.Equal(t, created, savedQuery)
+	assert.Len(t, GetAll(""), 1)
+}
@@ -0,0 +1,240 @@
+package providers
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kubeshark/base/pkg/api"
+)
+
+const (
+	GroupByProtocol  = "protocol"
+	GroupByNamespace = "namespace"
+	GroupByService   = "service"
+	GroupByPair      = "pair"
+
+	// MaxFlowsPerBucket bounds the breakdown (and the usage) of a bucket, the flows beyond it are counted under
+	// OtherFlowName. Every tier keeps it, so it stays low for the buckets to be cheap to query and to save.
+	MaxFlowsPerBucket = 500
+	OtherFlowName     = "other"
+
+	pairSeparator = " -> "
+)
+
+// FlowStats counts the entries of a protocol and method from a source to a destination service within a bucket.
+type FlowStats struct {
+	Protocol      string `json:"protocol"`
+	Method        string `json:"method"`
+	Namespace     string `json:"namespace"`
+	Source        string `json:"source"`
+	Service       string `json:"service"`
+	EntriesCount  int    `json:"entriesCount"`
+	VolumeInBytes int    `json:"volumeInBytes"`
+	FailuresCount int    `json:"failuresCount,omitempty"`
+}
+
+type flowKey struct {
+	protocol  string
+	method    string
+	namespace string
+	source    string
+	service   string
+}
+
+func (f *FlowStats) key() flowKey {
+	return flowKey{protocol: f.Protocol, method: f.Method, namespace: f.Namespace, source: f.Source, service: f.Service}
+}
+
+// StatsFilter keeps the flows matching all of its non-empty fields.
+type StatsFilter struct {
+	Protocol  string
+	Method    string
+	Namespace string
+	Source    string
+	Service   string
+}
+
+type TrafficPair struct {
+	Source          string `json:"source"`
+	Service         string `json:"service"`
+	EntriesCount    int    `json:"entriesCount"`
+	VolumeSizeBytes int    `json:"volumeSizeBytes"`
+}
+
+type TrafficMatrixResponse struct {
+	Pairs []*TrafficPair `json:"pairs"`
+}
+
+// ParseStatsFilter parses the filters in the form of key:value, e.g. namespace:default.
+func ParseStatsFilter(filters []string) (*StatsFilter, error) {
+	filter := &StatsFilter{}
+	for _, f := range filters {
+		parts := strings.SplitN(f, ":", 2)
+		if len(parts) != 2 || parts[1] == "" {
+			return nil, fmt.Errorf("invalid filter: %s", f)
+		}
+
+		switch parts[0] {
+		case "protocol":
+			filter.Protocol = parts[1]
+		case "method":
+			filter.Method = parts[1]
+		case "namespace":
+			filter.Namespace = parts[1]
+		case "source":
+			filter.Source = parts[1]
+		case "service":
+			filter.Service = parts[1]
+		default:
+			return nil, fmt.Errorf("invalid filter key: %s", parts[0])
+		}
+	}
+
+	return filter, nil
+}
+
+func (f *StatsFilter) matches(flow *FlowStats) bool {
+	return (f.Protocol == "" || f.Protocol == flow.Protocol) &&
+		(f.Method == "" || f.Method == flow.Method) &&
+		(f.Namespace == "" || f.Namespace == flow.Namespace) &&
+		(f.Source == "" || f.Source == flow.Source) &&
+		(f.Service == "" || f.Service == flow.Service)
+}
+
+func addToBucketFlows(bucketOfEntry *TimeFrameStatsValue, size int, summery *api.BaseEntry, namespace string, failed bool) {
+	if bucketOfEntry.flowIndex == nil {
+		bucketOfEntry.flowIndex = make(map[flowKey]*FlowStats, len(bucketOfEntry.Flows))
+		for _, flow := range bucketOfEntry.Flows {
+			bucketOfEntry.flowIndex[flow.key()] = flow
+		}
+	}
+
+	k := flowKey{
+		protocol:  summery.Protocol.Abbreviation,
+		method:    summery.Method,
+		namespace: namespace,
+		source:    GetPeerName(summery.Source),
+		service:   GetPeerName(summery.Destination),
+	}
+
+	flow, found := bucketOfEntry.flowIndex[k]
+	if !found && len(bucketOfEntry.Flows) >= MaxFlowsPerBucket {
+		k = flowKey{protocol: k.protocol, method: k.method, namespace: OtherFlowName, source: OtherFlowName, service: OtherFlowName}
+		flow, found = bucketOfEntry.flowIndex[k]
+	}
+	if !found {
+		flow = &FlowStats{Protocol: k.protocol, Method: k.method, Namespace: k.namespace, Source: k.source, Service: k.service}
+		bucketOfEntry.Flows = append(bucketOfEntry.Flows, flow)
+		bucketOfEntry.flowIndex[k] = flow
+	}
+
+	flow.EntriesCount++
+	flow.VolumeInBytes += size
+	if failed {
+		flow.FailuresCount++
+	}
+}
+
+func getGroupName(flow *FlowStats, groupBy string) (group string, sub string) {
+	switch groupBy {
+	case GroupByNamespace:
+		return flow.Namespace, flow.Protocol
+	case GroupByService:
+		return flow.Service, flow.Protocol
+	case GroupByPair:
+		return flow.Source + pairSeparator + flow.Service, flow.Protocol
+	default:
+		return flow.Protocol, flow.Method
+	}
+}
+
+// groupBucket regroups the flows of the bucket matching the filter, so the protocols of the returned bucket
+// are the groups and their methods are the protocols (or the methods if grouped by protocol).
+func groupBucket(bucket *TimeFrameStatsValue, groupBy string, filter *StatsFilter) *TimeFrameStatsValue {
+	groupedBucket := &TimeFrameStatsValue{
+		BucketTime:    bucket.BucketTime,
+		ProtocolStats: map[string]ProtocolStats{},
+	}
+
+	for _, flow := range bucket.Flows {
+		if !filter.matches(flow) {
+			continue
+		}
+
+		group, sub := getGroupName(flow, groupBy)
+		if _, found := groupedBucket.ProtocolStats[group]; !found {
+			groupedBucket.ProtocolStats[group] = ProtocolStats{MethodsStats: map[string]*SizeAndEntriesCount{}}
+		}
+		if _, found := groupedBucket.ProtocolStats[group].MethodsStats[sub]; !found {
+			groupedBucket.ProtocolStats[group].MethodsStats[sub] = &SizeAndEntriesCount{}
+		}
+
+		groupedBucket.ProtocolStats[group].MethodsStats[sub].EntriesCount += flow.EntriesCount
+		groupedBucket.ProtocolStats[group].MethodsStats[sub].VolumeInBytes += flow.VolumeInBytes
+		groupedBucket.ProtocolStats[group].MethodsStats[sub].FailuresCount += flow.FailuresCount
+	}
+
+	return groupedBucket
+}
+
+// GetTrafficStatsBy returns the traffic stats grouped by protocol, namespace, destination service or source and
+// destination pair, counting only the traffic matching the filter.
+func GetTrafficStatsBy(startTime time.Time, endTime time.Time, groupBy string, filter *StatsFilter) (*TrafficStatsResponse, error) {
+	if groupBy != GroupByProtocol && groupBy != GroupByNamespace && groupBy != GroupByService && groupBy != GroupByPair {
+		return nil, fmt.Errorf("invalid groupBy: %s", groupBy)
+	}
+
+	bucketsStatsCopy := BucketStats{}
+	forEachFilteredBucket(startTime, endTime, func(bucket *TimeFrameStatsValue) {
+		bucketsStatsCopy = append(bucketsStatsCopy, groupBucket(bucket, groupBy, filter))
+	})
+
+	return &TrafficStatsResponse{
+		Protocols:     getAvailableProtocols(bucketsStatsCopy),
+		PieStats:      getAccumulativeStats(bucketsStatsCopy),
+		TimelineStats: getAccumulativeStatsTiming(bucketsStatsCopy),
+	}, nil
+}
+
+// GetTrafficMatrix returns the source and destination pairs with the most entries.
+// A limit less than 1 returns every pair.
+func GetTrafficMatrix(startTime time.Time, endTime time.Time, filter *StatsFilter, limit int) *TrafficMatrixResponse {
+	pairs := map[string]*TrafficPair{}
+	forEachFilteredBucket(startTime, endTime, func(bucket *TimeFrameStatsValue) {
+		for _, flow := range bucket.Flows {
+			if !filter.matches(flow) {
+				continue
+			}
+
+			pairKey := flow.Source + pairSeparator + flow.Service
+			if _, found := pairs[pairKey]; !found {
+				pairs[pairKey] = &TrafficPair{Source: flow.Source, Service: flow.Service}
+			}
+			pairs[pairKey].EntriesCount += flow.EntriesCount
+			pairs[pairKey].VolumeSizeBytes += flow.VolumeInBytes
+		}
+	})
+
+	response := &TrafficMatrixResponse{Pairs: make([]*TrafficPair, 0, len(pairs))}
+	for _, pair := range pairs {
+		response.Pairs = append(response.Pairs, pair)
+	}
+
+	sort.Slice(response.Pairs, func(i, j int) bool {
+		if response.Pairs[i].EntriesCount != response.Pairs[j].EntriesCount {
+			return response.Pairs[i].EntriesCount > response.Pairs[j].EntriesCount
+		}
+		if response.Pairs[i].Source != response.Pairs[j].Source {
+			return response.Pairs[i].Source < response.Pairs[j].Source
+		}
+		return response.Pairs[i].Service < response.Pairs[j].Service
+	})
+
+	if limit > 0 && len(response.Pairs) > limit {
+		response.Pairs = response.Pairs[:limit]
+	}
+
+	return response
+}
@@ -0,0 +1,147 @@
+package providers
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/kubeshark/base/pkg/api"
+)
+
+func addMockFlow(entryTime time.Time, protocol string, method string, namespace string, source string, service string, size int) {
+	addToBucketStats(size, nil, &api.BaseEntry{
+		Protocol:    api.Protocol{ProtocolSummary: api.ProtocolSummary{Abbreviation: protocol}},
+		Method:      method,
+		Timestamp:   entryTime.UnixMilli(),
+		Source:      &api.TCP{Name: source, IP: "10.0.0.1"},
+		Destination: &api.TCP{Name: service, IP: "10.0.0.2"},
+	}, namespace)
+}
+
+func addMockFlows(start time.Time) {
+	addMockFlow(start, "HTTP", "GET", "shop", "frontend", "carts", 10)
+	addMockFlow(start, "HTTP", "GET", "shop", "frontend", "carts", 10)
+	addMockFlow(start.Add(time.Minute), "HTTP", "POST", "shop", "frontend", "orders", 20)
+	addMockFlow(start.Add(time.Minute), "REDIS", "GET", "shop", "carts", "redis", 5)
+	addMockFlow(start.Add(2*time.Minute), "AMQP", "basic.publish", "billing", "orders", "rabbitmq", 100)
+}
+
+func pieCounts(response *TrafficStatsResponse) map[string]int {
+	counts := map[string]int{}
+	for _, protocol := range response.PieStats {
+		counts[protocol.Name] = protocol.EntriesCount
+	}
+	return counts
+}
+
+func TestParseStatsFilter(t *testing.T) {
+	tests := map[string]struct {
+		filters  []string
+		expected *StatsFilter
+		isError  bool
+	}{
+		"empty":        {filters: nil, expected: &StatsFilter{}},
+		"single":       {filters: []string{"namespace:shop"}, expected: &StatsFilter{Namespace: "shop"}},
+		"multiple":     {filters: []string{"protocol:HTTP", "service:carts", "source:a:b"}, expected: &StatsFilter{Protocol: "HTTP", Service: "carts", Source: "a:b"}},
+		"missing":      {filters: []string{"namespace"}, isError: true},
+		"empty value":  {filters: []string{"namespace:"}, isError: true},
+		"invalid name": {filters: []string{"pod:x"}, isError: true},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			actual, err := ParseStatsFilter(test.filters)
+			if test.isError {
+				if err == nil {
+					t.Errorf("unexpected result - expected an error")
+				}
+				return
+			}
+			if !reflect.DeepEqual(test.expected, actual) {
+				t.Errorf("unexpected result - expected: %v, actual: %v", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestGetTrafficStatsBy(t *testing.T) {
+	resetStatsTiers()
+	t.Cleanup(resetStatsTiers)
+
+	start := time.Date(2022, time.Month(1), 1, 10, 0, 0, 0, time.UTC)
+	addMockFlows(start)
+
+	tests := map[string]struct {
+		groupBy  string
+		filter   *StatsFilter
+		expected map[string]int
+	}{
+		"protocol":            {groupBy: GroupByProtocol, filter: &StatsFilter{}, expected: map[string]int{"HTTP": 3, "REDIS": 1, "AMQP": 1}},
+		"namespace":           {groupBy: GroupByNamespace, filter: &StatsFilter{}, expected: map[string]int{"shop": 4, "billing": 1}},
+		"service":             {groupBy: GroupByService, filter: &StatsFilter{Namespace: "shop"}, expected: map[string]int{"carts": 2, "orders": 1, "redis": 1}},
+		"pair":                {groupBy: GroupByPair, filter: &StatsFilter{Protocol: "HTTP"}, expected: map[string]int{"frontend -> carts": 2, "frontend -> orders": 1}},
+		"nothing is matching": {groupBy: GroupByPair, filter: &StatsFilter{Method: "DELETE"}, expected: map[string]int{}},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			actual, err := GetTrafficStatsBy(start, start.Add(time.Hour), test.groupBy, test.filter)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(test.expected, pieCounts(actual)) {
+				t.Errorf("unexpected result - expected: %v, actual: %v", test.expected, pieCounts(actual))
+			}
+			if len(actual.Protocols) != len(test.expected)+1 {
+				t.Errorf("unexpected result - expected: %v, actual: %v", len(test.expected)+1, len(actual.Protocols))
+			}
+		})
+	}
+
+	if _, err := GetTrafficStatsBy(start, start.Add(time.Hour), "pod", &StatsFilter{}); err == nil {
+		t.Errorf("unexpected result - expected an error")
+	}
+}
+
+func TestGetTrafficMatrix(t *testing.T) {
+	resetStatsTiers()
+	t.Cleanup(resetStatsTiers)
+
+	start := time.Date(2022, time.Month(1), 1, 10, 0, 0, 0, time.UTC)
+	addMockFlows(start)
+
+	actual := GetTrafficMatrix(start, start.Add(time.Hour), &StatsFilter{}, 2)
+	expected := []*TrafficPair{
+		{Source: "frontend", Service: "carts", EntriesCount: 2, VolumeSizeBytes: 20},
+		{Source: "carts", Service: "redis", EntriesCount: 1, VolumeSizeBytes: 5},
+	}
+	if !reflect.DeepEqual(expected, actual.Pairs) {
+		t.Errorf("unexpected result - expected: %v, actual: %v", expected, actual.Pairs)
+	}
+
+	actual = GetTrafficMatrix(start, start.Add(time.Hour), &StatsFilter{Namespace: "billing"}, 0)
+	if len(actual.Pairs) != 1 || actual.Pairs[0].VolumeSizeBytes != 100 {
+		t.Errorf("unexpected result - expected: %v, actual: %v", "orders -> rabbitmq", actual.Pairs)
+	}
+}
+
+func TestMaxFlowsPerBucket(t *testing.T) {
+	resetStatsTiers()
+	t.Cleanup(resetStatsTiers)
+
+	start := time.Date(2022, time.Month(1), 1, 10, 0, 0, 0, time.UTC)
+	for i := 0; i < MaxFlowsPerBucket+5; i++ {
+		addMockFlow(start, "HTTP", "GET", "shop", fmt.Sprintf("source-%d", i), "carts", 1)
+	}
+
+	bucket := statsTiers[0].getBuckets(start, start)[0]
+	if len(bucket.Flows) != MaxFlowsPerBucket+1 {
+		t.Errorf("unexpected result - expected: %v, actual: %v", MaxFlowsPerBucket+1, len(bucket.Flows))
+	}
+
+	other := bucket.Flows[len(bucket.Flows)-1]
+	if other.Source != OtherFlowName || other.EntriesCount != 5 {
+		t.Errorf("unexpected result - expected: %v, actual: %v", "5 other entries", other)
+	}
+}
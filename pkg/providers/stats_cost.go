@@ -0,0 +1,308 @@
+package providers
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kubeshark/base/pkg/api"
+)
+
+const (
+	TrafficIntraNamespace = "intra"
+	TrafficCrossNamespace = "cross"
+	TrafficExternal       = "external"
+
+	// UnresolvedWorkloadName is the workload of the traffic whose peer of the namespace is not resolved to a name.
+	UnresolvedWorkloadName = "unresolved"
+)
+
+// PeerNamespaces are the namespaces the source and the destination of an entry are resolved to, empty if unresolved.
+type PeerNamespaces struct {
+	Source      string
+	Destination string
+}
+
+// UsageStats counts the traffic of a workload of a namespace by whether it stays within the namespace,
+// crosses into another one or has an unresolved peer. The bytes of the cross-namespace traffic are split between the
+// two namespaces, while its entries are counted in the namespace of the destination only.
+type UsageStats struct {
+	Namespace     string `json:"namespace"`
+	Workload      string `json:"workload"`
+	Traffic       string `json:"traffic"`
+	EntriesCount  int    `json:"entriesCount"`
+	VolumeInBytes int    `json:"volumeInBytes"`
+}
+
+type usageKey struct {
+	namespace string
+	workload  string
+	traffic   string
+}
+
+func (u *UsageStats) key() usageKey {
+	return usageKey{namespace: u.Namespace, workload: u.Workload, traffic: u.Traffic}
+}
+
+// usageShare is the part of the traffic of an entry charged to a workload.
+type usageShare struct {
+	key     usageKey
+	entries int
+	size    int
+}
+
+// CostPrices are the prices per GB of the traffic, the observability price applies to all of it.
+type CostPrices struct {
+	Observability float64 `json:"observability"`
+	Intra         float64 `json:"intra"`
+	Cross         float64 `json:"cross"`
+	External      float64 `json:"external"`
+}
+
+type CostUsage struct {
+	EntriesCount  int     `json:"entriesCount"`
+	VolumeInBytes int     `json:"volumeInBytes"`
+	IntraBytes    int     `json:"intraNamespaceBytes"`
+	CrossBytes    int     `json:"crossNamespaceBytes"`
+	ExternalBytes int     `json:"externalBytes"`
+	EstimatedCost float64 `json:"estimatedCost"`
+}
+
+type WorkloadCost struct {
+	Workload string `json:"workload"`
+	CostUsage
+}
+
+type NamespaceCost struct {
+	Namespace string `json:"namespace"`
+	CostUsage
+	Workloads []*WorkloadCost `json:"workloads"`
+}
+
+type CostReport struct {
+	StartTime  int64            `json:"startTime"`
+	EndTime    int64            `json:"endTime"`
+	Prices     CostPrices       `json:"prices"`
+	Total      CostUsage        `json:"total"`
+	Namespaces []*NamespaceCost `json:"namespaces"`
+}
+
+var defaultCostPrices = CostPrices{}
+
+func SetDefaultCostPrices(prices CostPrices) {
+	defaultCostPrices = prices
+}
+
+// ParseCostPrices overrides the default prices with the ones in the form of traffic:price, e.g. cross:0.01.
+func ParseCostPrices(prices []string) (CostPrices, error) {
+	parsed := defaultCostPrices
+	for _, p := range prices {
+		parts := strings.SplitN(p, ":", 2)
+		if len(parts) != 2 {
+			return parsed, fmt.Errorf("invalid price: %s", p)
+		}
+
+		value, err := strconv.ParseFloat(parts[1], 64)
+		if err != nil || value < 0 || math.IsNaN(value) || math.IsInf(value, 0) {
+			return parsed, fmt.Errorf("invalid price: %s", p)
+		}
+
+		switch parts[0] {
+		case "observability":
+			parsed.Observability = value
+		case TrafficIntraNamespace:
+			parsed.Intra = value
+		case TrafficCrossNamespace:
+			parsed.Cross = value
+		case TrafficExternal:
+			parsed.External = value
+		default:
+			return parsed, fmt.Errorf("invalid price key: %s", parts[0])
+		}
+	}
+
+	return parsed, nil
+}
+
+// getUsageKey attributes the traffic to the namespace of its destination, or of its source if the destination
+// is unresolved, so the egress of a namespace to the outside of the cluster is charged to it.
+func getUsageKey(summery *api.BaseEntry, namespace string, peers PeerNamespaces) usageKey {
+	k := usageKey{namespace: namespace}
+
+	var peer *api.TCP
+	switch {
+	case peers.Destination != "":
+		k.namespace = peers.Destination
+		peer = summery.Destination
+	case peers.Source != "":
+		k.namespace = peers.Source
+		peer = summery.Source
+	}
+
+	k.workload = getWorkloadName(peer)
+
+	switch {
+	case peers.Source == "" || peers.Destination == "":
+		k.traffic = TrafficExternal
+	case peers.Source == peers.Destination:
+		k.traffic = TrafficIntraNamespace
+	default:
+		k.traffic = TrafficCrossNamespace
+	}
+
+	return k
+}
+
+func getWorkloadName(peer *api.TCP) string {
+	if peer != nil && peer.Name != "" {
+		return peer.Name
+	}
+
+	return UnresolvedWorkloadName
+}
+
+// getUsageShares charges the traffic to its key, see getUsageKey. The cross-namespace traffic is shared with the
+// source namespace instead, which gets half of the bytes (rounded down) and none of the entries, so the totals of
+// the report still add up to the traffic.
+func getUsageShares(size int, summery *api.BaseEntry, namespace string, peers PeerNamespaces) []usageShare {
+	k := getUsageKey(summery, namespace, peers)
+	if k.traffic != TrafficCrossNamespace {
+		return []usageShare{{key: k, entries: 1, size: size}}
+	}
+
+	sourceKey := usageKey{namespace: peers.Source, workload: getWorkloadName(summery.Source), traffic: TrafficCrossNamespace}
+	return []usageShare{
+		{key: k, entries: 1, size: size - size/2},
+		{key: sourceKey, size: size / 2},
+	}
+}
+
+func addToUsageStats(size int, summery *api.BaseEntry, namespace string, peers PeerNamespaces) {
+	entryTime := time.UnixMilli(summery.Timestamp)
+	shares := getUsageShares(size, summery, namespace, peers)
+
+	for _, tier := range statsTiers {
+		bucketOfEntry := tier.getBucket(roundToInterval(entryTime, tier.interval))
+		if bucketOfEntry == nil {
+			continue
+		}
+
+		for _, share := range shares {
+			addToBucketUsage(bucketOfEntry, share)
+		}
+	}
+}
+
+func addToBucketUsage(bucketOfEntry *TimeFrameStatsValue, share usageShare) {
+	if bucketOfEntry.usageIndex == nil {
+		bucketOfEntry.usageIndex = make(map[usageKey]*UsageStats, len(bucketOfEntry.Usage))
+		for _, usage := range bucketOfEntry.Usage {
+			bucketOfEntry.usageIndex[usage.key()] = usage
+		}
+	}
+
+	k := share.key
+	usage, found := bucketOfEntry.usageIndex[k]
+	if !found && len(bucketOfEntry.Usage) >= MaxFlowsPerBucket {
+		k = usageKey{namespace: k.namespace, workload: OtherFlowName, traffic: k.traffic}
+		usage, found = bucketOfEntry.usageIndex[k]
+	}
+	if !found {
+		usage = &UsageStats{Namespace: k.namespace, Workload: k.workload, Traffic: k.traffic}
+		bucketOfEntry.Usage = append(bucketOfEntry.Usage, usage)
+		bucketOfEntry.usageIndex[k] = usage
+	}
+
+	usage.EntriesCount += share.entries
+	usage.VolumeInBytes += share.size
+}
+
+func (u *CostUsage) add(usage *UsageStats) {
+	u.EntriesCount += usage.EntriesCount
+	u.VolumeInBytes += usage.VolumeInBytes
+
+	switch usage.Traffic {
+	case TrafficIntraNamespace:
+		u.IntraBytes += usage.VolumeInBytes
+	case TrafficCrossNamespace:
+		u.CrossBytes += usage.VolumeInBytes
+	default:
+		u.ExternalBytes += usage.VolumeInBytes
+	}
+}
+
+func (u *CostUsage) setEstimatedCost(prices CostPrices) {
+	const gb = float64(1 << 30)
+
+	cost := float64(u.VolumeInBytes)/gb*prices.Observability +
+		float64(u.IntraBytes)/gb*prices.Intra +
+		float64(u.CrossBytes)/gb*prices.Cross +
+		float64(u.ExternalBytes)/gb*prices.External
+
+	u.EstimatedCost = math.Round(cost*10000) / 10000
+}
+
+// GetCostReport sums the traffic of the namespaces and their workloads within the time range,
+// the namespaces and the workloads are sorted by their volume. A namespace is charged half of the bytes of its
+// cross-namespace traffic, whether it is the source or the destination of it.
+func GetCostReport(startTime time.Time, endTime time.Time, prices CostPrices) *CostReport {
+	namespaces := map[string]*NamespaceCost{}
+	workloads := map[string]map[string]*WorkloadCost{}
+
+	report := &CostReport{
+		StartTime:  startTime.UnixMilli(),
+		EndTime:    endTime.UnixMilli(),
+		Prices:     prices,
+		Namespaces: make([]*NamespaceCost, 0),
+	}
+
+	forEachFilteredBucket(startTime, endTime, func(bucket *TimeFrameStatsValue) {
+		for _, usage := range bucket.Usage {
+			namespaceCost, found := namespaces[usage.Namespace]
+			if !found {
+				namespaceCost = &NamespaceCost{Namespace: usage.Namespace}
+				namespaces[usage.Namespace] = namespaceCost
+				workloads[usage.Namespace] = map[string]*WorkloadCost{}
+				report.Namespaces = append(report.Namespaces, namespaceCost)
+			}
+
+			workloadCost, found := workloads[usage.Namespace][usage.Workload]
+			if !found {
+				workloadCost = &WorkloadCost{Workload: usage.Workload}
+				workloads[usage.Namespace][usage.Workload] = workloadCost
+				namespaceCost.Workloads = append(namespaceCost.Workloads, workloadCost)
+			}
+
+			report.Total.add(usage)
+			namespaceCost.add(usage)
+			workloadCost.add(usage)
+		}
+	})
+
+	report.Total.setEstimatedCost(prices)
+	for _, namespaceCost := range report.Namespaces {
+		namespaceCost.setEstimatedCost(prices)
+		for _, workloadCost := range namespaceCost.Workloads {
+			workloadCost.setEstimatedCost(prices)
+		}
+
+		sort.Slice(namespaceCost.Workloads, func(i, j int) bool {
+			if namespaceCost.Workloads[i].VolumeInBytes != namespaceCost.Workloads[j].VolumeInBytes {
+				return namespaceCost.Workloads[i].VolumeInBytes > namespaceCost.Workloads[j].VolumeInBytes
+			}
+			return namespaceCost.Workloads[i].Workload < namespaceCost.Workloads[j].Workload
+		})
+	}
+
+	sort.Slice(report.Namespaces, func(i, j int) bool {
+		if report.Namespaces[i].VolumeInBytes != report.Namespaces[j].VolumeInBytes {
+			return report.Namespaces[i].VolumeInBytes > report.Namespaces[j].VolumeInBytes
+		}
+		return report.Namespaces[i].Namespace < report.Namespaces[j].Namespace
+	})
+
+	return report
+}
@@ -0,0 +1,130 @@
+package providers
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/kubeshark/base/pkg/api"
+)
+
+func addMockUsage(entryTime time.Time, source string, destination string, peers PeerNamespaces, size int) {
+	addToUsageStats(size, &api.BaseEntry{
+		Timestamp:   entryTime.UnixMilli(),
+		Source:      &api.TCP{Name: source, IP: "10.0.0.1"},
+		Destination: &api.TCP{Name: destination, IP: "10.0.0.2"},
+	}, "", peers)
+}
+
+func TestGetUsageKey(t *testing.T) {
+	summery := &api.BaseEntry{
+		Source:      &api.TCP{Name: "frontend.shop", IP: "10.0.0.1"},
+		Destination: &api.TCP{Name: "carts.shop", IP: "10.0.0.2"},
+	}
+
+	tests := map[string]struct {
+		peers    PeerNamespaces
+		expected usageKey
+	}{
+		"intra":      {peers: PeerNamespaces{Source: "shop", Destination: "shop"}, expected: usageKey{namespace: "shop", workload: "carts.shop", traffic: TrafficIntraNamespace}},
+		"cross":      {peers: PeerNamespaces{Source: "web", Destination: "shop"}, expected: usageKey{namespace: "shop", workload: "carts.shop", traffic: TrafficCrossNamespace}},
+		"egress":     {peers: PeerNamespaces{Source: "web"}, expected: usageKey{namespace: "web", workload: "frontend.shop", traffic: TrafficExternal}},
+		"ingress":    {peers: PeerNamespaces{Destination: "shop"}, expected: usageKey{namespace: "shop", workload: "carts.shop", traffic: TrafficExternal}},
+		"unresolved": {peers: PeerNamespaces{}, expected: usageKey{namespace: "default", workload: UnresolvedWorkloadName, traffic: TrafficExternal}},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			actual := getUsageKey(summery, "default", test.peers)
+			if actual != test.expected {
+				t.Errorf("unexpected result - expected: %v, actual: %v", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestGetCostReport(t *testing.T) {
+	resetStatsTiers()
+	t.Cleanup(resetStatsTiers)
+
+	const gb = 1 << 30
+	start := time.Date(2022, time.Month(1), 1, 10, 0, 0, 0, time.UTC)
+	addMockUsage(start, "frontend", "carts", PeerNamespaces{Source: "shop", Destination: "shop"}, gb)
+	addMockUsage(start.Add(time.Minute), "frontend", "orders", PeerNamespaces{Source: "web", Destination: "shop"}, gb)
+	addMockUsage(start.Add(time.Minute), "frontend", "", PeerNamespaces{Source: "web"}, 2*gb)
+
+	prices := CostPrices{Observability: 0.1, Cross: 0.01, External: 0.09}
+	report := GetCostReport(start, start.Add(time.Hour), prices)
+
+	expectedTotal := CostUsage{EntriesCount: 3, VolumeInBytes: 4 * gb, IntraBytes: gb, CrossBytes: gb, ExternalBytes: 2 * gb, EstimatedCost: 0.59}
+	if !reflect.DeepEqual(expectedTotal, report.Total) {
+		t.Errorf("unexpected result - expected: %v, actual: %v", expectedTotal, report.Total)
+	}
+
+	if len(report.Namespaces) != 2 {
+		t.Fatalf("unexpected result - expected: %v, actual: %v", 2, len(report.Namespaces))
+	}
+
+	// the namespaces are sorted by volume, ties by name
+	web, shop := report.Namespaces[0], report.Namespaces[1]
+	if web.Namespace != "web" || shop.Namespace != "shop" {
+		t.Fatalf("unexpected result - expected: %v, actual: %v %v", "web and shop", web.Namespace, shop.Namespace)
+	}
+
+	// the bytes of the cross-namespace traffic are split between its namespaces, its entry is counted in the destination
+	expectedShop := CostUsage{EntriesCount: 2, VolumeInBytes: gb + gb/2, IntraBytes: gb, CrossBytes: gb / 2, EstimatedCost: 0.155}
+	if !reflect.DeepEqual(expectedShop, shop.CostUsage) {
+		t.Errorf("unexpected result - expected: %v, actual: %v", expectedShop, shop.CostUsage)
+	}
+	if len(shop.Workloads) != 2 || shop.Workloads[0].Workload != "carts" || shop.Workloads[1].Workload != "orders" {
+		t.Errorf("unexpected result - expected: %v, actual: %v", "carts and orders", shop.Workloads)
+	}
+
+	expectedWeb := CostUsage{EntriesCount: 1, VolumeInBytes: 2*gb + gb/2, CrossBytes: gb / 2, ExternalBytes: 2 * gb, EstimatedCost: 0.435}
+	if !reflect.DeepEqual(expectedWeb, web.CostUsage) {
+		t.Errorf("unexpected result - expected: %v, actual: %v", expectedWeb, web.CostUsage)
+	}
+	if len(web.Workloads) != 1 || web.Workloads[0].Workload != "frontend" {
+		t.Errorf("unexpected result - expected: %v, actual: %v", "frontend", web.Workloads)
+	}
+}
+
+func TestGetUsageShares(t *testing.T) {
+	summery := &api.BaseEntry{
+		Source:      &api.TCP{Name: "frontend.web", IP: "10.0.0.1"},
+		Destination: &api.TCP{Name: "carts.shop", IP: "10.0.0.2"},
+	}
+
+	expected := []usageShare{
+		{key: usageKey{namespace: "shop", workload: "carts.shop", traffic: TrafficCrossNamespace}, entries: 1, size: 6},
+		{key: usageKey{namespace: "web", workload: "frontend.web", traffic: TrafficCrossNamespace}, size: 5},
+	}
+	if actual := getUsageShares(11, summery, "", PeerNamespaces{Source: "web", Destination: "shop"}); !reflect.DeepEqual(expected, actual) {
+		t.Errorf("unexpected result - expected: %v, actual: %v", expected, actual)
+	}
+
+	expected = []usageShare{{key: usageKey{namespace: "shop", workload: "carts.shop", traffic: TrafficIntraNamespace}, entries: 1, size: 11}}
+	if actual := getUsageShares(11, summery, "", PeerNamespaces{Source: "shop", Destination: "shop"}); !reflect.DeepEqual(expected, actual) {
+		t.Errorf("unexpected result - expected: %v, actual: %v", expected, actual)
+	}
+}
+
+func TestParseCostPrices(t *testing.T) {
+	SetDefaultCostPrices(CostPrices{Cross: 0.01})
+	t.Cleanup(func() { SetDefaultCostPrices(CostPrices{}) })
+
+	actual, err := ParseCostPrices([]string{"external:0.09", "observability:0.5"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := CostPrices{Observability: 0.5, Cross: 0.01, External: 0.09}
+	if actual != expected {
+		t.Errorf("unexpected result - expected: %v, actual: %v", expected, actual)
+	}
+
+	for _, invalid := range []string{"external", "external:x", "external:-1", "egress:1"} {
+		if _, err := ParseCostPrices([]string{invalid}); err == nil {
+			t.Errorf("unexpected result - expected: an error for %v, actual: nil", invalid)
+		}
+	}
+}
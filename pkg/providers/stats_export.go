@@ -0,0 +1,118 @@
+package providers
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// StatsExportRow is the traffic of a protocol and method within an interval of the export.
+type StatsExportRow struct {
+	Timestamp     int64  `json:"timestamp"`
+	Protocol      string `json:"protocol"`
+	Method        string `json:"method"`
+	EntriesCount  int    `json:"entriesCount"`
+	VolumeInBytes int    `json:"volumeInBytes"`
+	FailuresCount int    `json:"failuresCount"`
+}
+
+func (r *StatsExportRow) CSVHeader() []string {
+	return []string{"timestamp", "protocol", "method", "entriesCount", "volumeInBytes", "failuresCount"}
+}
+
+func (r *StatsExportRow) CSVRecord() []string {
+	return []string{
+		strconv.FormatInt(r.Timestamp, 10),
+		r.Protocol,
+		r.Method,
+		strconv.Itoa(r.EntriesCount),
+		strconv.Itoa(r.VolumeInBytes),
+		strconv.Itoa(r.FailuresCount),
+	}
+}
+
+// ExportTrafficStats passes the rows of the time range to the write function in time order, one interval at a time,
+// so only the rows of a single interval are held in memory. A zero interval is chosen the same way as the timeline's,
+// then rounded up to a multiple of the buckets of the time range.
+func ExportTrafficStats(startTime time.Time, endTime time.Time, interval time.Duration, write func(row *StatsExportRow) error) error {
+	initStats()
+
+	bucketStatsLocker.Lock()
+	tier := selectStatsTier(startTime)
+	buckets := tier.getBuckets(startTime, endTime)
+	bucketStatsLocker.Unlock()
+
+	if interval == 0 {
+		if len(buckets) == 0 {
+			return nil
+		}
+		interval = calculateInterval(buckets[0].BucketTime.Unix(), buckets[len(buckets)-1].BucketTime.Unix())
+		if remainder := interval % tier.interval; remainder != 0 {
+			interval += tier.interval - remainder
+		}
+	} else if interval < tier.interval || interval%tier.interval != 0 {
+		return fmt.Errorf("invalid interval: %v, the stats of the time range are kept in buckets of %v", interval, tier.interval)
+	}
+
+	var groupTime time.Time
+	group := map[string]map[string]*StatsExportRow{}
+	for _, bucket := range buckets {
+		bucketTime := roundToInterval(bucket.BucketTime, interval)
+		if !bucketTime.Equal(groupTime) {
+			if err := writeExportGroup(group, write); err != nil {
+				return err
+			}
+			groupTime = bucketTime
+			group = map[string]map[string]*StatsExportRow{}
+		}
+
+		bucketStatsLocker.Lock()
+		addToExportGroup(group, bucket, groupTime)
+		bucketStatsLocker.Unlock()
+	}
+
+	return writeExportGroup(group, write)
+}
+
+func addToExportGroup(group map[string]map[string]*StatsExportRow, bucket *TimeFrameStatsValue, groupTime time.Time) {
+	for protocolName, protocolStats := range bucket.ProtocolStats {
+		if _, found := group[protocolName]; !found {
+			group[protocolName] = map[string]*StatsExportRow{}
+		}
+		for methodName, methodStats := range protocolStats.MethodsStats {
+			row, found := group[protocolName][methodName]
+			if !found {
+				row = &StatsExportRow{Timestamp: groupTime.UnixMilli(), Protocol: protocolName, Method: methodName}
+				group[protocolName][methodName] = row
+			}
+			row.EntriesCount += methodStats.EntriesCount
+			row.VolumeInBytes += methodStats.VolumeInBytes
+			row.FailuresCount += methodStats.FailuresCount
+		}
+	}
+}
+
+func writeExportGroup(group map[string]map[string]*StatsExportRow, write func(row *StatsExportRow) error) error {
+	rows := make([]*StatsExportRow, 0)
+	for _, methods := range group {
+		for _, row := range methods {
+			rows = append(rows, row)
+		}
+	}
+
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].Protocol != rows[j].Protocol {
+			return rows[i].Protocol < rows[j].Protocol
+		}
+		return rows[i].Method < rows[j].Method
+	})
+
+	for _, row := range rows {
+		if err := write(row); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,125 @@
+package providers
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/kubeshark/base/pkg/api"
+)
+
+func TestExportTrafficStats(t *testing.T) {
+	resetStatsTiers()
+	t.Cleanup(resetStatsTiers)
+
+	start := time.Date(2022, time.Month(1), 1, 10, 0, 0, 0, time.UTC)
+	for i := 0; i < 4; i++ {
+		for _, method := range []string{"POST", "GET"} {
+			addToBucketStats(10, nil, &api.BaseEntry{
+				Protocol:  api.Protocol{ProtocolSummary: api.ProtocolSummary{Name: "http", Abbreviation: "HTTP"}},
+				Method:    method,
+				Status:    200,
+				Timestamp: start.Add(time.Duration(i) * time.Minute).UnixMilli(),
+			}, "")
+		}
+	}
+
+	tests := map[string]struct {
+		interval time.Duration
+		expected []*StatsExportRow
+	}{
+		"caller chosen interval": {
+			interval: 2 * time.Minute,
+			expected: []*StatsExportRow{
+				{Timestamp: start.UnixMilli(), Protocol: "HTTP", Method: "GET", EntriesCount: 2, VolumeInBytes: 20},
+				{Timestamp: start.UnixMilli(), Protocol: "HTTP", Method: "POST", EntriesCount: 2, VolumeInBytes: 20},
+				{Timestamp: start.Add(2 * time.Minute).UnixMilli(), Protocol: "HTTP", Method: "GET", EntriesCount: 2, VolumeInBytes: 20},
+				{Timestamp: start.Add(2 * time.Minute).UnixMilli(), Protocol: "HTTP", Method: "POST", EntriesCount: 2, VolumeInBytes: 20},
+			},
+		},
+		"automatic interval": {
+			interval: 0,
+			expected: []*StatsExportRow{
+				{Timestamp: start.UnixMilli(), Protocol: "HTTP", Method: "GET", EntriesCount: 1, VolumeInBytes: 10},
+				{Timestamp: start.UnixMilli(), Protocol: "HTTP", Method: "POST", EntriesCount: 1, VolumeInBytes: 10},
+				{Timestamp: start.Add(time.Minute).UnixMilli(), Protocol: "HTTP", Method: "GET", EntriesCount: 1, VolumeInBytes: 10},
+				{Timestamp: start.Add(time.Minute).UnixMilli(), Protocol: "HTTP", Method: "POST", EntriesCount: 1, VolumeInBytes: 10},
+				{Timestamp: start.Add(2 * time.Minute).UnixMilli(), Protocol: "HTTP", Method: "GET", EntriesCount: 1, VolumeInBytes: 10},
+				{Timestamp: start.Add(2 * time.Minute).UnixMilli(), Protocol: "HTTP", Method: "POST", EntriesCount: 1, VolumeInBytes: 10},
+				{Timestamp: start.Add(3 * time.Minute).UnixMilli(), Protocol: "HTTP", Method: "GET", EntriesCount: 1, VolumeInBytes: 10},
+				{Timestamp: start.Add(3 * time.Minute).UnixMilli(), Protocol: "HTTP", Method: "POST", EntriesCount: 1, VolumeInBytes: 10},
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			actual := make([]*StatsExportRow, 0)
+			err := ExportTrafficStats(start, start.Add(3*time.Minute), test.interval, func(row *StatsExportRow) error {
+				actual = append(actual, row)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(test.expected, actual) {
+				t.Errorf("unexpected result - expected: %v, actual: %v", test.expected, actual)
+			}
+		})
+	}
+
+	if err := ExportTrafficStats(start, start.Add(3*time.Minute), 90*time.Second, func(row *StatsExportRow) error { return nil }); err == nil {
+		t.Errorf("unexpected result - expected: an error for an interval that is not a multiple of the buckets, actual: nil")
+	}
+}
+
+func TestExportTrafficStatsAutomaticIntervalOfRollups(t *testing.T) {
+	start := time.Date(2022, time.Month(1), 1, 10, 0, 0, 0, time.UTC)
+
+	tests := map[string]struct {
+		end      time.Time
+		interval time.Duration
+	}{
+		"10 minute tier": {
+			// 7h / 30 bars is 15m, rounded up to 20m
+			end:      start.Add(7 * time.Hour),
+			interval: 20 * time.Minute,
+		},
+		"hourly tier": {
+			// 72h / 30 bars is 150m, rounded up to 3h
+			end:      start.Add(72 * time.Hour),
+			interval: 3 * time.Hour,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			resetStatsTiers()
+			t.Cleanup(resetStatsTiers)
+
+			for _, timestamp := range []time.Time{start, test.end} {
+				addToBucketStats(10, nil, &api.BaseEntry{
+					Protocol:  api.Protocol{ProtocolSummary: api.ProtocolSummary{Name: "http", Abbreviation: "HTTP"}},
+					Method:    "GET",
+					Status:    200,
+					Timestamp: timestamp.UnixMilli(),
+				}, "")
+			}
+
+			entriesCount := 0
+			err := ExportTrafficStats(start, test.end, 0, func(row *StatsExportRow) error {
+				if row.Timestamp%test.interval.Milliseconds() != 0 {
+					t.Errorf("unexpected result - expected: a timestamp on a multiple of %v, actual: %v", test.interval, time.UnixMilli(row.Timestamp).UTC())
+				}
+				entriesCount += row.EntriesCount
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if entriesCount != 2 {
+				t.Errorf("unexpected result - expected: 2 entries, actual: %d", entriesCount)
+			}
+		})
+	}
+}
@@ -0,0 +1,203 @@
+package providers
+
+import (
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/kubeshark/base/pkg/api"
+)
+
+const (
+	StatusClassSuccess = "success"
+	StatusClassFailure = "failure"
+
+	redisErrorType      = "Error"
+	amqpConnectionClose = "connection close"
+	amqpChannelClose    = "channel close"
+	amqpReplySuccess    = 200
+
+	// the bounds of the latency histogram grow by 2^(1/8), which keeps the error of the percentiles under 10%
+	latencyBucketsPerDoubling = 8
+)
+
+// LatencyHistogram counts the latencies in milliseconds by their logarithmic bucket.
+// It is sparse and can be merged, so the percentiles of any time range can be estimated.
+type LatencyHistogram map[int]int
+
+func latencyBucket(latencyMs int64) int {
+	if latencyMs <= 0 {
+		return 0
+	}
+	return int(math.Ceil(math.Log2(float64(latencyMs))*latencyBucketsPerDoubling)) + 1
+}
+
+// latencyBucketUpperBound returns the largest latency counted by the bucket.
+func latencyBucketUpperBound(bucket int) float64 {
+	if bucket <= 0 {
+		return 0
+	}
+	return math.Pow(2, float64(bucket-1)/latencyBucketsPerDoubling)
+}
+
+func (h LatencyHistogram) Add(latencyMs int64) {
+	h[latencyBucket(latencyMs)]++
+}
+
+func (h LatencyHistogram) Merge(other LatencyHistogram) {
+	for bucket, count := range other {
+		h[bucket] += count
+	}
+}
+
+// Percentile estimates the given percentile (0-100) by the upper bound of the bucket it falls into.
+func (h LatencyHistogram) Percentile(p float64) float64 {
+	total := 0
+	buckets := make([]int, 0, len(h))
+	for bucket, count := range h {
+		total += count
+		buckets = append(buckets, bucket)
+	}
+	if total == 0 {
+		return 0
+	}
+	sort.Ints(buckets)
+
+	rank := int(math.Ceil(p / 100 * float64(total)))
+	if rank < 1 {
+		rank = 1
+	}
+
+	seen := 0
+	for _, bucket := range buckets {
+		seen += h[bucket]
+		if seen >= rank {
+			return math.Round(latencyBucketUpperBound(bucket)*100) / 100
+		}
+	}
+
+	return latencyBucketUpperBound(buckets[len(buckets)-1])
+}
+
+// GetStatusClass maps the status of an entry onto its status class, e.g. 4xx for HTTP,
+// and tells whether it is a failure in terms common to all the protocols.
+// Only HTTP has a status in the summary, the failures of the other protocols are found in the entry, if any.
+func GetStatusClass(entry *api.Entry, summery *api.BaseEntry) (string, bool) {
+	var failed bool
+
+	switch summery.Protocol.Name {
+	case "http":
+		status := summery.Status
+		if status <= 0 {
+			return "unknown", false
+		}
+		return strconv.Itoa(status/100) + "xx", status >= 400
+	case "kafka":
+		failed = entry != nil && hasKafkaErrorCode(entry.Response)
+	case "redis":
+		failed = entry != nil && entry.Response["type"] == redisErrorType
+	case "amqp":
+		failed = entry != nil && (isAmqpCloseFailure(entry.Request) || isAmqpCloseFailure(entry.Response))
+	default:
+		failed = summery.Status != 0
+	}
+
+	if failed {
+		return StatusClassFailure, true
+	}
+	return StatusClassSuccess, false
+}
+
+// hasKafkaErrorCode tells whether any of the error codes within a Kafka response, e.g. of its topics or partitions,
+// is not zero.
+func hasKafkaErrorCode(value interface{}) bool {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			if key == "errorCode" {
+				if code, ok := field.(float64); ok && code != 0 {
+					return true
+				}
+				continue
+			}
+			if hasKafkaErrorCode(field) {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			if hasKafkaErrorCode(item) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// isAmqpCloseFailure tells whether the AMQP method closes a connection or a channel with a reply code other than
+// reply-success. The connection close of the dissector has its reply code under a misspelled key.
+func isAmqpCloseFailure(details map[string]interface{}) bool {
+	if details["method"] != amqpConnectionClose && details["method"] != amqpChannelClose {
+		return false
+	}
+
+	for _, key := range []string{"replyCode", "relyCode", "ReplyCode"} {
+		if code, ok := details[key].(float64); ok {
+			return code != amqpReplySuccess
+		}
+	}
+
+	return false
+}
+
+func addStatusAndLatency(stats *SizeAndEntriesCount, summery *api.BaseEntry, statusClass string, failed bool) {
+	if stats.StatusClasses == nil {
+		stats.StatusClasses = map[string]int{}
+	}
+	stats.StatusClasses[statusClass]++
+	if failed {
+		stats.FailuresCount++
+	}
+
+	if stats.Latencies == nil {
+		stats.Latencies = LatencyHistogram{}
+	}
+	stats.Latencies.Add(summery.Latency)
+}
+
+// addStatusAndLatencyStats merges the status and latency stats of a bucket into an accumulated counter.
+func addStatusAndLatencyStats(counter *AccumulativeStatsCounter, stats *SizeAndEntriesCount) {
+	counter.FailuresCount += stats.FailuresCount
+
+	if len(stats.StatusClasses) > 0 && counter.StatusClasses == nil {
+		counter.StatusClasses = map[string]int{}
+	}
+	for statusClass, count := range stats.StatusClasses {
+		counter.StatusClasses[statusClass] += count
+	}
+
+	if counter.latencies == nil {
+		counter.latencies = LatencyHistogram{}
+	}
+	counter.latencies.Merge(stats.Latencies)
+}
+
+func mergeStatusAndLatencyStats(counter *AccumulativeStatsCounter, other *AccumulativeStatsCounter) {
+	addStatusAndLatencyStats(counter, &SizeAndEntriesCount{
+		FailuresCount: other.FailuresCount,
+		StatusClasses: other.StatusClasses,
+		Latencies:     other.latencies,
+	})
+}
+
+// setRatesAndPercentiles computes the error rate and the latency percentiles of an accumulated counter.
+func setRatesAndPercentiles(counter *AccumulativeStatsCounter) {
+	if counter.EntriesCount > 0 {
+		counter.ErrorRate = float64(counter.FailuresCount) / float64(counter.EntriesCount)
+	}
+
+	counter.LatencyP50 = counter.latencies.Percentile(50)
+	counter.LatencyP95 = counter.latencies.Percentile(95)
+	counter.LatencyP99 = counter.latencies.Percentile(99)
+}
@@ -0,0 +1,149 @@
+package providers
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/kubeshark/base/pkg/api"
+)
+
+func TestLatencyHistogramPercentile(t *testing.T) {
+	histogram := LatencyHistogram{}
+	for i := int64(1); i <= 100; i++ {
+		histogram.Add(i)
+	}
+
+	tests := map[float64]float64{50: 50, 95: 95, 99: 99}
+	for p, expected := range tests {
+		actual := histogram.Percentile(p)
+		if actual < expected || actual > expected*math.Pow(2, 1.0/latencyBucketsPerDoubling) {
+			t.Errorf("unexpected result - expected: p%v within 10%% above %v, actual: %v", p, expected, actual)
+		}
+	}
+
+	if actual := (LatencyHistogram{}).Percentile(99); actual != 0 {
+		t.Errorf("unexpected result - expected: %v, actual: %v", 0, actual)
+	}
+
+	zeros := LatencyHistogram{}
+	zeros.Add(0)
+	if actual := zeros.Percentile(50); actual != 0 {
+		t.Errorf("unexpected result - expected: %v, actual: %v", 0, actual)
+	}
+}
+
+func TestGetStatusClass(t *testing.T) {
+	tests := map[string]struct {
+		protocol      string
+		status        int
+		entry         *api.Entry
+		expected      string
+		expectFailure bool
+	}{
+		"http ok":          {protocol: "http", status: 200, expected: "2xx"},
+		"http redirect":    {protocol: "http", status: 302, expected: "3xx"},
+		"http not found":   {protocol: "http", status: 404, expected: "4xx", expectFailure: true},
+		"http error":       {protocol: "http", status: 503, expected: "5xx", expectFailure: true},
+		"http no response": {protocol: "http", status: 0, expected: "unknown"},
+		"kafka ok": {protocol: "kafka", entry: &api.Entry{Response: map[string]interface{}{
+			"correlationID": float64(1),
+			"payload": map[string]interface{}{
+				"topics": []interface{}{map[string]interface{}{"errorCode": float64(0), "name": "orders", "partitions": []interface{}{
+					map[string]interface{}{"errorCode": float64(0), "partitionIndex": float64(0)},
+				}}},
+			},
+		}}, expected: StatusClassSuccess},
+		"kafka partition error": {protocol: "kafka", entry: &api.Entry{Response: map[string]interface{}{
+			"correlationID": float64(1),
+			"payload": map[string]interface{}{
+				"topics": []interface{}{map[string]interface{}{"errorCode": float64(0), "name": "orders", "partitions": []interface{}{
+					map[string]interface{}{"errorCode": float64(0), "partitionIndex": float64(0)},
+					map[string]interface{}{"errorCode": float64(3), "partitionIndex": float64(1)},
+				}}},
+			},
+		}}, expected: StatusClassFailure, expectFailure: true},
+		"kafka error": {protocol: "kafka", entry: &api.Entry{Response: map[string]interface{}{
+			"payload": map[string]interface{}{"errorCode": float64(35), "apiKeys": []interface{}{}},
+		}}, expected: StatusClassFailure, expectFailure: true},
+		"redis ok": {protocol: "redis", entry: &api.Entry{
+			Request:  map[string]interface{}{"type": "Array", "command": "GET", "key": "cart"},
+			Response: map[string]interface{}{"type": "Bulk String", "value": "item"},
+		}, expected: StatusClassSuccess},
+		"redis error": {protocol: "redis", entry: &api.Entry{
+			Request:  map[string]interface{}{"type": "Array", "command": "GET", "key": "cart"},
+			Response: map[string]interface{}{"type": "Error", "value": "WRONGTYPE Operation against a key holding the wrong kind of value"},
+		}, expected: StatusClassFailure, expectFailure: true},
+		"amqp publish": {protocol: "amqp", entry: &api.Entry{
+			Request:  map[string]interface{}{"method": "basic publish", "exchange": "orders"},
+			Response: map[string]interface{}{"method": ""},
+		}, expected: StatusClassSuccess},
+		"amqp connection closed": {protocol: "amqp", entry: &api.Entry{
+			Request:  map[string]interface{}{"method": "connection close", "relyCode": float64(200), "replyText": "Goodbye"},
+			Response: map[string]interface{}{"method": ""},
+		}, expected: StatusClassSuccess},
+		"amqp connection forced": {protocol: "amqp", entry: &api.Entry{
+			Request:  map[string]interface{}{"method": "connection close", "relyCode": float64(320), "replyText": "CONNECTION_FORCED"},
+			Response: map[string]interface{}{"method": ""},
+		}, expected: StatusClassFailure, expectFailure: true},
+		"amqp channel not found": {protocol: "amqp", entry: &api.Entry{
+			Request:  map[string]interface{}{"method": ""},
+			Response: map[string]interface{}{"method": "channel close", "ReplyCode": float64(404), "ReplyText": "NOT_FOUND"},
+		}, expected: StatusClassFailure, expectFailure: true},
+		"no entry": {protocol: "kafka", expected: StatusClassSuccess},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			summery := &api.BaseEntry{Protocol: api.Protocol{ProtocolSummary: api.ProtocolSummary{Name: test.protocol}}, Status: test.status}
+			actual, failed := GetStatusClass(test.entry, summery)
+			if actual != test.expected || failed != test.expectFailure {
+				t.Errorf("unexpected result - expected: %v %v, actual: %v %v", test.expected, test.expectFailure, actual, failed)
+			}
+		})
+	}
+}
+
+func TestTrafficStatsLatencyAndErrorRate(t *testing.T) {
+	resetStatsTiers()
+	t.Cleanup(resetStatsTiers)
+
+	start := time.Date(2022, time.Month(1), 1, 10, 0, 0, 0, time.UTC)
+	for i := 0; i < 10; i++ {
+		status := 200
+		if i%5 == 0 {
+			status = 500
+		}
+		addToBucketStats(1, nil, &api.BaseEntry{
+			Protocol:  api.Protocol{ProtocolSummary: api.ProtocolSummary{Name: "http", Abbreviation: "HTTP"}},
+			Method:    "GET",
+			Status:    status,
+			Latency:   int64(10 * (i + 1)),
+			Timestamp: start.Add(time.Duration(i) * time.Second).UnixMilli(),
+		}, "")
+	}
+
+	stats := getFilteredBucketStatsCopy(start, start.Add(time.Minute))
+
+	pie := getAccumulativeStats(stats)
+	if len(pie) != 1 {
+		t.Fatalf("unexpected result - expected: %v, actual: %v", 1, len(pie))
+	}
+	if pie[0].ErrorRate != 0.2 || pie[0].FailuresCount != 2 {
+		t.Errorf("unexpected result - expected: %v, actual: %v", 0.2, pie[0].ErrorRate)
+	}
+	if pie[0].StatusClasses["2xx"] != 8 || pie[0].StatusClasses["5xx"] != 2 {
+		t.Errorf("unexpected result - expected: %v, actual: %v", "8 2xx and 2 5xx", pie[0].StatusClasses)
+	}
+	if pie[0].LatencyP50 < 50 || pie[0].LatencyP50 > 55 || pie[0].LatencyP99 < 100 || pie[0].LatencyP99 > 110 {
+		t.Errorf("unexpected result - expected: %v, actual: %v %v", "p50 of 50 and p99 of 100", pie[0].LatencyP50, pie[0].LatencyP99)
+	}
+	if pie[0].Methods[0].LatencyP95 != pie[0].LatencyP95 {
+		t.Errorf("unexpected result - expected: %v, actual: %v", pie[0].LatencyP95, pie[0].Methods[0].LatencyP95)
+	}
+
+	timeline := getAccumulativeStatsTiming(stats)
+	if len(timeline) != 1 || timeline[0].ProtocolsData[0].ErrorRate != 0.2 {
+		t.Errorf("unexpected result - expected: %v, actual: %v", "a single point with the error rate of 0.2", timeline)
+	}
+}
@@ -25,6 +25,11 @@ type BucketStats []*TimeFrameStatsValue
 type TimeFrameStatsValue struct {
 	BucketTime    time.Time                `json:"timestamp"`
 	ProtocolStats map[string]ProtocolStats `json:"protocols"`
+	Flows         []*FlowStats             `json:"flows,omitempty"`
+	Usage         []*UsageStats            `json:"usage,omitempty"`
+	flowIndex     map[flowKey]*FlowStats
+	usageIndex    map[usageKey]*UsageStats
+	changed       bool
 }
 
 type ProtocolStats struct {
@@ -32,15 +37,25 @@ type ProtocolStats struct {
 }
 
 type SizeAndEntriesCount struct {
-	EntriesCount  int `json:"entriesCount"`
-	VolumeInBytes int `json:"volumeInBytes"`
+	EntriesCount  int              `json:"entriesCount"`
+	VolumeInBytes int              `json:"volumeInBytes"`
+	FailuresCount int              `json:"failuresCount,omitempty"`
+	StatusClasses map[string]int   `json:"statusClasses,omitempty"`
+	Latencies     LatencyHistogram `json:"latencies,omitempty"`
 }
 
 type AccumulativeStatsCounter struct {
-	Name            string `json:"name"`
-	Color           string `json:"color"`
-	EntriesCount    int    `json:"entriesCount"`
-	VolumeSizeBytes int    `json:"volumeSizeBytes"`
+	Name            string         `json:"name"`
+	Color           string         `json:"color"`
+	EntriesCount    int            `json:"entriesCount"`
+	VolumeSizeBytes int            `json:"volumeSizeBytes"`
+	FailuresCount   int            `json:"failuresCount"`
+	ErrorRate       float64        `json:"errorRate"`
+	LatencyP50      float64        `json:"latencyP50"`
+	LatencyP95      float64        `json:"latencyP95"`
+	LatencyP99      float64        `json:"latencyP99"`
+	StatusClasses   map[string]int `json:"statusClasses,omitempty"`
+	latencies       LatencyHistogram
 }
 
 type AccumulativeStatsProtocol struct {
@@ -61,7 +76,6 @@ type TrafficStatsResponse struct {
 
 var (
 	generalStats      = GeneralStats{}
-	bucketsStats      = BucketStats{}
 	bucketStatsLocker = sync.Mutex{}
 	protocolToColor   = map[string]string{}
 )
@@ -76,6 +90,8 @@ func ResetGeneralStats() {
 }
 
 func GetGeneralStats() *GeneralStats {
+	initStats()
+
 	return &generalStats
 }
 
@@ -95,7 +111,12 @@ func GetTrafficStats(startTime time.Time, endTime time.Time) *TrafficStatsRespon
 	}
 }
 
-func EntryAdded(size int, summery *api.BaseEntry) {
+func EntryAdded(size int, entry *api.Entry, summery *api.BaseEntry, namespace string, peers PeerNamespaces) {
+	initStats()
+
+	bucketStatsLocker.Lock()
+	defer bucketStatsLocker.Unlock()
+
 	generalStats.EntriesCount++
 	generalStats.EntriesVolumeInGB += float64(size) / (1 << 30)
 
@@ -105,7 +126,9 @@ func EntryAdded(size int, summery *api.BaseEntry) {
 		generalStats.FirstEntryTimestamp = currentTimestamp
 	}
 
-	addToBucketStats(size, summery)
+	addToBucketStats(size, entry, summery, namespace)
+	addToUsageStats(size, summery, namespace, peers)
+	addToIngestionCounters(size, summery)
 
 	generalStats.LastEntryTimestamp = currentTimestamp
 }
@@ -169,23 +192,22 @@ func getAccumulativeStatsTiming(stats BucketStats) []*AccumulativeStatsProtocolT
 	return convertAccumulativeStatsTimelineDictToArray(methodsPerProtocolPerTimeAggregated)
 }
 
-func addToBucketStats(size int, summery *api.BaseEntry) {
-	entryTimeBucketRounded := getBucketFromTimeStamp(summery.Timestamp)
+func addToBucketStats(size int, entry *api.Entry, summery *api.BaseEntry, namespace string) {
+	entryTime := time.UnixMilli(summery.Timestamp)
+	statusClass, failed := GetStatusClass(entry, summery)
 
-	if len(bucketsStats) == 0 {
-		bucketsStats = append(bucketsStats, &TimeFrameStatsValue{
-			BucketTime:    entryTimeBucketRounded,
-			ProtocolStats: map[string]ProtocolStats{},
-		})
-	}
-	bucketOfEntry := bucketsStats[len(bucketsStats)-1]
-	if bucketOfEntry.BucketTime != entryTimeBucketRounded {
-		bucketOfEntry = &TimeFrameStatsValue{
-			BucketTime:    entryTimeBucketRounded,
-			ProtocolStats: map[string]ProtocolStats{},
+	for _, tier := range statsTiers {
+		bucketOfEntry := tier.getBucket(roundToInterval(entryTime, tier.interval))
+		if bucketOfEntry == nil {
+			continue
 		}
-		bucketsStats = append(bucketsStats, bucketOfEntry)
+
+		addToBucket(bucketOfEntry, size, summery, statusClass, failed)
+		addToBucketFlows(bucketOfEntry, size, summery, namespace, failed)
 	}
+}
+
+func addToBucket(bucketOfEntry *TimeFrameStatsValue, size int, summery *api.BaseEntry, statusClass string, failed bool) {
 	if _, found := bucketOfEntry.ProtocolStats[summery.Protocol.Abbreviation]; !found {
 		bucketOfEntry.ProtocolStats[summery.Protocol.Abbreviation] = ProtocolStats{
 			MethodsStats: map[string]*SizeAndEntriesCount{},
@@ -200,11 +222,11 @@ func addToBucketStats(size int, summery *api.BaseEntry) {
 
 	bucketOfEntry.ProtocolStats[summery.Protocol.Abbreviation].MethodsStats[summery.Method].EntriesCount += 1
 	bucketOfEntry.ProtocolStats[summery.Protocol.Abbreviation].MethodsStats[summery.Method].VolumeInBytes += size
+	addStatusAndLatency(bucketOfEntry.ProtocolStats[summery.Protocol.Abbreviation].MethodsStats[summery.Method], summery, statusClass, failed)
 }
 
 func getBucketFromTimeStamp(timestamp int64) time.Time {
-	entryTimeStampAsTime := time.UnixMilli(timestamp)
-	return entryTimeStampAsTime.Add(-1 * InternalBucketThreshold / 2).Round(InternalBucketThreshold)
+	return roundToInterval(time.UnixMilli(timestamp), InternalBucketThreshold)
 }
 
 func convertAccumulativeStatsTimelineDictToArray(methodsPerProtocolPerTimeAggregated map[time.Time]map[string]map[string]*AccumulativeStatsCounter) []*AccumulativeStatsProtocolTime {
@@ -215,19 +237,22 @@ func convertAccumulativeStatsTimelineDictToArray(methodsPerProtocolPerTimeAggreg
 			entriesCount := 0
 			volumeSizeBytes := 0
 			methods := make([]*AccumulativeStatsCounter, 0)
+			protocolCounter := AccumulativeStatsCounter{}
 			for _, methodAccData := range value {
 				entriesCount += methodAccData.EntriesCount
 				volumeSizeBytes += methodAccData.VolumeSizeBytes
+				mergeStatusAndLatencyStats(&protocolCounter, methodAccData)
+				setRatesAndPercentiles(methodAccData)
 				methods = append(methods, methodAccData)
 			}
+			protocolCounter.Name = protocolName
+			protocolCounter.Color = getColorForProtocol(protocolName)
+			protocolCounter.EntriesCount = entriesCount
+			protocolCounter.VolumeSizeBytes = volumeSizeBytes
+			setRatesAndPercentiles(&protocolCounter)
 			protocolsData = append(protocolsData, &AccumulativeStatsProtocol{
-				AccumulativeStatsCounter: AccumulativeStatsCounter{
-					Name:            protocolName,
-					Color:           protocolToColor[protocolName],
-					EntriesCount:    entriesCount,
-					VolumeSizeBytes: volumeSizeBytes,
-				},
-				Methods: methods,
+				AccumulativeStatsCounter: protocolCounter,
+				Methods:                  methods,
 			})
 		}
 		finalResult = append(finalResult, &AccumulativeStatsProtocolTime{
@@ -244,44 +269,59 @@ func convertAccumulativeStatsDictToArray(methodsPerProtocolAggregated map[string
 		entriesCount := 0
 		volumeSizeBytes := 0
 		methods := make([]*AccumulativeStatsCounter, 0)
+		protocolCounter := AccumulativeStatsCounter{}
 		for _, methodAccData := range value {
 			entriesCount += methodAccData.EntriesCount
 			volumeSizeBytes += methodAccData.VolumeSizeBytes
+			mergeStatusAndLatencyStats(&protocolCounter, methodAccData)
+			setRatesAndPercentiles(methodAccData)
 			methods = append(methods, methodAccData)
 		}
+		protocolCounter.Name = protocolName
+		protocolCounter.Color = getColorForProtocol(protocolName)
+		protocolCounter.EntriesCount = entriesCount
+		protocolCounter.VolumeSizeBytes = volumeSizeBytes
+		setRatesAndPercentiles(&protocolCounter)
 		protocolsData = append(protocolsData, &AccumulativeStatsProtocol{
-			AccumulativeStatsCounter: AccumulativeStatsCounter{
-				Name:            protocolName,
-				Color:           protocolToColor[protocolName],
-				EntriesCount:    entriesCount,
-				VolumeSizeBytes: volumeSizeBytes,
-			},
-			Methods: methods,
+			AccumulativeStatsCounter: protocolCounter,
+			Methods:                  methods,
 		})
 	}
 	return protocolsData
 }
 
+// getFilteredBucketStatsCopy copies the protocol stats of the buckets between the given times from the finest tier that
+// still holds them. The flows and the usage are left out, see forEachFilteredBucket.
 func getFilteredBucketStatsCopy(startTime time.Time, endTime time.Time) BucketStats {
+	initStats()
+
 	bucketStatsCopy := BucketStats{}
 	bucketStatsLocker.Lock()
-	if err := copier.Copy(&bucketStatsCopy, bucketsStats); err != nil {
-		log.Error().Err(err).Msg("While copying src stats into temporary copied object.")
-		return nil
+	defer bucketStatsLocker.Unlock()
+
+	for _, bucket := range selectStatsTier(startTime).getBuckets(startTime, endTime) {
+		bucketCopy := &TimeFrameStatsValue{BucketTime: bucket.BucketTime}
+		if err := copier.CopyWithOption(&bucketCopy.ProtocolStats, bucket.ProtocolStats, copier.Option{DeepCopy: true}); err != nil {
+			log.Error().Err(err).Msg("While copying src stats into temporary copied object.")
+			return nil
+		}
+		bucketStatsCopy = append(bucketStatsCopy, bucketCopy)
 	}
-	bucketStatsLocker.Unlock()
 
-	filteredBucketStatsCopy := BucketStats{}
-	interval := InternalBucketThreshold
+	return bucketStatsCopy
+}
 
-	for _, bucket := range bucketStatsCopy {
-		if (bucket.BucketTime.After(startTime.Add(-1*interval/2).Round(interval)) && bucket.BucketTime.Before(endTime.Add(-1*interval/2).Round(interval))) ||
-			bucket.BucketTime.Equal(startTime.Add(-1*interval/2).Round(interval)) ||
-			bucket.BucketTime.Equal(endTime.Add(-1*interval/2).Round(interval)) {
-			filteredBucketStatsCopy = append(filteredBucketStatsCopy, bucket)
-		}
+// forEachFilteredBucket calls onBucket with the buckets between the given times from the finest tier that still holds
+// them, instead of copying their flows and usage. onBucket runs under the lock of the stats, it must not keep the bucket.
+func forEachFilteredBucket(startTime time.Time, endTime time.Time, onBucket func(bucket *TimeFrameStatsValue)) {
+	initStats()
+
+	bucketStatsLocker.Lock()
+	defer bucketStatsLocker.Unlock()
+
+	for _, bucket := range selectStatsTier(startTime).getBuckets(startTime, endTime) {
+		onBucket(bucket)
 	}
-	return filteredBucketStatsCopy
 }
 
 func getAggregatedResultTiming(stats BucketStats, interval time.Duration) map[time.Time]map[string]map[string]*AccumulativeStatsCounter {
@@ -311,6 +351,7 @@ func getAggregatedResultTiming(stats BucketStats, interval time.Duration) map[ti
 				}
 				methodsPerProtocolPerTimeAggregated[resultBucketRoundedKey][protocolName][methodName].EntriesCount += dataOfMethod.EntriesCount
 				methodsPerProtocolPerTimeAggregated[resultBucketRoundedKey][protocolName][methodName].VolumeSizeBytes += dataOfMethod.VolumeInBytes
+				addStatusAndLatencyStats(methodsPerProtocolPerTimeAggregated[resultBucketRoundedKey][protocolName][methodName], dataOfMethod)
 			}
 		}
 
@@ -337,12 +378,21 @@ func getAggregatedStats(stats BucketStats) map[string]map[string]*AccumulativeSt
 				}
 				methodsPerProtocolAggregated[protocolName][method].EntriesCount += countersValue.EntriesCount
 				methodsPerProtocolAggregated[protocolName][method].VolumeSizeBytes += countersValue.VolumeInBytes
+				addStatusAndLatencyStats(methodsPerProtocolAggregated[protocolName][method], countersValue)
 			}
 		}
 	}
 	return methodsPerProtocolAggregated
 }
 
+// getColorForProtocol falls back to a generated color for the groups that are not protocols, e.g. namespaces.
+func getColorForProtocol(protocolName string) string {
+	if color, ok := protocolToColor[protocolName]; ok {
+		return color
+	}
+	return getColorForMethod(protocolName, "")
+}
+
 func getColorForMethod(protocolName string, methodName string) string {
 	hash := md5.Sum([]byte(fmt.Sprintf("%v_%v", protocolName, methodName)))
 	input := hex.EncodeToString(hash[:])
@@ -30,7 +30,7 @@ func TestEntryAddedCount(t *testing.T) {
 	for _, entriesCount := range tests {
 		t.Run(fmt.Sprintf("%d", entriesCount), func(t *testing.T) {
 			for i := 0; i < entriesCount; i++ {
-				providers.EntryAdded(0, mockSummery)
+				providers.EntryAdded(0, nil, mockSummery, "", providers.PeerNamespaces{})
 			}
 
 			entriesStats := providers.GetGeneralStats()
@@ -68,7 +68,7 @@ func TestEntryAddedVolume(t *testing.T) {
 			expectedEntriesCount++
 			expectedVolumeInGB += float64(len(data)) / (1 << 30)
 
-			providers.EntryAdded(len(data), mockSummery)
+			providers.EntryAdded(len(data), nil, mockSummery, "", providers.PeerNamespaces{})
 
 			entriesStats := providers.GetGeneralStats()
 
@@ -82,3 +82,25 @@ func TestEntryAddedVolume(t *testing.T) {
 		})
 	}
 }
+
+func TestIngestionCountersAreNotReset(t *testing.T) {
+	mockSummery := &api.BaseEntry{Protocol: api.Protocol{ProtocolSummary: api.ProtocolSummary{Abbreviation: "COUNTER"}}, Method: "counter-method", Timestamp: time.Date(2021, 1, 1, 10, 0, 0, 0, time.UTC).UnixNano()}
+
+	providers.EntryAdded(10, nil, mockSummery, "", providers.PeerNamespaces{})
+	providers.ResetGeneralStats()
+	providers.EntryAdded(5, nil, mockSummery, "", providers.PeerNamespaces{})
+
+	for _, counter := range providers.GetIngestionCounters() {
+		if counter.Protocol != "COUNTER" {
+			continue
+		}
+
+		if counter.Method != "counter-method" || counter.EntriesCount != 2 || counter.VolumeInBytes != 15 {
+			t.Errorf("unexpected result - expected: %v, actual: %+v", "2 entries of 15 bytes", counter)
+		}
+		providers.ResetGeneralStats()
+		return
+	}
+
+	t.Errorf("unexpected result - the counter of protocol %v is missing", "COUNTER")
+}
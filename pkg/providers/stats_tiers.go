@@ -0,0 +1,299 @@
+package providers
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jinzhu/copier"
+	"github.com/kubeshark/base/pkg/models"
+	"github.com/kubeshark/hub/pkg/utils"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// StatsDirPath keeps the general stats and a file per bucket of every tier, so saving writes only the changed buckets.
+	StatsDirPath = models.DataDirPath + "traffic-stats"
+
+	generalStatsFileName = "general.json"
+)
+
+// statsTier is a ring buffer of the buckets of a single interval, the slot of a bucket is derived from its time,
+// so a new bucket overwrites the one that is older than the retention of the tier.
+type statsTier struct {
+	interval time.Duration
+	buckets  []*TimeFrameStatsValue
+	latest   time.Time
+	// evicted are the times of the buckets overwritten since the last save, their files are removed by it.
+	evicted []time.Time
+}
+
+var (
+	statsTiers    = newStatsTiers()
+	statsSyncOnce sync.Once
+)
+
+// newStatsTiers returns the minute buckets of the last hours followed by the 10-minute and hourly rollups.
+func newStatsTiers() []*statsTier {
+	return []*statsTier{
+		newStatsTier(InternalBucketThreshold, 6*time.Hour),
+		newStatsTier(10*time.Minute, 2*24*time.Hour),
+		newStatsTier(time.Hour, 30*24*time.Hour),
+	}
+}
+
+func newStatsTier(interval time.Duration, retention time.Duration) *statsTier {
+	return &statsTier{
+		interval: interval,
+		buckets:  make([]*TimeFrameStatsValue, retention/interval),
+	}
+}
+
+func (t *statsTier) retention() time.Duration {
+	return t.interval * time.Duration(len(t.buckets))
+}
+
+// oldest returns the time of the oldest bucket the tier can still hold.
+func (t *statsTier) oldest() time.Time {
+	return t.latest.Add(-t.retention() + t.interval)
+}
+
+func (t *statsTier) slot(bucketTime time.Time) int {
+	n := int64(len(t.buckets))
+	i := bucketTime.Unix() / int64(t.interval/time.Second)
+	return int(((i % n) + n) % n)
+}
+
+// getBucket returns the bucket of the given time to add to, creating it if needed, and marks it as changed.
+// It returns nil if the bucket is older than the retention of the tier.
+func (t *statsTier) getBucket(bucketTime time.Time) *TimeFrameStatsValue {
+	if !t.latest.IsZero() && bucketTime.Before(t.oldest()) {
+		return nil
+	}
+
+	i := t.slot(bucketTime)
+	if bucket := t.buckets[i]; bucket != nil && bucket.BucketTime.Equal(bucketTime) {
+		bucket.changed = true
+		return bucket
+	} else if bucket != nil {
+		t.evicted = append(t.evicted, bucket.BucketTime)
+	}
+
+	bucket := &TimeFrameStatsValue{
+		BucketTime:    bucketTime,
+		ProtocolStats: map[string]ProtocolStats{},
+		changed:       true,
+	}
+	t.buckets[i] = bucket
+
+	if bucketTime.After(t.latest) {
+		t.latest = bucketTime
+	}
+
+	return bucket
+}
+
+// getBuckets returns the buckets between the given times sorted by their time.
+func (t *statsTier) getBuckets(startTime time.Time, endTime time.Time) BucketStats {
+	start := roundToInterval(startTime, t.interval)
+	end := roundToInterval(endTime, t.interval)
+
+	buckets := BucketStats{}
+	for _, bucket := range t.buckets {
+		if bucket == nil || bucket.BucketTime.Before(t.oldest()) {
+			continue
+		}
+		if bucket.BucketTime.Before(start) || bucket.BucketTime.After(end) {
+			continue
+		}
+		buckets = append(buckets, bucket)
+	}
+
+	sort.Slice(buckets, func(i, j int) bool {
+		return buckets[i].BucketTime.Before(buckets[j].BucketTime)
+	})
+
+	return buckets
+}
+
+func roundToInterval(t time.Time, interval time.Duration) time.Time {
+	return t.Add(-1 * interval / 2).Round(interval)
+}
+
+// selectStatsTier returns the finest tier that still holds the buckets of the given start time.
+func selectStatsTier(startTime time.Time) *statsTier {
+	for _, tier := range statsTiers {
+		if tier.latest.IsZero() || !roundToInterval(startTime, tier.interval).Before(tier.oldest()) {
+			return tier
+		}
+	}
+
+	return statsTiers[len(statsTiers)-1]
+}
+
+// dirPath is the directory of the buckets of the tier within the stats directory.
+func (t *statsTier) dirPath(statsDirPath string) string {
+	return filepath.Join(statsDirPath, strconv.FormatInt(int64(t.interval/time.Second), 10))
+}
+
+func getBucketFilePath(tierDirPath string, bucketTime time.Time) string {
+	return filepath.Join(tierDirPath, fmt.Sprintf("%d.json", bucketTime.Unix()))
+}
+
+func initStats() {
+	statsSyncOnce.Do(func() {
+		if err := loadStats(StatsDirPath); err != nil && !os.IsNotExist(err) {
+			log.Error().Err(err).Msg("While reading traffic stats from file.")
+		}
+	})
+}
+
+// StartStatsPersistence saves the stats to the data directory every interval.
+func StartStatsPersistence(interval time.Duration) {
+	initStats()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if err := saveStats(StatsDirPath); err != nil {
+				log.Error().Err(err).Msg("While saving traffic stats.")
+			}
+		}
+	}()
+}
+
+// saveStats writes the buckets changed since the last save and removes the files of the evicted ones.
+// A bucket that fails to be written stays changed, so the next save writes it again.
+func saveStats(dirPath string) error {
+	type changedBucket struct {
+		bucket *TimeFrameStatsValue
+		copy   *TimeFrameStatsValue
+	}
+
+	changed := make([][]changedBucket, len(statsTiers))
+	evicted := make([][]time.Time, len(statsTiers))
+
+	bucketStatsLocker.Lock()
+	general := generalStats
+	for i, tier := range statsTiers {
+		for _, bucket := range tier.buckets {
+			if bucket == nil || !bucket.changed {
+				continue
+			}
+
+			bucketCopy := &TimeFrameStatsValue{}
+			if err := copier.CopyWithOption(bucketCopy, bucket, copier.Option{DeepCopy: true}); err != nil {
+				bucketStatsLocker.Unlock()
+				return err
+			}
+			bucket.changed = false
+			changed[i] = append(changed[i], changedBucket{bucket: bucket, copy: bucketCopy})
+		}
+
+		evicted[i] = tier.evicted
+		tier.evicted = nil
+	}
+	bucketStatsLocker.Unlock()
+
+	var saveErr error
+	for i, tier := range statsTiers {
+		tierDirPath := tier.dirPath(dirPath)
+		if err := os.MkdirAll(tierDirPath, 0755); err != nil {
+			return err
+		}
+
+		for _, bucketTime := range evicted[i] {
+			if err := os.Remove(getBucketFilePath(tierDirPath, bucketTime)); err != nil && !os.IsNotExist(err) {
+				saveErr = err
+			}
+		}
+
+		for _, c := range changed[i] {
+			if err := utils.SaveJsonFile(getBucketFilePath(tierDirPath, c.copy.BucketTime), c.copy); err != nil {
+				saveErr = err
+
+				bucketStatsLocker.Lock()
+				c.bucket.changed = true
+				bucketStatsLocker.Unlock()
+			}
+		}
+	}
+
+	if err := utils.SaveJsonFile(filepath.Join(dirPath, generalStatsFileName), general); err != nil {
+		return err
+	}
+
+	return saveErr
+}
+
+// loadStats reads the stats saved to the directory. The buckets that no longer fit the retention of their tier are
+// evicted, so the next save removes their files.
+func loadStats(dirPath string) error {
+	var general GeneralStats
+	if err := utils.ReadJsonFile(filepath.Join(dirPath, generalStatsFileName), &general); err != nil {
+		return err
+	}
+
+	bucketStatsLocker.Lock()
+	defer bucketStatsLocker.Unlock()
+
+	generalStats = general
+	statsTiers = newStatsTiers()
+	for _, tier := range statsTiers {
+		tierDirPath := tier.dirPath(dirPath)
+		files, err := os.ReadDir(tierDirPath)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return err
+		}
+
+		for _, file := range files {
+			bucket := &TimeFrameStatsValue{}
+			if err := utils.ReadJsonFile(filepath.Join(tierDirPath, file.Name()), bucket); err != nil {
+				log.Error().Err(err).Str("file", file.Name()).Msg("While reading a traffic stats bucket:")
+				continue
+			}
+			if bucket.ProtocolStats == nil {
+				continue
+			}
+
+			slot := tier.getBucket(bucket.BucketTime)
+			if slot == nil {
+				tier.evicted = append(tier.evicted, bucket.BucketTime)
+				continue
+			}
+			*slot = *bucket
+		}
+	}
+
+	return nil
+}
+
+// GetMinuteBucket returns a copy of the minute bucket of the given time, or nil if it saw no traffic.
+func GetMinuteBucket(minute time.Time) *TimeFrameStatsValue {
+	initStats()
+
+	bucketStatsLocker.Lock()
+	defer bucketStatsLocker.Unlock()
+
+	buckets := statsTiers[0].getBuckets(minute, minute)
+	if len(buckets) == 0 {
+		return nil
+	}
+
+	bucket := &TimeFrameStatsValue{}
+	if err := copier.CopyWithOption(bucket, buckets[0], copier.Option{DeepCopy: true}); err != nil {
+		log.Error().Err(err).Msg("While copying the minute bucket.")
+		return nil
+	}
+
+	return bucket
+}
@@ -0,0 +1,204 @@
+package providers
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/kubeshark/base/pkg/api"
+)
+
+func resetStatsTiers() {
+	statsTiers = newStatsTiers()
+	generalStats = GeneralStats{}
+}
+
+func addMockEntry(entryTime time.Time, size int) {
+	addToBucketStats(size, nil, &api.BaseEntry{
+		Protocol:  api.Protocol{ProtocolSummary: api.ProtocolSummary{Abbreviation: "HTTP"}},
+		Method:    "GET",
+		Timestamp: entryTime.UnixMilli(),
+	}, "")
+}
+
+func countEntries(stats BucketStats) int {
+	count := 0
+	for _, bucket := range stats {
+		for _, protocolStats := range bucket.ProtocolStats {
+			for _, methodStats := range protocolStats.MethodsStats {
+				count += methodStats.EntriesCount
+			}
+		}
+	}
+	return count
+}
+
+func TestStatsTiersRollup(t *testing.T) {
+	resetStatsTiers()
+	t.Cleanup(resetStatsTiers)
+
+	start := time.Date(2022, time.Month(1), 1, 10, 0, 0, 0, time.UTC)
+	for i := 0; i < 60; i++ {
+		addMockEntry(start.Add(time.Duration(i)*time.Minute), 1)
+	}
+
+	expectedBuckets := []int{60, 6, 1}
+	for i, tier := range statsTiers {
+		buckets := tier.getBuckets(start, start.Add(time.Hour))
+		if len(buckets) != expectedBuckets[i] {
+			t.Errorf("unexpected result - expected: %v, actual: %v", expectedBuckets[i], len(buckets))
+		}
+		if countEntries(buckets) != 60 {
+			t.Errorf("unexpected result - expected: %v, actual: %v", 60, countEntries(buckets))
+		}
+	}
+}
+
+func TestStatsTierRetention(t *testing.T) {
+	resetStatsTiers()
+	t.Cleanup(resetStatsTiers)
+
+	tier := statsTiers[0]
+	start := time.Date(2022, time.Month(1), 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < len(tier.buckets)+10; i++ {
+		addMockEntry(start.Add(time.Duration(i)*time.Minute), 1)
+	}
+
+	buckets := tier.getBuckets(start, start.Add(24*time.Hour))
+	if len(buckets) != len(tier.buckets) {
+		t.Errorf("unexpected result - expected: %v, actual: %v", len(tier.buckets), len(buckets))
+	}
+	if !buckets[0].BucketTime.Equal(start.Add(10 * time.Minute)) {
+		t.Errorf("unexpected result - expected: %v, actual: %v", start.Add(10*time.Minute), buckets[0].BucketTime)
+	}
+
+	// an entry older than the retention is dropped from the minute tier only
+	addMockEntry(start, 1)
+	if countEntries(tier.getBuckets(start, start.Add(24*time.Hour))) != len(tier.buckets) {
+		t.Errorf("unexpected result - the expired entry is counted")
+	}
+	if countEntries(statsTiers[1].getBuckets(start, start.Add(24*time.Hour))) != len(tier.buckets)+11 {
+		t.Errorf("unexpected result - the entry is missing from the 10-minute tier")
+	}
+}
+
+func TestSelectStatsTier(t *testing.T) {
+	resetStatsTiers()
+	t.Cleanup(resetStatsTiers)
+
+	now := time.Date(2022, time.Month(2), 1, 0, 0, 0, 0, time.UTC)
+	addMockEntry(now, 1)
+
+	tests := map[string]struct {
+		startTime time.Time
+		expected  time.Duration
+	}{
+		"last hour":  {startTime: now.Add(-time.Hour), expected: time.Minute},
+		"last day":   {startTime: now.Add(-24 * time.Hour), expected: 10 * time.Minute},
+		"last week":  {startTime: now.Add(-7 * 24 * time.Hour), expected: time.Hour},
+		"last year":  {startTime: now.Add(-365 * 24 * time.Hour), expected: time.Hour},
+		"the future": {startTime: now.Add(time.Hour), expected: time.Minute},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			actual := selectStatsTier(test.startTime).interval
+			if actual != test.expected {
+				t.Errorf("unexpected result - expected: %v, actual: %v", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestStatsPersistence(t *testing.T) {
+	resetStatsTiers()
+	t.Cleanup(resetStatsTiers)
+
+	dirPath := filepath.Join(t.TempDir(), "traffic-stats")
+	start := time.Date(2022, time.Month(1), 1, 10, 0, 0, 0, time.UTC)
+	for i := 0; i < 30; i++ {
+		addMockEntry(start.Add(time.Duration(i)*time.Minute), 10)
+	}
+	generalStats.EntriesCount = 30
+
+	if err := saveStats(dirPath); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	resetStatsTiers()
+	if err := loadStats(dirPath); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if generalStats.EntriesCount != 30 {
+		t.Errorf("unexpected result - expected: %v, actual: %v", 30, generalStats.EntriesCount)
+	}
+	for _, tier := range statsTiers {
+		if actual := countEntries(tier.getBuckets(start, start.Add(time.Hour))); actual != 30 {
+			t.Errorf("unexpected result - expected: %v, actual: %v", 30, actual)
+		}
+	}
+
+	// the loaded buckets keep counting
+	addMockEntry(start, 10)
+	if actual := countEntries(statsTiers[0].getBuckets(start, start)); actual != 2 {
+		t.Errorf("unexpected result - expected: %v, actual: %v", 2, actual)
+	}
+}
+
+func TestStatsPersistenceSavesChangedBuckets(t *testing.T) {
+	resetStatsTiers()
+	t.Cleanup(resetStatsTiers)
+
+	dirPath := filepath.Join(t.TempDir(), "traffic-stats")
+	minuteDirPath := statsTiers[0].dirPath(dirPath)
+	start := time.Date(2022, time.Month(1), 1, 10, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		addMockEntry(start.Add(time.Duration(i)*time.Minute), 10)
+	}
+
+	if err := saveStats(dirPath); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := os.RemoveAll(minuteDirPath); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// only the bucket added to since the last save is written
+	addMockEntry(start, 10)
+	if err := saveStats(dirPath); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if actual := listFileNames(t, minuteDirPath); !reflect.DeepEqual(actual, []string{getBucketFileName(start)}) {
+		t.Errorf("unexpected result - expected: %v, actual: %v", []string{getBucketFileName(start)}, actual)
+	}
+
+	// the file of a bucket overwritten by a newer one is removed
+	evicting := start.Add(statsTiers[0].retention())
+	addMockEntry(evicting, 10)
+	if err := saveStats(dirPath); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if actual := listFileNames(t, minuteDirPath); !reflect.DeepEqual(actual, []string{getBucketFileName(evicting)}) {
+		t.Errorf("unexpected result - expected: %v, actual: %v", []string{getBucketFileName(evicting)}, actual)
+	}
+}
+
+func getBucketFileName(bucketTime time.Time) string {
+	return filepath.Base(getBucketFilePath("", bucketTime))
+}
+
+func listFileNames(t *testing.T, dirPath string) []string {
+	files, err := os.ReadDir(dirPath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	names := make([]string, 0, len(files))
+	for _, file := range files {
+		names = append(names, file.Name())
+	}
+	return names
+}
@@ -24,6 +24,7 @@ type Resolver struct {
 	clientSet    *kubernetes.Clientset
 	nameMap      cmap.ConcurrentMap
 	serviceMap   cmap.ConcurrentMap
+	labelsMap    cmap.ConcurrentMap
 	isStarted    bool
 	errOut       chan error
 	namespace    string
@@ -56,6 +57,15 @@ func (resolver *Resolver) GetMap() cmap.ConcurrentMap {
 	return resolver.nameMap
 }
 
+// GetLabels returns the pod labels selected by the service with the given full address.
+func (resolver *Resolver) GetLabels(fullAddress string) (map[string]string, bool) {
+	labels, isFound := resolver.labelsMap.Get(fullAddress)
+	if !isFound {
+		return nil, false
+	}
+	return labels.(map[string]string), true
+}
+
 func (resolver *Resolver) CheckIsServiceIP(address string) bool {
 	_, isFound := resolver.serviceMap.Get(address)
 	return isFound
@@ -153,6 +163,7 @@ func (resolver *Resolver) watchServices(ctx context.Context) error {
 				}
 				resolver.saveServiceIP(service.Spec.ClusterIP, serviceHostname, service.Namespace, event.Type)
 			}
+			resolver.saveLabels(serviceHostname, service.Spec.Selector, event.Type)
 			if service.Status.LoadBalancer.Ingress != nil {
 				for _, ingress := range service.Status.LoadBalancer.Ingress {
 					resolver.saveResolvedName(ingress.IP, serviceHostname, service.Namespace, event.Type)
@@ -186,6 +197,14 @@ func (resolver *Resolver) saveServiceIP(key string, resolved string, namespace s
 	}
 }
 
+func (resolver *Resolver) saveLabels(resolved string, labels map[string]string, eventType watch.EventType) {
+	if eventType == watch.Deleted || len(labels) == 0 {
+		resolver.labelsMap.Remove(resolved)
+	} else {
+		resolver.labelsMap.Set(resolved, labels)
+	}
+}
+
 func (resolver *Resolver) infiniteErrorHandleRetryFunc(ctx context.Context, fun func(ctx context.Context) error) {
 	for {
 		err := fun(ctx)
@@ -9,6 +9,18 @@ import (
 func EntriesRoutes(ginApp *gin.Engine) {
 	routeGroup := ginApp.Group("/entries")
 
-	routeGroup.GET("/", controllers.GetEntries)  // get entries (base/thin entries) and metadata
-	routeGroup.GET("/:id", controllers.GetEntry) // get single (full) entry
+	routeGroup.GET("/", controllers.GetEntries)                        // get entries (base/thin entries) and metadata
+	routeGroup.GET("/export", controllers.ExportEntries)               // stream the entries matching a query as a HAR file or as rows of fields
+	routeGroup.POST("/import", controllers.ImportEntries)              // import the entries of an uploaded HAR file
+	routeGroup.GET("/stream", controllers.StreamEntries)               // stream the entries matching a query over Server-Sent Events
+	routeGroup.GET("/annotations", controllers.GetAnnotations)         // list the annotations, optionally by tag or only the bookmarks
+	routeGroup.GET("/bookmarks", controllers.GetBookmarkedEntries)     // get the bookmarked entries (base/thin entries) and their annotations
+	routeGroup.GET("/pinned", controllers.GetPinnedEntries)            // get the pinned entries (base/thin entries) and their annotations
+	routeGroup.GET("/diff", controllers.DiffEntries)                   // compare two entries
+	routeGroup.GET("/:id", controllers.GetEntry)                       // get single (full) entry
+	routeGroup.GET("/:id/annotation", controllers.GetAnnotation)       // get the annotation of an entry
+	routeGroup.PUT("/:id/annotation", controllers.PutAnnotation)       // annotate an entry
+	routeGroup.DELETE("/:id/annotation", controllers.DeleteAnnotation) // delete the annotation of an entry and its pinned copy
+	routeGroup.POST("/:id/pin", controllers.PinEntry)                  // copy an entry out of Basenine so it survives its eviction
+	routeGroup.DELETE("/:id/pin", controllers.UnpinEntry)              // delete the pinned copy of an entry
 }
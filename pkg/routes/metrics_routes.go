@@ -0,0 +1,11 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/kubeshark/hub/pkg/controllers"
+)
+
+// MetricsRoutes exposes the metrics of the hub in the Prometheus text format.
+func MetricsRoutes(ginApp *gin.Engine) {
+	ginApp.GET("/metrics", controllers.GetMetrics)
+}
@@ -9,4 +9,13 @@ func QueryRoutes(ginApp *gin.Engine) {
 	routeGroup := ginApp.Group("/query")
 
 	routeGroup.POST("/validate", controllers.PostValidate)
+	routeGroup.GET("/fields", controllers.GetQueryFields)       // get the macros, the fields by protocol and their frequent values
+	routeGroup.GET("/suggest", controllers.GetQuerySuggestions) // get the macros, fields and values that start with a prefix
+	routeGroup.GET("/aggregate", controllers.AggregateEntries)  // group and aggregate the stored entries matching a query
+
+	routeGroup.GET("/saved", controllers.GetSavedQueries)           // list the saved queries, optionally by tag
+	routeGroup.POST("/saved", controllers.PostSavedQuery)           // save a named query
+	routeGroup.GET("/saved/:name", controllers.GetSavedQuery)       // get a saved query
+	routeGroup.PUT("/saved/:name", controllers.PutSavedQuery)       // update or rename a saved query
+	routeGroup.DELETE("/saved/:name", controllers.DeleteSavedQuery) // delete a saved query
 }
@@ -13,4 +13,18 @@ func ServiceMapRoutes(ginApp *gin.Engine) {
 	routeGroup.GET("/status", controller.Status)
 	routeGroup.GET("/get", controller.Get)
 	routeGroup.GET("/reset", controller.Reset)
+
+	analysisGroup := routeGroup.Group("/analysis")
+	analysisGroup.GET("/dependencies", controller.GetDependencies) // transitive upstream or downstream dependencies of a node
+	analysisGroup.GET("/cycles", controller.GetCycles)             // dependency cycles
+	analysisGroup.GET("/ranking", controller.GetFanRanking)        // nodes ranked by fan-in or fan-out
+	analysisGroup.GET("/path", controller.GetShortestPath)         // shortest dependency path between two nodes
+
+	routeGroup.GET("/networkpolicies", controller.GetNetworkPolicies)            // NetworkPolicy YAML allowing the observed traffic
+	routeGroup.POST("/networkpolicies/dryrun", controller.DryRunNetworkPolicies) // observed traffic evaluated against the uploaded policies
+
+	routeGroup.POST("/build", controller.StartBuild)        // build a service map from the stored entries matching a query
+	routeGroup.GET("/build", controller.ListBuilds)         // list of the builds kept in memory
+	routeGroup.GET("/build/:id", controller.GetBuild)       // progress and the (partial) service map of a build
+	routeGroup.DELETE("/build/:id", controller.CancelBuild) // cancel a running build
 }
@@ -17,6 +17,11 @@ func StatusRoutes(ginApp *gin.Engine) {
 
 	routeGroup.GET("/general", controllers.GetGeneralStats)
 	routeGroup.GET("/trafficStats", controllers.GetTrafficStats)
+	routeGroup.GET("/trafficStats/export", controllers.ExportTrafficStats)
+	routeGroup.GET("/trafficMatrix", controllers.GetTrafficMatrix)
+	routeGroup.GET("/cost", controllers.GetCostReport)
+	routeGroup.GET("/anomalies", controllers.GetAnomalies)
+	routeGroup.GET("/top", controllers.GetTop)
 
 	routeGroup.GET("/resolving", controllers.GetCurrentResolvingInformation)
 }
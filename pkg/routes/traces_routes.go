@@ -0,0 +1,13 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/kubeshark/hub/pkg/controllers"
+)
+
+func TracesRoutes(ginApp *gin.Engine) {
+	routeGroup := ginApp.Group("/traces")
+
+	routeGroup.GET("", controllers.GetTraces)         // list the recent traces, or the traces of the entries matching a query
+	routeGroup.GET("/:traceId", controllers.GetTrace) // get the tree of the entries of a trace
+}
@@ -0,0 +1,264 @@
+package servicemap
+
+import (
+	"fmt"
+	"sort"
+)
+
+const (
+	DirectionUpstream   = "upstream"
+	DirectionDownstream = "downstream"
+
+	RankByFanIn  = "fanIn"
+	RankByFanOut = "fanOut"
+)
+
+// neighbors returns the sorted keys of the nodes that k calls (downstream) or that call k (upstream).
+func (g *graph) neighbors(k key, direction string) []key {
+	neighbors := make([]key, 0)
+
+	if direction == DirectionDownstream {
+		for v := range g.Edges[k] {
+			neighbors = append(neighbors, v)
+		}
+	} else {
+		for u, m := range g.Edges {
+			if _, ok := m[k]; ok {
+				neighbors = append(neighbors, u)
+			}
+		}
+	}
+
+	sort.Slice(neighbors, func(i, j int) bool {
+		return neighbors[i] < neighbors[j]
+	})
+
+	return neighbors
+}
+
+func (s *defaultServiceMap) toServiceMapNode(k key) ServiceMapNode {
+	n := s.graph.Nodes[k]
+	return ServiceMapNode{
+		Id:       n.id,
+		Name:     string(k),
+		Entry:    n.entry,
+		Resolved: n.entry.Name != UnresolvedNodeName,
+		Count:    n.count,
+	}
+}
+
+// GetDependencies walks the graph breadth-first from the given node.
+// Downstream dependencies are the services the node calls, upstream dependencies are the services calling it,
+// in other words the blast radius of the node going down. A maxDepth less than 1 means no depth limit.
+func (s *defaultServiceMap) GetDependencies(name string, direction string, maxDepth int) (*ServiceMapDependenciesResponse, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if direction != DirectionUpstream && direction != DirectionDownstream {
+		return nil, fmt.Errorf("invalid direction: %s", direction)
+	}
+
+	start := key(name)
+	if _, ok := s.nodeExists(start); !ok {
+		return nil, fmt.Errorf("node %s is not found", name)
+	}
+
+	dependencies := make([]ServiceMapDependency, 0)
+	visited := map[key]bool{start: true}
+	frontier := []key{start}
+
+	for depth := 1; len(frontier) > 0 && (maxDepth < 1 || depth <= maxDepth); depth++ {
+		next := make([]key, 0)
+		for _, k := range frontier {
+			for _, neighbor := range s.graph.neighbors(k, direction) {
+				if visited[neighbor] {
+					continue
+				}
+				visited[neighbor] = true
+				next = append(next, neighbor)
+				dependencies = append(dependencies, ServiceMapDependency{
+					Node:  s.toServiceMapNode(neighbor),
+					Depth: depth,
+				})
+			}
+		}
+		frontier = next
+	}
+
+	return &ServiceMapDependenciesResponse{
+		Node:         s.toServiceMapNode(start),
+		Direction:    direction,
+		MaxDepth:     maxDepth,
+		Dependencies: dependencies,
+	}, nil
+}
+
+// GetCycles returns the dependency cycles of the graph as its strongly connected components
+// (Tarjan's algorithm) that either contain more than one node or a node calling itself.
+func (s *defaultServiceMap) GetCycles() []ServiceMapCycle {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	index := 0
+	indices := make(map[key]int)
+	lowLinks := make(map[key]int)
+	onStack := make(map[key]bool)
+	stack := make([]key, 0)
+	cycles := make([]ServiceMapCycle, 0)
+
+	var strongConnect func(k key)
+	strongConnect = func(k key) {
+		indices[k] = index
+		lowLinks[k] = index
+		index++
+		stack = append(stack, k)
+		onStack[k] = true
+
+		for _, v := range s.graph.neighbors(k, DirectionDownstream) {
+			if _, ok := indices[v]; !ok {
+				strongConnect(v)
+				if lowLinks[v] < lowLinks[k] {
+					lowLinks[k] = lowLinks[v]
+				}
+			} else if onStack[v] && indices[v] < lowLinks[k] {
+				lowLinks[k] = indices[v]
+			}
+		}
+
+		if lowLinks[k] != indices[k] {
+			return
+		}
+
+		component := make([]string, 0)
+		for {
+			v := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[v] = false
+			component = append(component, string(v))
+			if v == k {
+				break
+			}
+		}
+
+		_, selfLoop := s.graph.Edges[k][k]
+		if len(component) > 1 || selfLoop {
+			sort.Strings(component)
+			cycles = append(cycles, ServiceMapCycle{Nodes: component})
+		}
+	}
+
+	for _, k := range s.sortedNodeKeys() {
+		if _, ok := indices[k]; !ok {
+			strongConnect(k)
+		}
+	}
+
+	sort.Slice(cycles, func(i, j int) bool {
+		return cycles[i].Nodes[0] < cycles[j].Nodes[0]
+	})
+
+	return cycles
+}
+
+// GetFanRanking ranks the nodes by the number of distinct callers (fan-in) or callees (fan-out).
+// A limit less than 1 returns every node.
+func (s *defaultServiceMap) GetFanRanking(by string, limit int) ([]ServiceMapFanRank, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if by != RankByFanIn && by != RankByFanOut {
+		return nil, fmt.Errorf("invalid ranking: %s", by)
+	}
+
+	fanIn := make(map[key]int)
+	for _, m := range s.graph.Edges {
+		for v := range m {
+			fanIn[v]++
+		}
+	}
+
+	ranking := make([]ServiceMapFanRank, 0, len(s.graph.Nodes))
+	for _, k := range s.sortedNodeKeys() {
+		ranking = append(ranking, ServiceMapFanRank{
+			Node:   s.toServiceMapNode(k),
+			FanIn:  fanIn[k],
+			FanOut: len(s.graph.Edges[k]),
+		})
+	}
+
+	sort.SliceStable(ranking, func(i, j int) bool {
+		if by == RankByFanIn {
+			return ranking[i].FanIn > ranking[j].FanIn
+		}
+		return ranking[i].FanOut > ranking[j].FanOut
+	})
+
+	if limit > 0 && len(ranking) > limit {
+		ranking = ranking[:limit]
+	}
+
+	return ranking, nil
+}
+
+// GetShortestPath finds the path with the least hops from source to destination following the call direction.
+func (s *defaultServiceMap) GetShortestPath(source string, destination string) (*ServiceMapPathResponse, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	from, to := key(source), key(destination)
+	if _, ok := s.nodeExists(from); !ok {
+		return nil, fmt.Errorf("node %s is not found", source)
+	}
+	if _, ok := s.nodeExists(to); !ok {
+		return nil, fmt.Errorf("node %s is not found", destination)
+	}
+
+	response := &ServiceMapPathResponse{
+		Source:      source,
+		Destination: destination,
+		Path:        make([]ServiceMapNode, 0),
+	}
+
+	parents := map[key]key{from: from}
+	queue := []key{from}
+	for len(queue) > 0 {
+		k := queue[0]
+		queue = queue[1:]
+
+		if k == to {
+			path := []key{to}
+			for path[0] != from {
+				path = append([]key{parents[path[0]]}, path...)
+			}
+			for _, p := range path {
+				response.Path = append(response.Path, s.toServiceMapNode(p))
+			}
+			response.Found = true
+			response.Hops = len(path) - 1
+			return response, nil
+		}
+
+		for _, v := range s.graph.neighbors(k, DirectionDownstream) {
+			if _, ok := parents[v]; ok {
+				continue
+			}
+			parents[v] = k
+			queue = append(queue, v)
+		}
+	}
+
+	return response, nil
+}
+
+func (s *defaultServiceMap) sortedNodeKeys() []key {
+	keys := make([]key, 0, len(s.graph.Nodes))
+	for k := range s.graph.Nodes {
+		keys = append(keys, k)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i] < keys[j]
+	})
+
+	return keys
+}
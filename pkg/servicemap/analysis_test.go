@@ -0,0 +1,165 @@
+package servicemap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newAnalysisServiceMap builds the graph below, where u is the unresolved 127.0.0.1
+//
+//	u -> a -> b -> c -> a
+//	          b -> d -> d
+func newAnalysisServiceMap() *defaultServiceMap {
+	s := NewDefaultServiceMapGenerator()
+	s.Enable()
+
+	s.NewTCPEntry(TCPEntryUnresolved, TCPEntryA, ProtocolHttp)
+	s.NewTCPEntry(TCPEntryA, TCPEntryB, ProtocolHttp)
+	s.NewTCPEntry(TCPEntryB, TCPEntryC, ProtocolHttp)
+	s.NewTCPEntry(TCPEntryC, TCPEntryA, ProtocolHttp)
+	s.NewTCPEntry(TCPEntryB, TCPEntryD, ProtocolRedis)
+	s.NewTCPEntry(TCPEntryD, TCPEntryD, ProtocolRedis)
+
+	return s
+}
+
+func dependencyNames(response *ServiceMapDependenciesResponse) map[string]int {
+	names := make(map[string]int)
+	for _, dependency := range response.Dependencies {
+		names[dependency.Node.Name] = dependency.Depth
+	}
+	return names
+}
+
+func TestGetDependencies(t *testing.T) {
+	tests := map[string]struct {
+		node      string
+		direction string
+		maxDepth  int
+		expected  map[string]int
+		err       bool
+	}{
+		"downstream unlimited": {node: a, direction: DirectionDownstream, maxDepth: 0, expected: map[string]int{b: 1, c: 2, d: 2}},
+		"downstream depth 1":   {node: a, direction: DirectionDownstream, maxDepth: 1, expected: map[string]int{b: 1}},
+		"downstream leaf":      {node: d, direction: DirectionDownstream, maxDepth: 0, expected: map[string]int{}},
+		"upstream unlimited":   {node: a, direction: DirectionUpstream, maxDepth: 0, expected: map[string]int{Ip: 1, c: 1, b: 2}},
+		"upstream depth 1":     {node: d, direction: DirectionUpstream, maxDepth: 1, expected: map[string]int{b: 1}},
+		"upstream root":        {node: Ip, direction: DirectionUpstream, maxDepth: 0, expected: map[string]int{}},
+		"unknown node":         {node: "unknown", direction: DirectionUpstream, err: true},
+		"invalid direction":    {node: a, direction: "sideways", err: true},
+	}
+
+	s := newAnalysisServiceMap()
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			response, err := s.GetDependencies(test.node, test.direction, test.maxDepth)
+			if test.err {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, test.node, response.Node.Name)
+			assert.Equal(t, test.expected, dependencyNames(response))
+		})
+	}
+}
+
+func TestGetCycles(t *testing.T) {
+	tests := map[string]struct {
+		serviceMap *defaultServiceMap
+		expected   []ServiceMapCycle
+	}{
+		"cycles": {
+			serviceMap: newAnalysisServiceMap(),
+			expected: []ServiceMapCycle{
+				{Nodes: []string{a, b, c}},
+				{Nodes: []string{d}},
+			},
+		},
+		"empty": {
+			serviceMap: NewDefaultServiceMapGenerator(),
+			expected:   []ServiceMapCycle{},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.expected, test.serviceMap.GetCycles())
+		})
+	}
+}
+
+func TestGetFanRanking(t *testing.T) {
+	tests := map[string]struct {
+		by       string
+		limit    int
+		expected []string
+		err      bool
+	}{
+		"fan-in":          {by: RankByFanIn, limit: 2, expected: []string{a, d}},
+		"fan-out":         {by: RankByFanOut, limit: 1, expected: []string{b}},
+		"fan-in no limit": {by: RankByFanIn, limit: 0, expected: []string{a, d, b, c, Ip}},
+		"invalid":         {by: "fanSideways", err: true},
+	}
+
+	s := newAnalysisServiceMap()
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			ranking, err := s.GetFanRanking(test.by, test.limit)
+			if test.err {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			names := make([]string, 0)
+			for _, rank := range ranking {
+				names = append(names, rank.Node.Name)
+			}
+			assert.Equal(t, test.expected, names)
+		})
+	}
+}
+
+func TestGetShortestPath(t *testing.T) {
+	tests := map[string]struct {
+		source      string
+		destination string
+		found       bool
+		expected    []string
+		err         bool
+	}{
+		"multiple hops": {source: Ip, destination: d, found: true, expected: []string{Ip, a, b, d}},
+		"through cycle": {source: c, destination: b, found: true, expected: []string{c, a, b}},
+		"same node":     {source: a, destination: a, found: true, expected: []string{a}},
+		"no path":       {source: d, destination: a, found: false, expected: []string{}},
+		"unknown node":  {source: a, destination: "unknown", err: true},
+	}
+
+	s := newAnalysisServiceMap()
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			response, err := s.GetShortestPath(test.source, test.destination)
+			if test.err {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, test.found, response.Found)
+			names := make([]string, 0)
+			for _, node := range response.Path {
+				names = append(names, node.Name)
+			}
+			assert.Equal(t, test.expected, names)
+			if test.found {
+				assert.Equal(t, len(test.expected)-1, response.Hops)
+			}
+		})
+	}
+}
@@ -0,0 +1,219 @@
+package servicemap
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	baseApi "github.com/kubeshark/base/pkg/api"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	BuildRunning   = "running"
+	BuildDone      = "done"
+	BuildCancelled = "cancelled"
+	BuildFailed    = "failed"
+
+	maxKeptBuilds = 10
+)
+
+// HistoricalFeeder streams the stored entries matching the request into the sink.
+// It reports its progress as the number of records Basenine scanned so far out of the total.
+type HistoricalFeeder func(ctx context.Context, request *ServiceMapBuildRequest, sink ServiceMapSink, progress func(current uint64, total uint64)) error
+
+type historicalBuild struct {
+	sync.Mutex
+	status     ServiceMapBuildStatus
+	serviceMap *defaultServiceMap
+	cancel     context.CancelFunc
+}
+
+// lockedSink serializes the writes of the feeder with the reads of the API.
+type lockedSink struct {
+	build *historicalBuild
+}
+
+func (l *lockedSink) NewTCPEntry(src *baseApi.TCP, dst *baseApi.TCP, p *baseApi.Protocol) {
+	l.build.Lock()
+	defer l.build.Unlock()
+
+	l.build.serviceMap.NewTCPEntry(src, dst, p)
+	l.build.status.EntriesProcessed = l.build.serviceMap.GetEntriesProcessedCount()
+}
+
+type HistoricalBuilder struct {
+	lock   sync.Mutex
+	feeder HistoricalFeeder
+	builds map[string]*historicalBuild
+}
+
+func NewHistoricalBuilder(feeder HistoricalFeeder) *HistoricalBuilder {
+	return &HistoricalBuilder{
+		feeder: feeder,
+		builds: make(map[string]*historicalBuild),
+	}
+}
+
+// Start builds a throwaway service map from the stored entries in the background.
+func (b *HistoricalBuilder) Start(request *ServiceMapBuildRequest) ServiceMapBuildStatus {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	serviceMap := NewDefaultServiceMapGenerator()
+	serviceMap.Enable()
+
+	build := &historicalBuild{
+		status: ServiceMapBuildStatus{
+			Id:          uuid.New().String(),
+			Status:      BuildRunning,
+			Query:       request.Query,
+			StartTimeMs: request.StartTimeMs,
+			EndTimeMs:   request.EndTimeMs,
+			CreatedAt:   time.Now().UnixMilli(),
+		},
+		serviceMap: serviceMap,
+		cancel:     cancel,
+	}
+
+	status := build.getStatus()
+
+	b.lock.Lock()
+	b.builds[build.status.Id] = build
+	b.evictFinishedBuilds()
+	b.lock.Unlock()
+
+	go func() {
+		defer cancel()
+
+		err := b.feeder(ctx, request, &lockedSink{build: build}, func(current uint64, total uint64) {
+			build.Lock()
+			build.status.Current = current
+			build.status.Total = total
+			build.Unlock()
+		})
+
+		build.Lock()
+		defer build.Unlock()
+
+		build.status.FinishedAt = time.Now().UnixMilli()
+		switch {
+		case ctx.Err() != nil:
+			build.status.Status = BuildCancelled
+		case err != nil:
+			log.Error().Err(err).Str("build-id", build.status.Id).Msg("While building the service map from stored entries!")
+			build.status.Status = BuildFailed
+			build.status.Error = err.Error()
+		default:
+			build.status.Status = BuildDone
+		}
+	}()
+
+	return status
+}
+
+// Get returns the status of the build along with the nodes and edges collected so far.
+func (b *HistoricalBuilder) Get(id string) (*ServiceMapBuildResponse, error) {
+	build, err := b.getBuild(id)
+	if err != nil {
+		return nil, err
+	}
+
+	build.Lock()
+	defer build.Unlock()
+
+	return &ServiceMapBuildResponse{
+		Build: build.getStatus(),
+		ServiceMapResponse: ServiceMapResponse{
+			Status: build.serviceMap.GetStatus(),
+			Nodes:  build.serviceMap.GetNodes(),
+			Edges:  build.serviceMap.GetEdges(),
+		},
+	}, nil
+}
+
+// List returns the statuses of the kept builds, newest first.
+func (b *HistoricalBuilder) List() []ServiceMapBuildStatus {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	statuses := make([]ServiceMapBuildStatus, 0, len(b.builds))
+	for _, build := range b.builds {
+		build.Lock()
+		statuses = append(statuses, build.getStatus())
+		build.Unlock()
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].CreatedAt > statuses[j].CreatedAt
+	})
+
+	return statuses
+}
+
+// Cancel stops a running build, the nodes and edges collected so far are kept.
+func (b *HistoricalBuilder) Cancel(id string) (ServiceMapBuildStatus, error) {
+	build, err := b.getBuild(id)
+	if err != nil {
+		return ServiceMapBuildStatus{}, err
+	}
+
+	build.cancel()
+
+	build.Lock()
+	defer build.Unlock()
+
+	return build.getStatus(), nil
+}
+
+func (b *HistoricalBuilder) getBuild(id string) (*historicalBuild, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	build, ok := b.builds[id]
+	if !ok {
+		return nil, fmt.Errorf("service map build %s is not found", id)
+	}
+
+	return build, nil
+}
+
+// evictFinishedBuilds drops the oldest finished builds, running builds are never evicted.
+func (b *HistoricalBuilder) evictFinishedBuilds() {
+	if len(b.builds) <= maxKeptBuilds {
+		return
+	}
+
+	finished := make([]*historicalBuild, 0)
+	for _, build := range b.builds {
+		build.Lock()
+		if build.status.Status != BuildRunning {
+			finished = append(finished, build)
+		}
+		build.Unlock()
+	}
+
+	sort.Slice(finished, func(i, j int) bool {
+		return finished[i].status.CreatedAt < finished[j].status.CreatedAt
+	})
+
+	for _, build := range finished {
+		if len(b.builds) <= maxKeptBuilds {
+			return
+		}
+		delete(b.builds, build.status.Id)
+	}
+}
+
+func (h *historicalBuild) getStatus() ServiceMapBuildStatus {
+	status := h.status
+	if status.Total > 0 {
+		status.Progress = float64(status.Current) / float64(status.Total)
+	}
+	if status.Status == BuildDone {
+		status.Progress = 1
+	}
+	return status
+}
@@ -0,0 +1,110 @@
+package servicemap
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func waitForBuild(t *testing.T, builder *HistoricalBuilder, id string) *ServiceMapBuildResponse {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		response, err := builder.Get(id)
+		assert.NoError(t, err)
+		if response.Build.Status != BuildRunning {
+			return response
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatalf("build %s did not finish in time", id)
+	return nil
+}
+
+func TestHistoricalBuild(t *testing.T) {
+	tests := map[string]struct {
+		feeder         HistoricalFeeder
+		expectedStatus string
+		expectedNodes  int
+		expectedEdges  int
+		expectedError  string
+	}{
+		"done": {
+			feeder: func(ctx context.Context, request *ServiceMapBuildRequest, sink ServiceMapSink, progress func(uint64, uint64)) error {
+				sink.NewTCPEntry(TCPEntryA, TCPEntryB, ProtocolHttp)
+				progress(1, 2)
+				sink.NewTCPEntry(TCPEntryA, TCPEntryC, ProtocolRedis)
+				progress(2, 2)
+				return nil
+			},
+			expectedStatus: BuildDone,
+			expectedNodes:  3,
+			expectedEdges:  2,
+		},
+		"failed": {
+			feeder: func(ctx context.Context, request *ServiceMapBuildRequest, sink ServiceMapSink, progress func(uint64, uint64)) error {
+				sink.NewTCPEntry(TCPEntryA, TCPEntryB, ProtocolHttp)
+				return errors.New("connection refused")
+			},
+			expectedStatus: BuildFailed,
+			expectedNodes:  2,
+			expectedEdges:  1,
+			expectedError:  "connection refused",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			builder := NewHistoricalBuilder(test.feeder)
+
+			status := builder.Start(&ServiceMapBuildRequest{Query: "http"})
+			assert.Equal(t, BuildRunning, status.Status)
+			assert.Equal(t, "http", status.Query)
+
+			response := waitForBuild(t, builder, status.Id)
+			assert.Equal(t, test.expectedStatus, response.Build.Status)
+			assert.Equal(t, test.expectedError, response.Build.Error)
+			assert.Equal(t, test.expectedNodes, response.Status.NodeCount)
+			assert.Len(t, response.Nodes, test.expectedNodes)
+			assert.Len(t, response.Edges, test.expectedEdges)
+			assert.Len(t, builder.List(), 1)
+		})
+	}
+}
+
+func TestHistoricalBuildCancel(t *testing.T) {
+	builder := NewHistoricalBuilder(func(ctx context.Context, request *ServiceMapBuildRequest, sink ServiceMapSink, progress func(uint64, uint64)) error {
+		sink.NewTCPEntry(TCPEntryA, TCPEntryB, ProtocolHttp)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	status := builder.Start(&ServiceMapBuildRequest{})
+
+	_, err := builder.Cancel(status.Id)
+	assert.NoError(t, err)
+
+	response := waitForBuild(t, builder, status.Id)
+	assert.Equal(t, BuildCancelled, response.Build.Status)
+
+	_, err = builder.Cancel("unknown")
+	assert.Error(t, err)
+}
+
+func TestHistoricalBuildDoesNotAffectLiveServiceMap(t *testing.T) {
+	live := GetDefaultServiceMapInstance()
+	live.Reset()
+
+	builder := NewHistoricalBuilder(func(ctx context.Context, request *ServiceMapBuildRequest, sink ServiceMapSink, progress func(uint64, uint64)) error {
+		sink.NewTCPEntry(TCPEntryA, TCPEntryB, ProtocolHttp)
+		return nil
+	})
+
+	status := builder.Start(&ServiceMapBuildRequest{})
+	waitForBuild(t, builder, status.Id)
+
+	assert.Equal(t, 0, live.GetNodesCount())
+}
@@ -0,0 +1,160 @@
+package servicemap
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	CollapseNone     = ""
+	CollapseCIDR     = "cidr"
+	CollapseExternal = "external"
+
+	ExternalNodeName = "external"
+
+	defaultCollapseIPv4Prefix = 24
+	defaultCollapseIPv6Prefix = 64
+	idleSweepInterval         = time.Minute
+)
+
+var now = time.Now
+
+// ServiceMapOptions bounds the memory of the service map.
+// Zero values disable the corresponding limit.
+type ServiceMapOptions struct {
+	MaxNodes           int
+	MaxEdges           int
+	IdleTimeout        time.Duration
+	CollapseUnresolved string
+	CollapseIPv4Prefix int
+	CollapseIPv6Prefix int
+}
+
+func (s *defaultServiceMap) SetOptions(options ServiceMapOptions) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	// the two nodes of the entry being added must fit
+	if options.MaxNodes > 0 && options.MaxNodes < 2 {
+		options.MaxNodes = 2
+	}
+	if options.CollapseUnresolved != CollapseNone && options.CollapseUnresolved != CollapseCIDR && options.CollapseUnresolved != CollapseExternal {
+		log.Warn().Str("collapse", options.CollapseUnresolved).Msg("Unknown collapse option of the unresolved IPs, ignoring.")
+		options.CollapseUnresolved = CollapseNone
+	}
+	if options.CollapseIPv4Prefix <= 0 || options.CollapseIPv4Prefix > 32 {
+		options.CollapseIPv4Prefix = defaultCollapseIPv4Prefix
+	}
+	if options.CollapseIPv6Prefix <= 0 || options.CollapseIPv6Prefix > 128 {
+		options.CollapseIPv6Prefix = defaultCollapseIPv6Prefix
+	}
+
+	s.options = options
+	s.enforceLimits()
+}
+
+// unresolvedKey returns the node key of an unresolved peer, which is either its IP
+// or the CIDR bucket / external node it is collapsed into. The collapsed IPs are counted
+// once by a HyperLogLog, which estimates their number in a fixed memory.
+func (s *defaultServiceMap) unresolvedKey(ip string) key {
+	switch s.options.CollapseUnresolved {
+	case CollapseCIDR:
+		s.collapsedPeers.add(ip)
+
+		parsed := net.ParseIP(ip)
+		if parsed == nil {
+			return key(ExternalNodeName)
+		}
+
+		if ipv4 := parsed.To4(); ipv4 != nil {
+			mask := net.CIDRMask(s.options.CollapseIPv4Prefix, 32)
+			return key(fmt.Sprintf("%s/%d", ipv4.Mask(mask), s.options.CollapseIPv4Prefix))
+		}
+
+		mask := net.CIDRMask(s.options.CollapseIPv6Prefix, 128)
+		return key(fmt.Sprintf("%s/%d", parsed.Mask(mask), s.options.CollapseIPv6Prefix))
+	case CollapseExternal:
+		s.collapsedPeers.add(ip)
+		return key(ExternalNodeName)
+	default:
+		return key(ip)
+	}
+}
+
+// enforceLimits evicts the idle nodes and edges, then the least recently seen ones until the caps are met.
+func (s *defaultServiceMap) enforceLimits() {
+	if s.options.IdleTimeout > 0 && now().Sub(s.lastIdleSweep) >= idleSweepInterval {
+		s.lastIdleSweep = now()
+		s.evictIdle(now().Add(-s.options.IdleTimeout))
+	}
+
+	for s.options.MaxEdges > 0 && s.graph.edgeLRU.Len() > s.options.MaxEdges {
+		s.removeEdge(s.graph.edgeLRU.Back().Value.(edgeKey))
+		s.edgesEvicted++
+	}
+
+	for s.options.MaxNodes > 0 && len(s.graph.Nodes) > s.options.MaxNodes {
+		s.removeNode(s.graph.nodeLRU.Back().Value.(key))
+	}
+}
+
+func (s *defaultServiceMap) evictIdle(cutoff time.Time) {
+	for e := s.graph.edgeLRU.Back(); e != nil; e = s.graph.edgeLRU.Back() {
+		k := e.Value.(edgeKey)
+		if !s.graph.Edges[k.u][k.v].data[k.protocol].lastSeen.Before(cutoff) {
+			break
+		}
+		s.removeEdge(k)
+		s.edgesEvicted++
+	}
+
+	for e := s.graph.nodeLRU.Back(); e != nil; e = s.graph.nodeLRU.Back() {
+		k := e.Value.(key)
+		if !s.graph.Nodes[k].lastSeen.Before(cutoff) {
+			break
+		}
+		s.removeNode(k)
+	}
+}
+
+// removeNode evicts the node along with its incoming and outgoing edges.
+func (s *defaultServiceMap) removeNode(k key) {
+	for v, e := range s.graph.Edges[k] {
+		for p := range e.data {
+			s.removeEdge(edgeKey{u: k, v: v, protocol: p})
+			s.edgesEvicted++
+		}
+	}
+
+	for u, m := range s.graph.Edges {
+		if e, ok := m[k]; ok {
+			for p := range e.data {
+				s.removeEdge(edgeKey{u: u, v: k, protocol: p})
+				s.edgesEvicted++
+			}
+		}
+	}
+
+	s.graph.nodeLRU.Remove(s.graph.Nodes[k].element)
+	delete(s.graph.Nodes, k)
+	s.changes.nodeRemoved(k)
+	s.nodesEvicted++
+}
+
+func (s *defaultServiceMap) removeEdge(k edgeKey) {
+	e := s.graph.Edges[k.u][k.v]
+	s.graph.edgeLRU.Remove(e.data[k.protocol].element)
+	delete(e.data, k.protocol)
+
+	if len(e.data) == 0 {
+		delete(s.graph.Edges[k.u], k.v)
+	}
+	if len(s.graph.Edges[k.u]) == 0 {
+		delete(s.graph.Edges, k.u)
+	}
+
+	s.changes.edgeRemoved(k)
+}
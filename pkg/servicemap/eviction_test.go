@@ -0,0 +1,144 @@
+package servicemap
+
+import (
+	"testing"
+	"time"
+
+	baseApi "github.com/kubeshark/base/pkg/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnresolvedCollapse(t *testing.T) {
+	tests := map[string]struct {
+		options       ServiceMapOptions
+		ips           []string
+		expectedNodes []string
+		collapsed     int
+	}{
+		"none": {
+			options:       ServiceMapOptions{},
+			ips:           []string{"10.0.1.1", "10.0.1.2", "10.0.2.1"},
+			expectedNodes: []string{a, "10.0.1.1", "10.0.1.2", "10.0.2.1"},
+			collapsed:     0,
+		},
+		"cidr": {
+			options:       ServiceMapOptions{CollapseUnresolved: CollapseCIDR},
+			ips:           []string{"10.0.1.1", "10.0.1.2", "10.0.2.1", "fd00::1", "fd00::2", "10.0.1.1"},
+			expectedNodes: []string{a, "10.0.1.0/24", "10.0.2.0/24", "fd00::/64"},
+			collapsed:     5,
+		},
+		"cidr with prefix": {
+			options:       ServiceMapOptions{CollapseUnresolved: CollapseCIDR, CollapseIPv4Prefix: 16},
+			ips:           []string{"10.0.1.1", "10.0.2.1", "10.1.0.1"},
+			expectedNodes: []string{a, "10.0.0.0/16", "10.1.0.0/16"},
+			collapsed:     3,
+		},
+		"cidr invalid ip": {
+			options:       ServiceMapOptions{CollapseUnresolved: CollapseCIDR},
+			ips:           []string{"not-an-ip"},
+			expectedNodes: []string{a, ExternalNodeName},
+			collapsed:     1,
+		},
+		"external": {
+			options:       ServiceMapOptions{CollapseUnresolved: CollapseExternal},
+			ips:           []string{"10.0.1.1", "192.168.0.1", "192.168.0.1"},
+			expectedNodes: []string{a, ExternalNodeName},
+			collapsed:     2,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			s := NewDefaultServiceMapGenerator()
+			s.SetOptions(test.options)
+			s.Enable()
+
+			for _, ip := range test.ips {
+				s.NewTCPEntry(&baseApi.TCP{IP: ip, Port: Port}, TCPEntryA, ProtocolHttp)
+			}
+
+			assert.ElementsMatch(t, test.expectedNodes, nodeNames(s.GetNodes()))
+			for _, node := range s.GetNodes() {
+				if node.Name != a {
+					assert.False(t, node.Resolved)
+					assert.Equal(t, node.Name, node.Entry.IP)
+				}
+			}
+			assert.Equal(t, test.collapsed, s.GetStatus().CollapsedPeersCount)
+		})
+	}
+}
+
+func TestMaxNodesEviction(t *testing.T) {
+	s := NewDefaultServiceMapGenerator()
+	s.SetOptions(ServiceMapOptions{MaxNodes: 3})
+	s.Enable()
+
+	s.NewTCPEntry(TCPEntryA, TCPEntryB, ProtocolHttp)
+	s.NewTCPEntry(TCPEntryA, TCPEntryC, ProtocolHttp)
+	s.FlushDelta()
+
+	// b is the least recently seen node
+	s.NewTCPEntry(TCPEntryC, TCPEntryD, ProtocolHttp)
+
+	status := s.GetStatus()
+	assert.ElementsMatch(t, []string{a, c, d}, nodeNames(s.GetNodes()))
+	assert.Equal(t, 3, status.NodeCount)
+	assert.Equal(t, 2, status.EdgeCount)
+	assert.Equal(t, 1, status.NodesEvictedCount)
+	assert.Equal(t, 1, status.EdgesEvictedCount)
+
+	delta := s.FlushDelta()
+	assert.Equal(t, []string{b}, delta.RemovedNodes)
+	assert.Equal(t, []ServiceMapEdgeRef{{Source: a, Destination: b, Protocol: ProtocolHttp.Name}}, delta.RemovedEdges)
+
+	// ids are not reused after an eviction
+	ids := map[int]bool{}
+	for _, node := range s.GetNodes() {
+		assert.False(t, ids[node.Id])
+		ids[node.Id] = true
+	}
+}
+
+func TestMaxEdgesEviction(t *testing.T) {
+	s := NewDefaultServiceMapGenerator()
+	s.SetOptions(ServiceMapOptions{MaxEdges: 2})
+	s.Enable()
+
+	s.NewTCPEntry(TCPEntryA, TCPEntryB, ProtocolHttp)
+	s.NewTCPEntry(TCPEntryA, TCPEntryB, ProtocolRedis)
+	s.NewTCPEntry(TCPEntryA, TCPEntryB, ProtocolHttp)
+	s.NewTCPEntry(TCPEntryA, TCPEntryC, ProtocolHttp)
+
+	edges := s.GetEdges()
+	assert.Len(t, edges, 2)
+	for _, edge := range edges {
+		assert.False(t, edge.Destination.Name == b && edge.Protocol.Name == ProtocolRedis.Name)
+	}
+	assert.Equal(t, 1, s.GetStatus().EdgesEvictedCount)
+	assert.Equal(t, 0, s.GetStatus().NodesEvictedCount)
+}
+
+func TestIdleEviction(t *testing.T) {
+	defer func() { now = time.Now }()
+
+	current := time.Date(2022, 1, 1, 10, 0, 0, 0, time.UTC)
+	now = func() time.Time { return current }
+
+	s := NewDefaultServiceMapGenerator()
+	s.SetOptions(ServiceMapOptions{IdleTimeout: 10 * time.Minute})
+	s.Enable()
+
+	s.NewTCPEntry(TCPEntryA, TCPEntryB, ProtocolHttp)
+
+	current = current.Add(5 * time.Minute)
+	s.NewTCPEntry(TCPEntryC, TCPEntryD, ProtocolHttp)
+
+	current = current.Add(6 * time.Minute)
+	s.NewTCPEntry(TCPEntryC, TCPEntryD, ProtocolHttp)
+
+	status := s.GetStatus()
+	assert.ElementsMatch(t, []string{c, d}, nodeNames(s.GetNodes()))
+	assert.Equal(t, 2, status.NodesEvictedCount)
+	assert.Equal(t, 1, status.EdgesEvictedCount)
+}
@@ -0,0 +1,61 @@
+package servicemap
+
+import (
+	"hash/fnv"
+	"math"
+	"math/bits"
+)
+
+// hyperLogLogPrecision makes 4096 registers, which estimate the count within about 1.6%.
+const hyperLogLogPrecision = 12
+
+// hyperLogLog estimates the number of distinct values added to it in a fixed memory, however many there are.
+type hyperLogLog struct {
+	registers []uint8
+}
+
+func newHyperLogLog() *hyperLogLog {
+	return &hyperLogLog{registers: make([]uint8, 1<<hyperLogLogPrecision)}
+}
+
+// hashValue is the FNV-1a hash of the value, mixed by the finalizer of SplitMix64 for its bits to be uniform.
+func hashValue(value string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(value))
+
+	x := h.Sum64()
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
+
+func (h *hyperLogLog) add(value string) {
+	x := hashValue(value)
+	i := x >> (64 - hyperLogLogPrecision)
+	rank := uint8(bits.LeadingZeros64(x<<hyperLogLogPrecision|1<<(hyperLogLogPrecision-1)) + 1)
+
+	if rank > h.registers[i] {
+		h.registers[i] = rank
+	}
+}
+
+// count estimates the number of distinct values, counting them exactly enough while they are few.
+func (h *hyperLogLog) count() int {
+	m := float64(len(h.registers))
+
+	sum := 0.0
+	zeros := 0
+	for _, register := range h.registers {
+		sum += math.Ldexp(1, -int(register))
+		if register == 0 {
+			zeros++
+		}
+	}
+
+	estimate := 0.7213 / (1 + 1.079/m) * m * m / sum
+	if estimate <= 2.5*m && zeros > 0 {
+		estimate = m * math.Log(m/float64(zeros))
+	}
+
+	return int(math.Round(estimate))
+}
@@ -0,0 +1,34 @@
+package servicemap
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHyperLogLogCount(t *testing.T) {
+	tests := map[string]struct {
+		distinct int
+		delta    float64
+	}{
+		"none":      {distinct: 0, delta: 0},
+		"few":       {distinct: 10, delta: 0},
+		"thousands": {distinct: 1000, delta: 0.05},
+		"many":      {distinct: 100000, delta: 0.05},
+		"lots":      {distinct: 1000000, delta: 0.05},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			h := newHyperLogLog()
+			for i := 0; i < test.distinct; i++ {
+				ip := fmt.Sprintf("10.%d.%d.%d", i>>16&0xff, i>>8&0xff, i&0xff)
+				h.add(ip)
+				h.add(ip)
+			}
+
+			assert.InDelta(t, test.distinct, h.count(), float64(test.distinct)*test.delta)
+		})
+	}
+}
@@ -9,6 +9,9 @@ type ServiceMapStatus struct {
 	EntriesProcessedCount int    `json:"entriesProcessedCount"`
 	NodeCount             int    `json:"nodeCount"`
 	EdgeCount             int    `json:"edgeCount"`
+	NodesEvictedCount     int    `json:"nodesEvictedCount"`
+	EdgesEvictedCount     int    `json:"edgesEvictedCount"`
+	CollapsedPeersCount   int    `json:"collapsedPeersCount"`
 }
 
 type ServiceMapResponse struct {
@@ -26,8 +29,96 @@ type ServiceMapNode struct {
 }
 
 type ServiceMapEdge struct {
-	Source      ServiceMapNode    `json:"source"`
-	Destination ServiceMapNode    `json:"destination"`
-	Count       int               `json:"count"`
-	Protocol    *baseApi.Protocol `json:"protocol"`
+	Source      ServiceMapNode       `json:"source"`
+	Destination ServiceMapNode       `json:"destination"`
+	Count       int                  `json:"count"`
+	Protocol    *baseApi.Protocol    `json:"protocol"`
+	Ports       []ServiceMapEdgePort `json:"ports"`
+}
+
+// ServiceMapEdgePort is a destination port of the traffic of an edge, a service may be reached on several.
+type ServiceMapEdgePort struct {
+	Port  string `json:"port"`
+	Count int    `json:"count"`
+}
+
+type ServiceMapBuildRequest struct {
+	Query       string `json:"query"`
+	StartTimeMs int64  `json:"startTimeMs" validate:"min=0"`
+	EndTimeMs   int64  `json:"endTimeMs" validate:"min=0"`
+}
+
+type ServiceMapBuildStatus struct {
+	Id               string  `json:"id"`
+	Status           string  `json:"status"`
+	Query            string  `json:"query"`
+	StartTimeMs      int64   `json:"startTimeMs"`
+	EndTimeMs        int64   `json:"endTimeMs"`
+	EntriesProcessed int     `json:"entriesProcessed"`
+	Current          uint64  `json:"current"`
+	Total            uint64  `json:"total"`
+	Progress         float64 `json:"progress"`
+	Error            string  `json:"error,omitempty"`
+	CreatedAt        int64   `json:"createdAt"`
+	FinishedAt       int64   `json:"finishedAt,omitempty"`
+}
+
+type ServiceMapBuildResponse struct {
+	ServiceMapResponse
+	Build ServiceMapBuildStatus `json:"build"`
+}
+
+type ServiceMapDependency struct {
+	Node  ServiceMapNode `json:"node"`
+	Depth int            `json:"depth"`
+}
+
+type ServiceMapDependenciesResponse struct {
+	Node         ServiceMapNode         `json:"node"`
+	Direction    string                 `json:"direction"`
+	MaxDepth     int                    `json:"maxDepth"`
+	Dependencies []ServiceMapDependency `json:"dependencies"`
+}
+
+type ServiceMapCycle struct {
+	Nodes []string `json:"nodes"`
+}
+
+type ServiceMapFanRank struct {
+	Node   ServiceMapNode `json:"node"`
+	FanIn  int            `json:"fanIn"`
+	FanOut int            `json:"fanOut"`
+}
+
+type ServiceMapPathResponse struct {
+	Source      string           `json:"source"`
+	Destination string           `json:"destination"`
+	Found       bool             `json:"found"`
+	Hops        int              `json:"hops"`
+	Path        []ServiceMapNode `json:"path"`
+}
+
+type ServiceMapSnapshot struct {
+	Sequence uint64           `json:"sequence"`
+	Status   ServiceMapStatus `json:"status"`
+	Nodes    []ServiceMapNode `json:"nodes"`
+	Edges    []ServiceMapEdge `json:"edges"`
+}
+
+type ServiceMapDelta struct {
+	Sequence     uint64              `json:"sequence"`
+	Reset        bool                `json:"reset"`
+	Status       ServiceMapStatus    `json:"status"`
+	NewNodes     []ServiceMapNode    `json:"newNodes"`
+	UpdatedNodes []ServiceMapNode    `json:"updatedNodes"`
+	NewEdges     []ServiceMapEdge    `json:"newEdges"`
+	UpdatedEdges []ServiceMapEdge    `json:"updatedEdges"`
+	RemovedNodes []string            `json:"removedNodes"`
+	RemovedEdges []ServiceMapEdgeRef `json:"removedEdges"`
+}
+
+type ServiceMapEdgeRef struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	Protocol    string `json:"protocol"`
 }
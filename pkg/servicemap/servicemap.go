@@ -1,7 +1,11 @@
 package servicemap
 
 import (
+	"container/list"
+	"sort"
+	"strconv"
 	"sync"
+	"time"
 
 	"github.com/jinzhu/copier"
 	baseApi "github.com/kubeshark/base/pkg/api"
@@ -26,15 +30,33 @@ func GetDefaultServiceMapInstance() *defaultServiceMap {
 }
 
 type defaultServiceMap struct {
+	lock             sync.RWMutex
 	enabled          bool
 	graph            *graph
 	entriesProcessed int
+	changes          *changeSet
+	sequence         uint64
+	history          []*ServiceMapDelta
+	resetPending     bool
+	options          ServiceMapOptions
+	lastIdleSweep    time.Time
+	nodesEvicted     int
+	edgesEvicted     int
+	collapsedPeers   *hyperLogLog
 }
 
 type ServiceMapSink interface {
 	NewTCPEntry(source *baseApi.TCP, destination *baseApi.TCP, protocol *baseApi.Protocol)
 }
 
+// ServiceMapUpdates lets the subscribers follow the service map incrementally,
+// a snapshot first and then the deltas flushed since the sequence of that snapshot.
+type ServiceMapUpdates interface {
+	GetSnapshot() *ServiceMapSnapshot
+	GetDeltasSince(sequence uint64) ([]*ServiceMapDelta, bool)
+	FlushDelta() *ServiceMapDelta
+}
+
 type ServiceMap interface {
 	Enable()
 	Disable()
@@ -45,6 +67,11 @@ type ServiceMap interface {
 	GetEntriesProcessedCount() int
 	GetNodesCount() int
 	GetEdgesCount() int
+	GetDependencies(name string, direction string, maxDepth int) (*ServiceMapDependenciesResponse, error)
+	GetCycles() []ServiceMapCycle
+	GetFanRanking(by string, limit int) ([]ServiceMapFanRank, error)
+	GetShortestPath(source string, destination string) (*ServiceMapPathResponse, error)
+	SetOptions(options ServiceMapOptions)
 	Reset()
 }
 
@@ -53,6 +80,8 @@ func NewDefaultServiceMapGenerator() *defaultServiceMap {
 		enabled:          false,
 		entriesProcessed: 0,
 		graph:            newDirectedGraph(),
+		changes:          newChangeSet(),
+		collapsedPeers:   newHyperLogLog(),
 	}
 }
 
@@ -64,14 +93,19 @@ type entryData struct {
 }
 
 type nodeData struct {
-	id    int
-	entry *baseApi.TCP
-	count int
+	id       int
+	entry    *baseApi.TCP
+	count    int
+	lastSeen time.Time
+	element  *list.Element
 }
 
 type edgeProtocol struct {
 	protocol *baseApi.Protocol
 	count    int
+	ports    map[string]int
+	lastSeen time.Time
+	element  *list.Element
 }
 
 type edgeData struct {
@@ -81,34 +115,61 @@ type edgeData struct {
 type graph struct {
 	Nodes map[key]*nodeData
 	Edges map[key]map[key]*edgeData
+
+	// least recently seen nodes and edges are at the back
+	nodeLRU    *list.List
+	edgeLRU    *list.List
+	nextNodeId int
 }
 
 func newDirectedGraph() *graph {
 	return &graph{
-		Nodes: make(map[key]*nodeData),
-		Edges: make(map[key]map[key]*edgeData),
+		Nodes:   make(map[key]*nodeData),
+		Edges:   make(map[key]map[key]*edgeData),
+		nodeLRU: list.New(),
+		edgeLRU: list.New(),
 	}
 }
 
-func newNodeData(id int, e *baseApi.TCP) *nodeData {
+func (g *graph) newNodeData(k key, e *baseApi.TCP) *nodeData {
+	g.nextNodeId++
 	return &nodeData{
-		id:    id,
-		entry: e,
-		count: 1,
+		id:       g.nextNodeId,
+		entry:    e,
+		count:    1,
+		lastSeen: now(),
+		element:  g.nodeLRU.PushFront(k),
+	}
+}
+
+func (g *graph) newEdgeProtocol(k edgeKey, p *baseApi.Protocol, port string) *edgeProtocol {
+	return &edgeProtocol{
+		protocol: p,
+		count:    1,
+		ports:    map[string]int{port: 1},
+		lastSeen: now(),
+		element:  g.edgeLRU.PushFront(k),
 	}
 }
 
-func newEdgeData(p *baseApi.Protocol) *edgeData {
+func (g *graph) newEdgeData(k edgeKey, p *baseApi.Protocol, port string) *edgeData {
 	return &edgeData{
 		data: map[key]*edgeProtocol{
-			key(p.Name): {
-				protocol: p,
-				count:    1,
-			},
+			k.protocol: g.newEdgeProtocol(k, p, port),
 		},
 	}
 }
 
+func (g *graph) touchNode(n *nodeData) {
+	n.lastSeen = now()
+	g.nodeLRU.MoveToFront(n.element)
+}
+
+func (g *graph) touchEdge(e *edgeProtocol) {
+	e.lastSeen = now()
+	g.edgeLRU.MoveToFront(e.element)
+}
+
 func (s *defaultServiceMap) nodeExists(k key) (*nodeData, bool) {
 	n, ok := s.graph.Nodes[k]
 	return n, ok
@@ -117,9 +178,12 @@ func (s *defaultServiceMap) nodeExists(k key) (*nodeData, bool) {
 func (s *defaultServiceMap) addNode(k key, e *baseApi.TCP) (*nodeData, bool) {
 	nd, exists := s.nodeExists(k)
 	if !exists {
-		s.graph.Nodes[k] = newNodeData(len(s.graph.Nodes)+1, e)
+		s.graph.Nodes[k] = s.graph.newNodeData(k, e)
+		s.changes.nodeAdded(k)
 		return s.graph.Nodes[k], true
 	}
+	s.graph.touchNode(nd)
+	s.changes.nodeUpdated(k)
 	return nd, false
 }
 
@@ -141,40 +205,57 @@ func (s *defaultServiceMap) addEdge(u, v *entryData, p *baseApi.Protocol) {
 		// edge data already exists for u -> v pair
 		// we have a new protocol for this u -> v pair
 
-		k := key(p.Name)
-		if pd, pOk := e.data[k]; pOk {
+		k := edgeKey{u: u.key, v: v.key, protocol: key(p.Name)}
+		if pd, pOk := e.data[k.protocol]; pOk {
 			// protocol key already exists, just increment the count
 			pd.count++
+			pd.ports[v.entry.Port]++
+			s.graph.touchEdge(pd)
+			s.changes.edgeUpdated(k)
 		} else {
 			// new protocol key
-			e.data[k] = &edgeProtocol{
-				protocol: p,
-				count:    1,
-			}
+			e.data[k.protocol] = s.graph.newEdgeProtocol(k, p, v.entry.Port)
+			s.changes.edgeAdded(k)
 		}
 	} else {
 		// new edge data for u -> v pair
-		s.graph.Edges[u.key][v.key] = newEdgeData(p)
+		k := edgeKey{u: u.key, v: v.key, protocol: key(p.Name)}
+		s.graph.Edges[u.key][v.key] = s.graph.newEdgeData(k, p, v.entry.Port)
+		s.changes.edgeAdded(k)
 	}
 
 	s.entriesProcessed++
+
+	s.enforceLimits()
 }
 
 func (s *defaultServiceMap) Enable() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
 	s.enabled = true
 }
 
 func (s *defaultServiceMap) Disable() {
-	s.Reset()
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.reset()
 	s.enabled = false
 }
 
 func (s *defaultServiceMap) IsEnabled() bool {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
 	return s.enabled
 }
 
 func (s *defaultServiceMap) NewTCPEntry(src *baseApi.TCP, dst *baseApi.TCP, p *baseApi.Protocol) {
-	if !s.IsEnabled() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if !s.enabled {
 		return
 	}
 
@@ -183,13 +264,14 @@ func (s *defaultServiceMap) NewTCPEntry(src *baseApi.TCP, dst *baseApi.TCP, p *b
 
 	if len(src.Name) == 0 {
 		srcEntry = &entryData{
-			key:   key(src.IP),
+			key:   s.unresolvedKey(src.IP),
 			entry: &baseApi.TCP{},
 		}
 		if err := copier.Copy(srcEntry.entry, src); err != nil {
 			log.Error().Err(err).Msg("While copying src entry into src entry data.")
 		}
 
+		srcEntry.entry.IP = string(srcEntry.key)
 		srcEntry.entry.Name = UnresolvedNodeName
 	} else {
 		srcEntry = &entryData{
@@ -200,13 +282,14 @@ func (s *defaultServiceMap) NewTCPEntry(src *baseApi.TCP, dst *baseApi.TCP, p *b
 
 	if len(dst.Name) == 0 {
 		dstEntry = &entryData{
-			key:   key(dst.IP),
+			key:   s.unresolvedKey(dst.IP),
 			entry: &baseApi.TCP{},
 		}
 		if err := copier.Copy(dstEntry.entry, dst); err != nil {
 			log.Error().Err(err).Msg("While copying dst entry into dst entry data.")
 		}
 
+		dstEntry.entry.IP = string(dstEntry.key)
 		dstEntry.entry.Name = UnresolvedNodeName
 	} else {
 		dstEntry = &entryData{
@@ -219,58 +302,93 @@ func (s *defaultServiceMap) NewTCPEntry(src *baseApi.TCP, dst *baseApi.TCP, p *b
 }
 
 func (s *defaultServiceMap) GetStatus() ServiceMapStatus {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.status()
+}
+
+func (s *defaultServiceMap) GetNodes() []ServiceMapNode {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.nodes()
+}
+
+func (s *defaultServiceMap) GetEdges() []ServiceMapEdge {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.edges()
+}
+
+func (s *defaultServiceMap) GetEntriesProcessedCount() int {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.entriesProcessed
+}
+
+func (s *defaultServiceMap) GetNodesCount() int {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.nodesCount()
+}
+
+func (s *defaultServiceMap) GetEdgesCount() int {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.edgesCount()
+}
+
+func (s *defaultServiceMap) Reset() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.reset()
+}
+
+func (s *defaultServiceMap) status() ServiceMapStatus {
 	status := ServiceMapDisabled
-	if s.IsEnabled() {
+	if s.enabled {
 		status = ServiceMapEnabled
 	}
 
 	return ServiceMapStatus{
 		Status:                status,
 		EntriesProcessedCount: s.entriesProcessed,
-		NodeCount:             s.GetNodesCount(),
-		EdgeCount:             s.GetEdgesCount(),
+		NodeCount:             s.nodesCount(),
+		EdgeCount:             s.edgesCount(),
+		NodesEvictedCount:     s.nodesEvicted,
+		EdgesEvictedCount:     s.edgesEvicted,
+		CollapsedPeersCount:   s.collapsedPeers.count(),
 	}
 }
 
-func (s *defaultServiceMap) GetNodes() []ServiceMapNode {
+func (s *defaultServiceMap) nodes() []ServiceMapNode {
 	nodes := []ServiceMapNode{}
 
-	for i, n := range s.graph.Nodes {
-		nodes = append(nodes, ServiceMapNode{
-			Id:       n.id,
-			Name:     string(i),
-			Resolved: n.entry.Name != UnresolvedNodeName,
-			Entry:    n.entry,
-			Count:    n.count,
-		})
+	for k := range s.graph.Nodes {
+		nodes = append(nodes, s.toServiceMapNode(k))
 	}
 
 	return nodes
 }
 
-func (s *defaultServiceMap) GetEdges() []ServiceMapEdge {
+func (s *defaultServiceMap) edges() []ServiceMapEdge {
 	edges := []ServiceMapEdge{}
 
 	for u, m := range s.graph.Edges {
 		for v := range m {
 			for _, p := range s.graph.Edges[u][v].data {
 				edges = append(edges, ServiceMapEdge{
-					Source: ServiceMapNode{
-						Id:       s.graph.Nodes[u].id,
-						Name:     string(u),
-						Entry:    s.graph.Nodes[u].entry,
-						Resolved: s.graph.Nodes[u].entry.Name != UnresolvedNodeName,
-						Count:    s.graph.Nodes[u].count,
-					},
-					Destination: ServiceMapNode{
-						Id:       s.graph.Nodes[v].id,
-						Name:     string(v),
-						Entry:    s.graph.Nodes[v].entry,
-						Resolved: s.graph.Nodes[v].entry.Name != UnresolvedNodeName,
-						Count:    s.graph.Nodes[v].count,
-					},
-					Count:    p.count,
-					Protocol: p.protocol,
+					Source:      s.toServiceMapNode(u),
+					Destination: s.toServiceMapNode(v),
+					Count:       p.count,
+					Protocol:    p.protocol,
+					Ports:       p.toServiceMapEdgePorts(),
 				})
 			}
 		}
@@ -279,27 +397,40 @@ func (s *defaultServiceMap) GetEdges() []ServiceMapEdge {
 	return edges
 }
 
-func (s *defaultServiceMap) GetEntriesProcessedCount() int {
-	return s.entriesProcessed
+// toServiceMapEdgePorts returns the observed destination ports of an edge, in their numeric order.
+func (e *edgeProtocol) toServiceMapEdgePorts() []ServiceMapEdgePort {
+	ports := make([]ServiceMapEdgePort, 0, len(e.ports))
+	for port, count := range e.ports {
+		ports = append(ports, ServiceMapEdgePort{Port: port, Count: count})
+	}
+
+	sort.Slice(ports, func(i, j int) bool {
+		a, errA := strconv.Atoi(ports[i].Port)
+		b, errB := strconv.Atoi(ports[j].Port)
+		if errA != nil || errB != nil || a == b {
+			return ports[i].Port < ports[j].Port
+		}
+		return a < b
+	})
+
+	return ports
 }
 
-func (s *defaultServiceMap) GetNodesCount() int {
+func (s *defaultServiceMap) nodesCount() int {
 	return len(s.graph.Nodes)
 }
 
-func (s *defaultServiceMap) GetEdgesCount() int {
-	var count int
-	for u, m := range s.graph.Edges {
-		for v := range m {
-			for range s.graph.Edges[u][v].data {
-				count++
-			}
-		}
-	}
-	return count
+func (s *defaultServiceMap) edgesCount() int {
+	return s.graph.edgeLRU.Len()
 }
 
-func (s *defaultServiceMap) Reset() {
+func (s *defaultServiceMap) reset() {
 	s.entriesProcessed = 0
 	s.graph = newDirectedGraph()
+	s.changes = newChangeSet()
+	s.history = nil
+	s.resetPending = true
+	s.nodesEvicted = 0
+	s.edgesEvicted = 0
+	s.collapsedPeers = newHyperLogLog()
 }
@@ -6,6 +6,8 @@ import (
 	"testing"
 
 	baseApi "github.com/kubeshark/base/pkg/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -417,3 +419,18 @@ func TestServiceMapSuite(t *testing.T) {
 	suite.Run(t, new(ServiceMapDisabledSuite))
 	suite.Run(t, new(ServiceMapEnabledSuite))
 }
+
+func TestEdgePorts(t *testing.T) {
+	serviceMap := NewDefaultServiceMapGenerator()
+	serviceMap.Enable()
+
+	for _, port := range []string{"8080", "80", "8080", "443"} {
+		destination := *TCPEntryB
+		destination.Port = port
+		serviceMap.NewTCPEntry(TCPEntryA, &destination, ProtocolHttp)
+	}
+
+	edges := serviceMap.GetEdges()
+	require.Len(t, edges, 1)
+	assert.Equal(t, []ServiceMapEdgePort{{Port: "80", Count: 1}, {Port: "443", Count: 1}, {Port: "8080", Count: 2}}, edges[0].Ports)
+}
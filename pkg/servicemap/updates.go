@@ -0,0 +1,194 @@
+package servicemap
+
+const maxDeltaHistory = 100
+
+type edgeKey struct {
+	u        key
+	v        key
+	protocol key
+}
+
+// changeSet collects what changed in the graph since the last flushed delta.
+type changeSet struct {
+	newNodes     map[key]bool
+	updatedNodes map[key]bool
+	newEdges     map[edgeKey]bool
+	updatedEdges map[edgeKey]bool
+	removedNodes map[key]bool
+	removedEdges map[edgeKey]bool
+}
+
+func newChangeSet() *changeSet {
+	return &changeSet{
+		newNodes:     make(map[key]bool),
+		updatedNodes: make(map[key]bool),
+		newEdges:     make(map[edgeKey]bool),
+		updatedEdges: make(map[edgeKey]bool),
+		removedNodes: make(map[key]bool),
+		removedEdges: make(map[edgeKey]bool),
+	}
+}
+
+func (c *changeSet) nodeAdded(k key) {
+	c.newNodes[k] = true
+}
+
+func (c *changeSet) nodeUpdated(k key) {
+	if !c.newNodes[k] {
+		c.updatedNodes[k] = true
+	}
+}
+
+func (c *changeSet) edgeAdded(k edgeKey) {
+	c.newEdges[k] = true
+}
+
+func (c *changeSet) edgeUpdated(k edgeKey) {
+	if !c.newEdges[k] {
+		c.updatedEdges[k] = true
+	}
+}
+
+// nodeRemoved is applied by the subscribers before the new and updated nodes,
+// so a node evicted and seen again within the same delta ends up in both sets.
+func (c *changeSet) nodeRemoved(k key) {
+	delete(c.newNodes, k)
+	delete(c.updatedNodes, k)
+	c.removedNodes[k] = true
+}
+
+func (c *changeSet) edgeRemoved(k edgeKey) {
+	delete(c.newEdges, k)
+	delete(c.updatedEdges, k)
+	c.removedEdges[k] = true
+}
+
+func (c *changeSet) isEmpty() bool {
+	return len(c.newNodes) == 0 && len(c.updatedNodes) == 0 && len(c.newEdges) == 0 && len(c.updatedEdges) == 0 &&
+		len(c.removedNodes) == 0 && len(c.removedEdges) == 0
+}
+
+func (s *defaultServiceMap) toServiceMapEdge(k edgeKey) (ServiceMapEdge, bool) {
+	e, ok := s.graph.Edges[k.u][k.v]
+	if !ok {
+		return ServiceMapEdge{}, false
+	}
+
+	p, ok := e.data[k.protocol]
+	if !ok {
+		return ServiceMapEdge{}, false
+	}
+
+	return ServiceMapEdge{
+		Source:      s.toServiceMapNode(k.u),
+		Destination: s.toServiceMapNode(k.v),
+		Count:       p.count,
+		Protocol:    p.protocol,
+		Ports:       p.toServiceMapEdgePorts(),
+	}, true
+}
+
+func (s *defaultServiceMap) toServiceMapNodes(keys map[key]bool) []ServiceMapNode {
+	nodes := make([]ServiceMapNode, 0, len(keys))
+	for k := range keys {
+		if _, ok := s.nodeExists(k); ok {
+			nodes = append(nodes, s.toServiceMapNode(k))
+		}
+	}
+	return nodes
+}
+
+func (s *defaultServiceMap) toServiceMapEdges(keys map[edgeKey]bool) []ServiceMapEdge {
+	edges := make([]ServiceMapEdge, 0, len(keys))
+	for k := range keys {
+		if edge, ok := s.toServiceMapEdge(k); ok {
+			edges = append(edges, edge)
+		}
+	}
+	return edges
+}
+
+// GetSnapshot returns the whole graph along with the sequence of the last flushed delta.
+func (s *defaultServiceMap) GetSnapshot() *ServiceMapSnapshot {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return &ServiceMapSnapshot{
+		Sequence: s.sequence,
+		Status:   s.status(),
+		Nodes:    s.nodes(),
+		Edges:    s.edges(),
+	}
+}
+
+// GetDeltasSince returns the deltas flushed after the given sequence.
+// It returns false if some of them are not kept anymore, so the subscriber needs a new snapshot instead.
+func (s *defaultServiceMap) GetDeltasSince(sequence uint64) ([]*ServiceMapDelta, bool) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	deltas := make([]*ServiceMapDelta, 0)
+	if sequence > s.sequence {
+		return deltas, false
+	}
+	if sequence == s.sequence {
+		return deltas, true
+	}
+	if len(s.history) == 0 || s.history[0].Sequence > sequence+1 {
+		return deltas, false
+	}
+
+	for _, delta := range s.history {
+		if delta.Sequence > sequence {
+			deltas = append(deltas, delta)
+		}
+	}
+
+	return deltas, true
+}
+
+// FlushDelta turns the changes since the previous flush into a new delta, it returns nil if nothing has changed.
+// Nodes and edges carry their absolute counts, so applying a delta on top of a newer snapshot is harmless.
+func (s *defaultServiceMap) FlushDelta() *ServiceMapDelta {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.changes.isEmpty() && !s.resetPending {
+		return nil
+	}
+
+	s.sequence++
+	delta := &ServiceMapDelta{
+		Sequence:     s.sequence,
+		Reset:        s.resetPending,
+		Status:       s.status(),
+		NewNodes:     s.toServiceMapNodes(s.changes.newNodes),
+		UpdatedNodes: s.toServiceMapNodes(s.changes.updatedNodes),
+		NewEdges:     s.toServiceMapEdges(s.changes.newEdges),
+		UpdatedEdges: s.toServiceMapEdges(s.changes.updatedEdges),
+		RemovedNodes: make([]string, 0, len(s.changes.removedNodes)),
+		RemovedEdges: make([]ServiceMapEdgeRef, 0, len(s.changes.removedEdges)),
+	}
+
+	for k := range s.changes.removedNodes {
+		delta.RemovedNodes = append(delta.RemovedNodes, string(k))
+	}
+
+	for k := range s.changes.removedEdges {
+		delta.RemovedEdges = append(delta.RemovedEdges, ServiceMapEdgeRef{
+			Source:      string(k.u),
+			Destination: string(k.v),
+			Protocol:    string(k.protocol),
+		})
+	}
+
+	s.changes = newChangeSet()
+	s.resetPending = false
+
+	s.history = append(s.history, delta)
+	if len(s.history) > maxDeltaHistory {
+		s.history = s.history[len(s.history)-maxDeltaHistory:]
+	}
+
+	return delta
+}
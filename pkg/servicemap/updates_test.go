@@ -0,0 +1,100 @@
+package servicemap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func nodeNames(nodes []ServiceMapNode) []string {
+	names := make([]string, 0)
+	for _, node := range nodes {
+		names = append(names, node.Name)
+	}
+	return names
+}
+
+func TestFlushDelta(t *testing.T) {
+	s := NewDefaultServiceMapGenerator()
+	s.Enable()
+
+	assert.Nil(t, s.FlushDelta())
+
+	s.NewTCPEntry(TCPEntryA, TCPEntryB, ProtocolHttp)
+	delta := s.FlushDelta()
+	assert.Equal(t, uint64(1), delta.Sequence)
+	assert.False(t, delta.Reset)
+	assert.ElementsMatch(t, []string{a, b}, nodeNames(delta.NewNodes))
+	assert.Empty(t, delta.UpdatedNodes)
+	assert.Len(t, delta.NewEdges, 1)
+	assert.Empty(t, delta.UpdatedEdges)
+
+	assert.Nil(t, s.FlushDelta())
+
+	s.NewTCPEntry(TCPEntryA, TCPEntryB, ProtocolHttp)
+	s.NewTCPEntry(TCPEntryA, TCPEntryC, ProtocolHttp)
+	delta = s.FlushDelta()
+	assert.Equal(t, uint64(2), delta.Sequence)
+	assert.ElementsMatch(t, []string{c}, nodeNames(delta.NewNodes))
+	assert.ElementsMatch(t, []string{a, b}, nodeNames(delta.UpdatedNodes))
+	assert.Len(t, delta.NewEdges, 1)
+	assert.Len(t, delta.UpdatedEdges, 1)
+	assert.Equal(t, 2, delta.UpdatedEdges[0].Count)
+
+	s.Reset()
+	delta = s.FlushDelta()
+	assert.Equal(t, uint64(3), delta.Sequence)
+	assert.True(t, delta.Reset)
+	assert.Empty(t, delta.NewNodes)
+	assert.Equal(t, 0, delta.Status.NodeCount)
+}
+
+func TestGetDeltasSince(t *testing.T) {
+	s := NewDefaultServiceMapGenerator()
+	s.Enable()
+
+	for i := 0; i < maxDeltaHistory+5; i++ {
+		s.NewTCPEntry(TCPEntryA, TCPEntryB, ProtocolHttp)
+		s.FlushDelta()
+	}
+
+	current := uint64(maxDeltaHistory + 5)
+
+	tests := map[string]struct {
+		sequence uint64
+		ok       bool
+		expected int
+	}{
+		"up to date":       {sequence: current, ok: true, expected: 0},
+		"behind":           {sequence: current - 3, ok: true, expected: 3},
+		"oldest kept":      {sequence: current - maxDeltaHistory, ok: true, expected: maxDeltaHistory},
+		"not kept anymore": {sequence: current - maxDeltaHistory - 1, ok: false, expected: 0},
+		"from the future":  {sequence: current + 1, ok: false, expected: 0},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			deltas, ok := s.GetDeltasSince(test.sequence)
+			assert.Equal(t, test.ok, ok)
+			assert.Len(t, deltas, test.expected)
+			for i, delta := range deltas {
+				assert.Equal(t, test.sequence+uint64(i)+1, delta.Sequence)
+			}
+		})
+	}
+}
+
+func TestGetSnapshot(t *testing.T) {
+	s := NewDefaultServiceMapGenerator()
+	s.Enable()
+
+	s.NewTCPEntry(TCPEntryA, TCPEntryB, ProtocolHttp)
+	s.FlushDelta()
+	s.NewTCPEntry(TCPEntryB, TCPEntryC, ProtocolHttp)
+
+	snapshot := s.GetSnapshot()
+	assert.Equal(t, uint64(1), snapshot.Sequence)
+	assert.Len(t, snapshot.Nodes, 3)
+	assert.Len(t, snapshot.Edges, 2)
+	assert.Equal(t, 2, snapshot.Status.EntriesProcessedCount)
+}
@@ -0,0 +1,359 @@
+package suggestions
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kubeshark/base/pkg/api"
+)
+
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+
+	valuesPerField      = 50  // the least recently seen value of a field is evicted beyond it
+	fieldsPerProtocol   = 300 // keeps the headers and the bodies of some protocols from growing the catalog endlessly
+	maxFieldDepth       = 5
+	fullySampledEntries = 100 // the fields of the first entries of a protocol are all walked, then one every fieldsSamplingRate
+	fieldsSamplingRate  = 100
+)
+
+// CommonFields are the fields of the entries of every protocol.
+var CommonFields = []string{
+	"protocol.name",
+	"protocol.version",
+	"protocol.abbr",
+	"capture",
+	"src.ip",
+	"src.port",
+	"src.name",
+	"dst.ip",
+	"dst.port",
+	"dst.name",
+	"namespace",
+	"outgoing",
+	"timestamp",
+	"requestSize",
+	"responseSize",
+	"elapsedTime",
+}
+
+var (
+	identifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	// a condition on a single field, e.g. response.status == 200, the field it is on is the one of its value
+	singleConditionPattern = regexp.MustCompile(`^\s*([A-Za-z_][A-Za-z0-9_.\[\]"-]*)\s*==\s*("[^"]*"|[^\s"]+)\s*$`)
+)
+
+type Macro struct {
+	Name     string `json:"name"`
+	Expanded string `json:"expanded"`
+}
+
+// Value is a value of a field seen in the recent traffic, along with the query that filters the entries by it.
+type Value struct {
+	Field    string `json:"field"`
+	Value    string `json:"value"`
+	Query    string `json:"query"`
+	Count    int64  `json:"count"`
+	LastSeen int64  `json:"lastSeen"`
+}
+
+type Fields struct {
+	Macros    []*Macro            `json:"macros"`
+	Common    []string            `json:"common"`
+	Protocols map[string][]string `json:"protocols"`
+	Values    map[string][]*Value `json:"values"`
+}
+
+type Suggestions struct {
+	Macros []*Macro `json:"macros"`
+	Fields []string `json:"fields"`
+	Values []*Value `json:"values"`
+}
+
+type protocolFields struct {
+	entriesCount int64
+	paths        map[string]struct{}
+}
+
+// Catalog learns the fields of the entries of each protocol and the values of the fields that filter the traffic
+// the most, e.g. the services, the paths, the status codes and the Kafka topics, from the ingested entries.
+type Catalog struct {
+	lock      sync.Mutex
+	protocols map[string]*protocolFields
+	values    map[string]map[string]*Value
+}
+
+var (
+	instance *Catalog
+	once     sync.Once
+)
+
+func GetDefaultCatalogInstance() *Catalog {
+	once.Do(func() {
+		instance = NewCatalog()
+	})
+
+	return instance
+}
+
+func NewCatalog() *Catalog {
+	return &Catalog{
+		protocols: make(map[string]*protocolFields),
+		values:    make(map[string]map[string]*Value),
+	}
+}
+
+// GetMacros returns the macros the dissectors define in Basenine, sorted by name.
+func GetMacros(extensions []*api.Extension) []*Macro {
+	macros := make([]*Macro, 0)
+	for _, extension := range extensions {
+		for name, expanded := range extension.Dissector.Macros() {
+			macros = append(macros, &Macro{Name: name, Expanded: expanded})
+		}
+	}
+
+	sort.Slice(macros, func(i, j int) bool {
+		return macros[i].Name < macros[j].Name
+	})
+
+	return macros
+}
+
+func (c *Catalog) Add(entry *api.Entry, summary *api.BaseEntry) {
+	c.AddAt(entry, summary, time.Now())
+}
+
+func (c *Catalog) AddAt(entry *api.Entry, summary *api.BaseEntry, now time.Time) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.addFields(entry)
+
+	lastSeen := now.UnixMilli()
+	if entry.Source != nil {
+		c.addValue("src.name", entry.Source.Name, "", lastSeen)
+	}
+	if entry.Destination != nil {
+		c.addValue("dst.name", entry.Destination.Name, "", lastSeen)
+	}
+	c.addValue("namespace", entry.Namespace, "", lastSeen)
+
+	if summary != nil {
+		c.addValue("summary", summary.Summary, summary.SummaryQuery, lastSeen)
+		c.addValue("method", summary.Method, summary.MethodQuery, lastSeen)
+		if summary.StatusQuery != "" {
+			c.addValue("status", strconv.Itoa(summary.Status), summary.StatusQuery, lastSeen)
+		}
+	}
+}
+
+func (c *Catalog) addFields(entry *api.Entry) {
+	protocol := entry.Protocol.Abbreviation
+	fields, ok := c.protocols[protocol]
+	if !ok {
+		fields = &protocolFields{paths: make(map[string]struct{})}
+		c.protocols[protocol] = fields
+	}
+
+	fields.entriesCount++
+	if fields.entriesCount > fullySampledEntries && fields.entriesCount%fieldsSamplingRate != 0 {
+		return
+	}
+
+	add := func(path string) {
+		if len(fields.paths) < fieldsPerProtocol {
+			fields.paths[path] = struct{}{}
+		}
+	}
+	walkFields("request", entry.Request, 1, add)
+	walkFields("response", entry.Response, 1, add)
+}
+
+// walkFields adds the paths of the leaves of a decoded JSON value in the Basenine syntax,
+// the keys that are not identifiers, such as the names of the headers, in brackets and the arrays by their first item.
+func walkFields(path string, value interface{}, depth int, add func(path string)) {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		if depth >= maxFieldDepth {
+			add(path)
+			return
+		}
+		for key, child := range value {
+			walkFields(getChildPath(path, key), child, depth+1, add)
+		}
+	case []interface{}:
+		if len(value) == 0 || depth >= maxFieldDepth {
+			add(path)
+			return
+		}
+		walkFields(path+"[0]", value[0], depth+1, add)
+	default:
+		add(path)
+	}
+}
+
+func getChildPath(path string, key string) string {
+	if identifierPattern.MatchString(key) {
+		return path + "." + key
+	}
+	return fmt.Sprintf("%s[%s]", path, strconv.Quote(key))
+}
+
+// addValue counts a value of a field, its query compares the field to it unless the dissector has given one.
+// The values with a query on a single field are kept by that field, e.g. the paths by request.path.
+func (c *Catalog) addValue(field string, value string, query string, lastSeen int64) {
+	if value == "" {
+		return
+	}
+
+	if query == "" {
+		query = fmt.Sprintf("%s == %s", field, strconv.Quote(value))
+	} else if match := singleConditionPattern.FindStringSubmatch(query); match != nil {
+		field = match[1]
+	}
+
+	values, ok := c.values[field]
+	if !ok {
+		values = make(map[string]*Value)
+		c.values[field] = values
+	}
+
+	if existing, ok := values[value]; ok {
+		existing.Count++
+		existing.LastSeen = lastSeen
+		existing.Query = query
+		return
+	}
+
+	if len(values) >= valuesPerField {
+		evictLeastRecent(values)
+	}
+	values[value] = &Value{Field: field, Value: value, Query: query, Count: 1, LastSeen: lastSeen}
+}
+
+func evictLeastRecent(values map[string]*Value) {
+	var leastRecent *Value
+	for _, value := range values {
+		if leastRecent == nil || value.LastSeen < leastRecent.LastSeen {
+			leastRecent = value
+		}
+	}
+	if leastRecent != nil {
+		delete(values, leastRecent.Value)
+	}
+}
+
+// GetFields returns the macros, the fields by protocol abbreviation and the most frequent values of the fields.
+func (c *Catalog) GetFields(macros []*Macro, limit int) *Fields {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	fields := &Fields{
+		Macros:    macros,
+		Common:    CommonFields,
+		Protocols: make(map[string][]string),
+		Values:    make(map[string][]*Value),
+	}
+
+	for protocol, protocolFields := range c.protocols {
+		fields.Protocols[protocol] = getSortedPaths(protocolFields.paths)
+	}
+
+	for field, values := range c.values {
+		fields.Values[field] = getTopValues(values, limit, func(value *Value) bool { return true })
+	}
+
+	return fields
+}
+
+// Suggest returns the macros, the fields and the values that start with the prefix, regardless of case.
+// A value also matches when its query starts with the prefix, e.g. src.name == "ca matches the carts service.
+func (c *Catalog) Suggest(macros []*Macro, prefix string, limit int) *Suggestions {
+	prefix = strings.ToLower(strings.TrimSpace(prefix))
+	hasPrefix := func(s string) bool {
+		return strings.HasPrefix(strings.ToLower(s), prefix)
+	}
+
+	suggestions := &Suggestions{
+		Macros: make([]*Macro, 0),
+		Fields: make([]string, 0),
+	}
+
+	for _, macro := range macros {
+		if len(suggestions.Macros) < limit && hasPrefix(macro.Name) {
+			suggestions.Macros = append(suggestions.Macros, macro)
+		}
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	paths := make(map[string]struct{})
+	for _, field := range CommonFields {
+		paths[field] = struct{}{}
+	}
+	for _, protocolFields := range c.protocols {
+		for path := range protocolFields.paths {
+			paths[path] = struct{}{}
+		}
+	}
+	for _, path := range getSortedPaths(paths) {
+		if len(suggestions.Fields) < limit && hasPrefix(path) {
+			suggestions.Fields = append(suggestions.Fields, path)
+		}
+	}
+
+	allValues := make(map[string]*Value)
+	for field, values := range c.values {
+		for value, v := range values {
+			allValues[field+"\x00"+value] = v
+		}
+	}
+	suggestions.Values = getTopValues(allValues, limit, func(value *Value) bool {
+		return hasPrefix(value.Value) || hasPrefix(value.Query)
+	})
+
+	return suggestions
+}
+
+func getSortedPaths(paths map[string]struct{}) []string {
+	sorted := make([]string, 0, len(paths))
+	for path := range paths {
+		sorted = append(sorted, path)
+	}
+	sort.Strings(sorted)
+
+	return sorted
+}
+
+// getTopValues returns copies of the most frequent values that match, the most recent first among the equally frequent.
+func getTopValues(values map[string]*Value, limit int, match func(value *Value) bool) []*Value {
+	top := make([]*Value, 0)
+	for _, value := range values {
+		if match(value) {
+			valueCopy := *value
+			top = append(top, &valueCopy)
+		}
+	}
+
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Count != top[j].Count {
+			return top[i].Count > top[j].Count
+		}
+		if top[i].LastSeen != top[j].LastSeen {
+			return top[i].LastSeen > top[j].LastSeen
+		}
+		return top[i].Query < top[j].Query
+	})
+
+	if len(top) > limit {
+		top = top[:limit]
+	}
+	return top
+}
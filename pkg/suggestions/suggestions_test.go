@@ -0,0 +1,102 @@
+package suggestions
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/kubeshark/base/pkg/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var start = time.Date(2022, time.Month(1), 1, 10, 0, 0, 0, time.UTC)
+
+func addHttpEntry(catalog *Catalog, at time.Time, source string, destination string, path string, status int) {
+	entry := &api.Entry{
+		Protocol:    api.ProtocolSummary{Name: "http", Abbreviation: "HTTP"},
+		Source:      &api.TCP{Name: source},
+		Destination: &api.TCP{Name: destination},
+		Namespace:   "shop",
+		Request: map[string]interface{}{
+			"path":    path,
+			"method":  "GET",
+			"headers": map[string]interface{}{"Content-Type": "application/json"},
+			"cookies": []interface{}{map[string]interface{}{"name": "session"}},
+		},
+		Response: map[string]interface{}{"status": float64(status)},
+	}
+	summary := &api.BaseEntry{
+		Summary:      path,
+		SummaryQuery: fmt.Sprintf(`request.path == "%s"`, path),
+		Method:       "GET",
+		MethodQuery:  `request.method == "GET"`,
+		Status:       status,
+		StatusQuery:  fmt.Sprintf(`response.status == %d`, status),
+	}
+	catalog.AddAt(entry, summary, at)
+}
+
+func TestGetFields(t *testing.T) {
+	catalog := NewCatalog()
+	addHttpEntry(catalog, start, "front-end", "carts", "/items", 200)
+	addHttpEntry(catalog, start, "front-end", "orders", "/orders", 500)
+
+	kafkaEntry := &api.Entry{
+		Protocol: api.ProtocolSummary{Name: "kafka", Abbreviation: "KAFKA"},
+		Request:  map[string]interface{}{"payload": map[string]interface{}{"topics": []interface{}{map[string]interface{}{"name": "payments"}}}},
+	}
+	catalog.AddAt(kafkaEntry, &api.BaseEntry{Summary: "payments", SummaryQuery: `request.payload.topics[0].name == "payments"`}, start)
+
+	fields := catalog.GetFields(nil, DefaultLimit)
+
+	assert.Equal(t, []string{`request.cookies[0].name`, `request.headers["Content-Type"]`, "request.method", "request.path", "response.status"}, fields.Protocols["HTTP"])
+	assert.Equal(t, []string{"request.payload.topics[0].name"}, fields.Protocols["KAFKA"])
+
+	require.Len(t, fields.Values["src.name"], 1)
+	assert.Equal(t, &Value{Field: "src.name", Value: "front-end", Query: `src.name == "front-end"`, Count: 2, LastSeen: start.UnixMilli()}, fields.Values["src.name"][0])
+	assert.Len(t, fields.Values["dst.name"], 2)
+	assert.Len(t, fields.Values["request.path"], 2)
+	assert.Len(t, fields.Values["response.status"], 2)
+	require.Len(t, fields.Values["request.payload.topics[0].name"], 1)
+	assert.Equal(t, "payments", fields.Values["request.payload.topics[0].name"][0].Value)
+}
+
+func TestSuggest(t *testing.T) {
+	catalog := NewCatalog()
+	addHttpEntry(catalog, start, "front-end", "carts", "/items", 200)
+	addHttpEntry(catalog, start, "front-end", "catalogue", "/catalogue", 200)
+	addHttpEntry(catalog, start, "front-end", "catalogue", "/catalogue", 200)
+
+	macros := []*Macro{{Name: "http", Expanded: `protocol.abbr == "HTTP"`}, {Name: "kafka", Expanded: `protocol.name == "kafka"`}}
+
+	suggestions := catalog.Suggest(macros, "HT", DefaultLimit)
+	assert.Equal(t, macros[:1], suggestions.Macros)
+
+	suggestions = catalog.Suggest(macros, "dst.", DefaultLimit)
+	assert.Equal(t, []string{"dst.ip", "dst.name", "dst.port"}, suggestions.Fields)
+	require.Len(t, suggestions.Values, 2)
+	assert.Equal(t, "catalogue", suggestions.Values[0].Value)
+	assert.Equal(t, "carts", suggestions.Values[1].Value)
+
+	suggestions = catalog.Suggest(macros, `request.path == "/ca`, DefaultLimit)
+	require.Len(t, suggestions.Values, 1)
+	assert.Equal(t, `request.path == "/catalogue"`, suggestions.Values[0].Query)
+
+	suggestions = catalog.Suggest(macros, "ca", 1)
+	require.Len(t, suggestions.Values, 1)
+	assert.Equal(t, int64(2), suggestions.Values[0].Count)
+}
+
+func TestValuesEviction(t *testing.T) {
+	catalog := NewCatalog()
+	for i := 0; i <= valuesPerField; i++ {
+		addHttpEntry(catalog, start.Add(time.Duration(i)*time.Second), "front-end", fmt.Sprintf("service-%d", i), "/", 200)
+	}
+
+	values := catalog.GetFields(nil, MaxLimit).Values["dst.name"]
+	assert.Len(t, values, valuesPerField)
+	for _, value := range values {
+		assert.NotEqual(t, "service-0", value.Value)
+	}
+}
@@ -0,0 +1,84 @@
+package top
+
+import (
+	"container/heap"
+
+	"github.com/kubeshark/hub/pkg/providers"
+)
+
+// counter is a monitored key of a sketch, its count may be overestimated by at most maxError.
+type counter struct {
+	key       string
+	count     int64
+	maxError  int64
+	latencies providers.LatencyHistogram
+	index     int
+}
+
+type counterHeap []*counter
+
+func (h counterHeap) Len() int { return len(h) }
+
+func (h counterHeap) Less(i, j int) bool { return h[i].count < h[j].count }
+
+func (h counterHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *counterHeap) Push(x interface{}) {
+	c := x.(*counter)
+	c.index = len(*h)
+	*h = append(*h, c)
+}
+
+func (h *counterHeap) Pop() interface{} {
+	old := *h
+	c := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return c
+}
+
+// sketch finds the heavy hitters of a stream with the Space-Saving algorithm, it monitors a bounded number of keys
+// and a new key replaces the one with the smallest count, inheriting its count as the error bound.
+// Any key weighing more than 1/capacity of the stream is guaranteed to be monitored.
+type sketch struct {
+	capacity int
+	counters map[string]*counter
+	heap     counterHeap
+}
+
+func newSketch(capacity int) *sketch {
+	return &sketch{
+		capacity: capacity,
+		counters: make(map[string]*counter),
+	}
+}
+
+// add adds the weight to the count of the key and returns its counter.
+func (s *sketch) add(key string, weight int64) *counter {
+	if c, ok := s.counters[key]; ok {
+		c.count += weight
+		heap.Fix(&s.heap, c.index)
+		return c
+	}
+
+	if len(s.heap) < s.capacity {
+		c := &counter{key: key, count: weight}
+		heap.Push(&s.heap, c)
+		s.counters[key] = c
+		return c
+	}
+
+	c := s.heap[0]
+	delete(s.counters, c.key)
+	c.key = key
+	c.maxError = c.count
+	c.count += weight
+	c.latencies = nil
+	s.counters[key] = c
+	heap.Fix(&s.heap, 0)
+
+	return c
+}
@@ -0,0 +1,395 @@
+package top
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kubeshark/base/pkg/api"
+	"github.com/kubeshark/hub/pkg/providers"
+)
+
+const (
+	DefaultWindow = 15 * time.Minute
+	MaxWindow     = 60 * time.Minute
+	MaxN          = 100
+
+	sketchCapacity          = 256 // keys monitored per minute for each ranking
+	slowestPerMinute        = MaxN
+	minCallsForLatencyRanks = 10 // keys with fewer calls in the window have a meaningless p99
+)
+
+// Sample is what the tracker keeps of an ingested entry. It has no id as the ingested entries are not inserted yet,
+// the id of a slowest one is set once it is found in the database, see Tracker.SetEntryId.
+type Sample struct {
+	Id           string
+	Service      string
+	Endpoint     string
+	Failed       bool
+	LatencyMs    int64
+	RequestSize  int
+	ResponseSize int
+	Timestamp    int64
+	SourceIP     string
+	DestIP       string
+}
+
+type Item struct {
+	Name  string  `json:"name"`
+	Value float64 `json:"value"`
+	// MaxError is how much the value may be overestimated, non-zero only for the keys that entered a full sketch.
+	MaxError int64 `json:"maxError,omitempty"`
+}
+
+type Ranking struct {
+	Calls        []*Item `json:"calls"`
+	Errors       []*Item `json:"errors"`
+	LatencyP99   []*Item `json:"latencyP99"`
+	RequestSize  []*Item `json:"requestSize"`
+	ResponseSize []*Item `json:"responseSize"`
+}
+
+// SlowEntry is one of the slowest entries, its id is empty if the entry is not found in the database yet.
+type SlowEntry struct {
+	Id        string `json:"id"`
+	Service   string `json:"service"`
+	Endpoint  string `json:"endpoint"`
+	LatencyMs int64  `json:"latency"`
+	Timestamp int64  `json:"timestamp"`
+	// Query finds the entry in the database, see providers.GetEntryQuery.
+	Query string `json:"query"`
+}
+
+type Response struct {
+	StartTime int64        `json:"startTime"`
+	EndTime   int64        `json:"endTime"`
+	Services  *Ranking     `json:"services"`
+	Endpoints *Ranking     `json:"endpoints"`
+	Slowest   []*SlowEntry `json:"slowest"`
+}
+
+// sketchSet ranks the keys of a dimension, e.g. the services, by each of the metrics.
+type sketchSet struct {
+	calls        *sketch
+	errors       *sketch
+	requestSize  *sketch
+	responseSize *sketch
+}
+
+func newSketchSet() *sketchSet {
+	return &sketchSet{
+		calls:        newSketch(sketchCapacity),
+		errors:       newSketch(sketchCapacity),
+		requestSize:  newSketch(sketchCapacity),
+		responseSize: newSketch(sketchCapacity),
+	}
+}
+
+func (s *sketchSet) add(key string, sample *Sample) {
+	c := s.calls.add(key, 1)
+	if c.latencies == nil {
+		c.latencies = providers.LatencyHistogram{}
+	}
+	c.latencies.Add(sample.LatencyMs)
+
+	if sample.Failed {
+		s.errors.add(key, 1)
+	}
+	if sample.RequestSize > 0 {
+		s.requestSize.add(key, int64(sample.RequestSize))
+	}
+	if sample.ResponseSize > 0 {
+		s.responseSize.add(key, int64(sample.ResponseSize))
+	}
+}
+
+type minuteSlot struct {
+	minute    time.Time
+	services  *sketchSet
+	endpoints *sketchSet
+	slowest   []*Sample
+}
+
+func newMinuteSlot(minute time.Time) *minuteSlot {
+	return &minuteSlot{
+		minute:    minute,
+		services:  newSketchSet(),
+		endpoints: newSketchSet(),
+	}
+}
+
+// addSlowest keeps the slowest samples of the minute sorted by their latency, slowest first.
+func (s *minuteSlot) addSlowest(sample *Sample) {
+	if len(s.slowest) >= slowestPerMinute && sample.LatencyMs <= s.slowest[len(s.slowest)-1].LatencyMs {
+		return
+	}
+
+	i := sort.Search(len(s.slowest), func(i int) bool {
+		return s.slowest[i].LatencyMs < sample.LatencyMs
+	})
+	s.slowest = append(s.slowest, nil)
+	copy(s.slowest[i+1:], s.slowest[i:])
+	s.slowest[i] = sample
+
+	if len(s.slowest) > slowestPerMinute {
+		s.slowest = s.slowest[:slowestPerMinute]
+	}
+}
+
+// Tracker maintains the heavy hitters of the last MaxWindow in a ring of minute slots,
+// so a query only merges the bounded sketches of the minutes in its window.
+type Tracker struct {
+	lock  sync.Mutex
+	slots []*minuteSlot
+}
+
+var instance *Tracker
+var once sync.Once
+
+func GetDefaultTrackerInstance() *Tracker {
+	once.Do(func() {
+		instance = NewTracker()
+	})
+
+	return instance
+}
+
+func NewTracker() *Tracker {
+	return &Tracker{
+		slots: make([]*minuteSlot, MaxWindow/time.Minute),
+	}
+}
+
+// NewSample extracts the sample of an entry, the endpoint of an entry is its destination service,
+// method and summary without the query string, e.g. "carts GET /carts/items".
+func NewSample(entry *api.Entry, summary *api.BaseEntry) *Sample {
+	service := providers.GetPeerName(entry.Destination)
+	path := strings.SplitN(summary.Summary, "?", 2)[0]
+	endpoint := strings.Join(strings.Fields(strings.Join([]string{service, summary.Method, path}, " ")), " ")
+	_, failed := providers.GetStatusClass(entry, summary)
+
+	return &Sample{
+		Service:      service,
+		Endpoint:     endpoint,
+		Failed:       failed,
+		LatencyMs:    entry.ElapsedTime,
+		RequestSize:  entry.RequestSize,
+		ResponseSize: entry.ResponseSize,
+		Timestamp:    entry.Timestamp,
+		SourceIP:     providers.GetPeerIP(entry.Source),
+		DestIP:       providers.GetPeerIP(entry.Destination),
+	}
+}
+
+func (t *Tracker) slotIndex(minute time.Time) int64 {
+	n := int64(len(t.slots))
+	return ((minute.Unix()/60)%n + n) % n
+}
+
+// Add counts the sample in the slot of its minute, the samples older than the slot are dropped.
+func (t *Tracker) Add(sample *Sample) {
+	minute := time.UnixMilli(sample.Timestamp).Truncate(time.Minute)
+	i := t.slotIndex(minute)
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	slot := t.slots[i]
+	if slot == nil || slot.minute.Before(minute) {
+		slot = newMinuteSlot(minute)
+		t.slots[i] = slot
+	} else if slot.minute.After(minute) {
+		return
+	}
+
+	slot.services.add(sample.Service, sample)
+	slot.endpoints.add(sample.Endpoint, sample)
+	slot.addSlowest(sample)
+}
+
+// SetEntryId records the id of a stored entry on its samples among the slowest, so the responses refer to it.
+// It tells whether the entry is one of the slowest.
+func (t *Tracker) SetEntryId(entry *api.Entry) bool {
+	minute := time.UnixMilli(entry.Timestamp).Truncate(time.Minute)
+	sourceIP := providers.GetPeerIP(entry.Source)
+	destIP := providers.GetPeerIP(entry.Destination)
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	slot := t.slots[t.slotIndex(minute)]
+	if slot == nil || !slot.minute.Equal(minute) {
+		return false
+	}
+
+	found := false
+	for _, sample := range slot.slowest {
+		if sample.Id == "" && sample.Timestamp == entry.Timestamp && sample.LatencyMs == entry.ElapsedTime &&
+			sample.SourceIP == sourceIP && sample.DestIP == destIP {
+			sample.Id = entry.Id
+			found = true
+		}
+	}
+
+	return found
+}
+
+// GetMissingIdsQuery returns the query of the slowest entries whose ids are not found yet, along with their count.
+func GetMissingIdsQuery(slowest []*SlowEntry) (string, int) {
+	conditions := make([]string, 0)
+	for _, entry := range slowest {
+		if entry.Id == "" {
+			conditions = append(conditions, "("+entry.Query+")")
+		}
+	}
+
+	return strings.Join(conditions, " or "), len(conditions)
+}
+
+type mergedCounter struct {
+	count     int64
+	maxError  int64
+	latencies providers.LatencyHistogram
+}
+
+func mergeSketches(sketches []*sketch) map[string]*mergedCounter {
+	merged := make(map[string]*mergedCounter)
+	for _, s := range sketches {
+		for key, c := range s.counters {
+			m, ok := merged[key]
+			if !ok {
+				m = &mergedCounter{}
+				merged[key] = m
+			}
+			m.count += c.count
+			m.maxError += c.maxError
+			if c.latencies != nil {
+				if m.latencies == nil {
+					m.latencies = providers.LatencyHistogram{}
+				}
+				m.latencies.Merge(c.latencies)
+			}
+		}
+	}
+
+	return merged
+}
+
+func topItems(items []*Item, n int) []*Item {
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Value != items[j].Value {
+			return items[i].Value > items[j].Value
+		}
+		return items[i].Name < items[j].Name
+	})
+
+	if len(items) > n {
+		items = items[:n]
+	}
+
+	return items
+}
+
+func topCounts(merged map[string]*mergedCounter, n int) []*Item {
+	items := make([]*Item, 0, len(merged))
+	for key, m := range merged {
+		items = append(items, &Item{Name: key, Value: float64(m.count), MaxError: m.maxError})
+	}
+
+	return topItems(items, n)
+}
+
+func topLatencies(merged map[string]*mergedCounter, n int) []*Item {
+	items := make([]*Item, 0, len(merged))
+	for key, m := range merged {
+		if m.count < minCallsForLatencyRanks || m.latencies == nil {
+			continue
+		}
+		items = append(items, &Item{Name: key, Value: m.latencies.Percentile(99)})
+	}
+
+	return topItems(items, n)
+}
+
+func getRanking(sets []*sketchSet, n int) *Ranking {
+	calls := make([]*sketch, 0, len(sets))
+	errors := make([]*sketch, 0, len(sets))
+	requestSize := make([]*sketch, 0, len(sets))
+	responseSize := make([]*sketch, 0, len(sets))
+	for _, set := range sets {
+		calls = append(calls, set.calls)
+		errors = append(errors, set.errors)
+		requestSize = append(requestSize, set.requestSize)
+		responseSize = append(responseSize, set.responseSize)
+	}
+
+	mergedCalls := mergeSketches(calls)
+
+	return &Ranking{
+		Calls:        topCounts(mergedCalls, n),
+		Errors:       topCounts(mergeSketches(errors), n),
+		LatencyP99:   topLatencies(mergedCalls, n),
+		RequestSize:  topCounts(mergeSketches(requestSize), n),
+		ResponseSize: topCounts(mergeSketches(responseSize), n),
+	}
+}
+
+// Get returns the top n services, endpoints and slowest entries of the window that ends at the given time.
+func (t *Tracker) Get(now time.Time, window time.Duration, n int) (*Response, error) {
+	if window < time.Minute || window > MaxWindow {
+		return nil, fmt.Errorf("invalid window: %v, must be between 1m and %v", window, MaxWindow)
+	}
+	if n < 1 || n > MaxN {
+		return nil, fmt.Errorf("invalid n: %d, must be between 1 and %d", n, MaxN)
+	}
+
+	end := now.Truncate(time.Minute)
+	start := end.Add(-window + time.Minute)
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	services := make([]*sketchSet, 0)
+	endpoints := make([]*sketchSet, 0)
+	slowest := make([]*Sample, 0)
+	for _, slot := range t.slots {
+		if slot == nil || slot.minute.Before(start) || slot.minute.After(end) {
+			continue
+		}
+		services = append(services, slot.services)
+		endpoints = append(endpoints, slot.endpoints)
+		slowest = append(slowest, slot.slowest...)
+	}
+
+	sort.SliceStable(slowest, func(i, j int) bool {
+		if slowest[i].LatencyMs != slowest[j].LatencyMs {
+			return slowest[i].LatencyMs > slowest[j].LatencyMs
+		}
+		return slowest[i].Timestamp < slowest[j].Timestamp
+	})
+	if len(slowest) > n {
+		slowest = slowest[:n]
+	}
+
+	response := &Response{
+		StartTime: start.UnixMilli(),
+		EndTime:   end.Add(time.Minute).UnixMilli(),
+		Services:  getRanking(services, n),
+		Endpoints: getRanking(endpoints, n),
+		Slowest:   make([]*SlowEntry, 0, len(slowest)),
+	}
+	for _, sample := range slowest {
+		response.Slowest = append(response.Slowest, &SlowEntry{
+			Id:        sample.Id,
+			Service:   sample.Service,
+			Endpoint:  sample.Endpoint,
+			LatencyMs: sample.LatencyMs,
+			Timestamp: sample.Timestamp,
+			Query:     providers.GetEntryQuery(sample.Timestamp, sample.LatencyMs, sample.SourceIP, sample.DestIP),
+		})
+	}
+
+	return response, nil
+}
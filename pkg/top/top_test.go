@@ -0,0 +1,164 @@
+package top
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/kubeshark/base/pkg/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var start = time.Date(2022, time.Month(1), 1, 10, 0, 0, 0, time.UTC)
+
+func TestSketchKeepsHeavyHitters(t *testing.T) {
+	s := newSketch(10)
+	for i := 0; i < 1000; i++ {
+		s.add("heavy", 1)
+		s.add(fmt.Sprintf("light-%d", i), 1)
+	}
+
+	require.Contains(t, s.counters, "heavy")
+	heavy := s.counters["heavy"]
+	assert.Equal(t, int64(1000), heavy.count-heavy.maxError)
+	assert.Len(t, s.counters, 10)
+}
+
+func TestNewSample(t *testing.T) {
+	entry := &api.Entry{
+		Id:           "1",
+		Source:       &api.TCP{IP: "10.0.0.1", Name: "front-end"},
+		Destination:  &api.TCP{IP: "10.0.0.2", Name: "carts"},
+		Timestamp:    start.UnixMilli(),
+		ElapsedTime:  42,
+		RequestSize:  10,
+		ResponseSize: 20,
+	}
+	summary := &api.BaseEntry{
+		Protocol: api.Protocol{ProtocolSummary: api.ProtocolSummary{Name: "http"}},
+		Method:   "GET",
+		Summary:  "/carts/items?id=1",
+		Status:   500,
+	}
+
+	assert.Equal(t, &Sample{
+		Service:      "carts",
+		Endpoint:     "carts GET /carts/items",
+		Failed:       true,
+		LatencyMs:    42,
+		RequestSize:  10,
+		ResponseSize: 20,
+		Timestamp:    start.UnixMilli(),
+		SourceIP:     "10.0.0.1",
+		DestIP:       "10.0.0.2",
+	}, NewSample(entry, summary))
+}
+
+func TestTrackerGet(t *testing.T) {
+	tracker := NewTracker()
+	for i := 0; i < 30; i++ {
+		timestamp := start.Add(time.Duration(i) * time.Minute).UnixMilli()
+		tracker.Add(&Sample{Service: "carts", Endpoint: "carts GET /carts", LatencyMs: 10, RequestSize: 100, Timestamp: timestamp})
+		tracker.Add(&Sample{Service: "carts", Endpoint: "carts POST /carts", LatencyMs: 20, Failed: true, Timestamp: timestamp})
+		tracker.Add(&Sample{Service: "orders", Endpoint: "orders GET /orders", LatencyMs: int64(100 + i), ResponseSize: 1000, Timestamp: timestamp})
+	}
+	now := start.Add(29*time.Minute + 30*time.Second)
+
+	tests := map[string]struct {
+		window        time.Duration
+		expectedCalls []*Item
+		expectedP99   string
+		expectedSlow  int64
+	}{
+		"whole window": {
+			window:        30 * time.Minute,
+			expectedCalls: []*Item{{Name: "carts", Value: 60}, {Name: "orders", Value: 30}},
+			expectedP99:   "orders",
+			expectedSlow:  129,
+		},
+		"last minutes": {
+			window:        5 * time.Minute,
+			expectedCalls: []*Item{{Name: "carts", Value: 10}, {Name: "orders", Value: 5}},
+			// orders has too few calls to rank by p99
+			expectedP99:  "carts",
+			expectedSlow: 129,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			response, err := tracker.Get(now, test.window, 2)
+			require.NoError(t, err)
+
+			assert.Equal(t, test.expectedCalls, response.Services.Calls)
+			assert.Equal(t, []*Item{{Name: "carts", Value: float64(test.expectedCalls[0].Value / 2)}}, response.Services.Errors)
+			assert.Equal(t, "orders", response.Services.ResponseSize[0].Name)
+			assert.Equal(t, "carts GET /carts", response.Endpoints.RequestSize[0].Name)
+			assert.Len(t, response.Endpoints.Calls, 2)
+
+			require.NotEmpty(t, response.Services.LatencyP99)
+			assert.Equal(t, test.expectedP99, response.Services.LatencyP99[0].Name)
+
+			require.Len(t, response.Slowest, 2)
+			assert.Equal(t, test.expectedSlow, response.Slowest[0].LatencyMs)
+			assert.Equal(t, "orders GET /orders", response.Slowest[0].Endpoint)
+			assert.NotEmpty(t, response.Slowest[0].Query)
+		})
+	}
+}
+
+func TestTrackerSetEntryId(t *testing.T) {
+	tracker := NewTracker()
+	timestamp := start.UnixMilli()
+	tracker.Add(&Sample{Service: "carts", LatencyMs: 10, Timestamp: timestamp, SourceIP: "10.0.0.1", DestIP: "10.0.0.2"})
+	tracker.Add(&Sample{Service: "orders", LatencyMs: 20, Timestamp: timestamp, SourceIP: "10.0.0.1", DestIP: "10.0.0.3"})
+
+	response, err := tracker.Get(start, time.Minute, 10)
+	require.NoError(t, err)
+	query, missing := GetMissingIdsQuery(response.Slowest)
+	assert.Equal(t, 2, missing)
+	assert.Equal(t, "("+response.Slowest[0].Query+") or ("+response.Slowest[1].Query+")", query)
+
+	entry := &api.Entry{
+		Id:          "7",
+		Source:      &api.TCP{IP: "10.0.0.1"},
+		Destination: &api.TCP{IP: "10.0.0.3"},
+		Timestamp:   timestamp,
+		ElapsedTime: 20,
+	}
+	assert.True(t, tracker.SetEntryId(entry))
+	assert.False(t, tracker.SetEntryId(&api.Entry{Id: "8", Timestamp: timestamp, ElapsedTime: 30}))
+
+	response, err = tracker.Get(start, time.Minute, 10)
+	require.NoError(t, err)
+	assert.Equal(t, "7", response.Slowest[0].Id)
+	assert.Empty(t, response.Slowest[1].Id)
+
+	query, missing = GetMissingIdsQuery(response.Slowest)
+	assert.Equal(t, 1, missing)
+	assert.Equal(t, "("+response.Slowest[1].Query+")", query)
+}
+
+func TestTrackerDropsStaleSamples(t *testing.T) {
+	tracker := NewTracker()
+	tracker.Add(&Sample{Service: "carts", Timestamp: start.Add(MaxWindow).UnixMilli()})
+	tracker.Add(&Sample{Service: "orders", Timestamp: start.UnixMilli()})
+
+	response, err := tracker.Get(start.Add(MaxWindow), MaxWindow, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []*Item{{Name: "carts", Value: 1}}, response.Services.Calls)
+}
+
+func TestTrackerGetValidation(t *testing.T) {
+	tracker := NewTracker()
+
+	_, err := tracker.Get(start, 2*MaxWindow, 10)
+	assert.Error(t, err)
+	_, err = tracker.Get(start, time.Second, 10)
+	assert.Error(t, err)
+	_, err = tracker.Get(start, DefaultWindow, 0)
+	assert.Error(t, err)
+	_, err = tracker.Get(start, DefaultWindow, MaxN+1)
+	assert.Error(t, err)
+}
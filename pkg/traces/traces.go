@@ -0,0 +1,540 @@
+package traces
+
+import (
+	"container/list"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/kubeshark/base/pkg/api"
+	"github.com/kubeshark/hub/pkg/providers"
+)
+
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+
+	maxTraces        = 10000 // the least recently updated trace is evicted beyond it
+	maxSpansPerTrace = 1000
+	maxSpans         = 200000 // of all the traces, the least recently updated traces are evicted beyond it
+)
+
+// The propagation formats of the trace context, in the order of their precedence when an entry has several.
+const (
+	PropagationW3C       = "w3c"
+	PropagationB3        = "b3"
+	PropagationJaeger    = "jaeger"
+	PropagationRequestId = "x-request-id"
+)
+
+// Context is the trace context propagated by the headers of a request.
+type Context struct {
+	Propagation  string
+	TraceId      string
+	SpanId       string
+	ParentSpanId string
+}
+
+// Span is what the index keeps of an entry of a trace.
+type Span struct {
+	// Id is the id of the entry, known only once the entry was found in the database, by its trace or a listing with a query.
+	Id           string `json:"id,omitempty"`
+	SpanId       string `json:"spanId,omitempty"`
+	ParentSpanId string `json:"parentSpanId,omitempty"`
+	Propagation  string `json:"propagation"`
+	Protocol     string `json:"protocol"`
+	Source       string `json:"src"`
+	Destination  string `json:"dst"`
+	Method       string `json:"method,omitempty"`
+	Summary      string `json:"summary,omitempty"`
+	Status       int    `json:"status"`
+	Failed       bool   `json:"failed"`
+	Timestamp    int64  `json:"timestamp"`
+	ElapsedTime  int64  `json:"elapsedTime"`
+	// Query finds the entry in the database, see providers.GetEntryQuery.
+	Query string `json:"query"`
+
+	sourceIP string
+	destIP   string
+	seq      int64
+}
+
+func (s *Span) end() int64 {
+	return s.Timestamp + s.ElapsedTime
+}
+
+// Node is a span of a trace tree, the gap is the time between the end of its previous sibling,
+// or the start of its parent for the first child, and its start. The offset is from the start of the trace.
+type Node struct {
+	*Span
+	OffsetMs int64   `json:"offset"`
+	GapMs    int64   `json:"gap"`
+	Children []*Node `json:"children"`
+}
+
+type Trace struct {
+	TraceId   string  `json:"traceId"`
+	StartTime int64   `json:"startTime"`
+	Duration  int64   `json:"duration"`
+	SpanCount int     `json:"spanCount"`
+	Failed    bool    `json:"failed"`
+	Truncated bool    `json:"truncated"`
+	Roots     []*Node `json:"roots"`
+}
+
+// Summary describes a trace by the endpoint of its earliest root span.
+type Summary struct {
+	TraceId      string   `json:"traceId"`
+	Propagation  string   `json:"propagation"`
+	RootService  string   `json:"rootService"`
+	RootEndpoint string   `json:"rootEndpoint"`
+	StartTime    int64    `json:"startTime"`
+	Duration     int64    `json:"duration"`
+	SpanCount    int      `json:"spanCount"`
+	Failed       bool     `json:"failed"`
+	Services     []string `json:"services"`
+}
+
+type trace struct {
+	id        string
+	spans     []*Span
+	truncated bool
+	element   *list.Element
+}
+
+// Index keeps the spans of the recently seen traces, by their trace ids.
+type Index struct {
+	lock   sync.Mutex
+	traces map[string]*trace
+	recent *list.List // of *trace, the most recently updated first
+	spans  int
+	seq    int64
+}
+
+var instance *Index
+var once sync.Once
+
+func GetDefaultIndexInstance() *Index {
+	once.Do(func() {
+		instance = NewIndex()
+	})
+
+	return instance
+}
+
+func NewIndex() *Index {
+	return &Index{
+		traces: make(map[string]*trace),
+		recent: list.New(),
+	}
+}
+
+// GetContext extracts the trace context from the request headers of an entry,
+// it returns nil for the entries without request headers or propagation headers.
+func GetContext(entry *api.Entry) *Context {
+	headers, ok := entry.Request["headers"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	lowerCased := make(map[string]string, len(headers))
+	for name, value := range headers {
+		if value, ok := value.(string); ok {
+			lowerCased[strings.ToLower(name)] = strings.TrimSpace(value)
+		}
+	}
+
+	if context := parseTraceparent(lowerCased["traceparent"]); context != nil {
+		return context
+	}
+	if traceId := normalizeId(lowerCased["x-b3-traceid"]); traceId != "" {
+		return &Context{
+			Propagation:  PropagationB3,
+			TraceId:      traceId,
+			SpanId:       normalizeId(lowerCased["x-b3-spanid"]),
+			ParentSpanId: normalizeId(lowerCased["x-b3-parentspanid"]),
+		}
+	}
+	if context := parseUberTraceId(lowerCased["uber-trace-id"]); context != nil {
+		return context
+	}
+	if requestId := lowerCased["x-request-id"]; requestId != "" {
+		return &Context{Propagation: PropagationRequestId, TraceId: requestId}
+	}
+
+	return nil
+}
+
+// parseTraceparent parses a W3C traceparent, e.g. 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01,
+// its parent id is the id of the span that sent the request.
+func parseTraceparent(value string) *Context {
+	parts := strings.Split(value, "-")
+	if len(parts) < 4 || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return nil
+	}
+
+	traceId := normalizeId(parts[1])
+	if traceId == "" {
+		return nil
+	}
+
+	return &Context{
+		Propagation: PropagationW3C,
+		TraceId:     traceId,
+		SpanId:      normalizeId(parts[2]),
+	}
+}
+
+// parseUberTraceId parses a Jaeger uber-trace-id, {trace-id}:{span-id}:{parent-span-id}:{flags}, possibly URL encoded.
+func parseUberTraceId(value string) *Context {
+	if unescaped, err := url.QueryUnescape(value); err == nil {
+		value = unescaped
+	}
+
+	parts := strings.Split(value, ":")
+	if len(parts) != 4 {
+		return nil
+	}
+
+	traceId := normalizeId(parts[0])
+	if traceId == "" {
+		return nil
+	}
+
+	return &Context{
+		Propagation:  PropagationJaeger,
+		TraceId:      traceId,
+		SpanId:       normalizeId(parts[1]),
+		ParentSpanId: normalizeId(parts[2]),
+	}
+}
+
+// normalizeId lower cases a hex id and pads it to 32 or 16 digits, as the 64 bit and 128 bit ids of a trace
+// may be sent with or without their leading zeros. It returns "" for the invalid ids and the all zero ones.
+func normalizeId(value string) string {
+	value = strings.ToLower(value)
+	if value == "" || len(value) > 32 || strings.Trim(value, "0") == "" {
+		return ""
+	}
+	for _, c := range value {
+		if !strings.ContainsRune("0123456789abcdef", c) {
+			return ""
+		}
+	}
+
+	if len(value) > 16 {
+		return fmt.Sprintf("%032s", value)
+	}
+	return fmt.Sprintf("%016s", value)
+}
+
+// NewSpan makes the span of an entry, it returns nil for the entries without a trace context.
+func NewSpan(entry *api.Entry, summary *api.BaseEntry) (string, *Span) {
+	context := GetContext(entry)
+	if context == nil {
+		return "", nil
+	}
+
+	_, failed := providers.GetStatusClass(entry, summary)
+	span := &Span{
+		Id:           entry.Id,
+		SpanId:       context.SpanId,
+		ParentSpanId: context.ParentSpanId,
+		Propagation:  context.Propagation,
+		Protocol:     summary.Protocol.Abbreviation,
+		Source:       providers.GetPeerName(entry.Source),
+		Destination:  providers.GetPeerName(entry.Destination),
+		Method:       summary.Method,
+		Summary:      summary.Summary,
+		Status:       summary.Status,
+		Failed:       failed,
+		Timestamp:    entry.Timestamp,
+		ElapsedTime:  entry.ElapsedTime,
+		sourceIP:     providers.GetPeerIP(entry.Source),
+		destIP:       providers.GetPeerIP(entry.Destination),
+	}
+	span.Query = providers.GetEntryQuery(span.Timestamp, span.ElapsedTime, span.sourceIP, span.destIP)
+
+	return context.TraceId, span
+}
+
+// Add indexes the entry by the trace id of its propagation headers, if any.
+func (i *Index) Add(entry *api.Entry, summary *api.BaseEntry) {
+	traceId, span := NewSpan(entry, summary)
+	if span == nil {
+		return
+	}
+
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	t, ok := i.traces[traceId]
+	if !ok {
+		t = &trace{id: traceId}
+		t.element = i.recent.PushFront(t)
+		i.traces[traceId] = t
+	} else {
+		i.recent.MoveToFront(t.element)
+	}
+
+	if len(t.spans) >= maxSpansPerTrace {
+		t.truncated = true
+	} else {
+		i.seq++
+		span.seq = i.seq
+		t.spans = append(t.spans, span)
+		i.spans++
+	}
+
+	for len(i.traces) > maxTraces || i.spans > maxSpans {
+		oldest := i.recent.Remove(i.recent.Back()).(*trace)
+		delete(i.traces, oldest.id)
+		i.spans -= len(oldest.spans)
+	}
+}
+
+// GetMissingIdsQuery returns the query of the entries of the spans of a trace whose ids are unknown, along with
+// their count, so they can be found in the database at once. The count is 0 if the trace is not indexed.
+func (i *Index) GetMissingIdsQuery(traceId string) (string, int) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	t, ok := i.traces[traceId]
+	if !ok {
+		return "", 0
+	}
+
+	missing := 0
+	var startTime, endTime int64
+	sourceIPs := make(map[string]bool)
+	for _, span := range t.spans {
+		if span.Id != "" {
+			continue
+		}
+		if missing == 0 || span.Timestamp < startTime {
+			startTime = span.Timestamp
+		}
+		if missing == 0 || span.Timestamp > endTime {
+			endTime = span.Timestamp
+		}
+		sourceIPs[span.sourceIP] = true
+		missing++
+	}
+	if missing == 0 {
+		return "", 0
+	}
+
+	conditions := make([]string, 0, len(sourceIPs))
+	for sourceIP := range sourceIPs {
+		conditions = append(conditions, fmt.Sprintf(`src.ip == "%s"`, sourceIP))
+	}
+	sort.Strings(conditions)
+
+	return fmt.Sprintf("timestamp >= %d and timestamp <= %d and (%s)", startTime, endTime, strings.Join(conditions, " or ")), missing
+}
+
+// SetEntryId records the id of a stored entry on its span, so the trees refer to the entries by their ids.
+// It returns the trace id of the entry, or "" if the entry has no trace context or its trace is not indexed.
+func (i *Index) SetEntryId(entry *api.Entry, summary *api.BaseEntry) string {
+	traceId, span := NewSpan(entry, summary)
+	if span == nil {
+		return ""
+	}
+
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	t, ok := i.traces[traceId]
+	if !ok {
+		return ""
+	}
+	for _, indexed := range t.spans {
+		if indexed.Id == "" && indexed.Timestamp == span.Timestamp && indexed.ElapsedTime == span.ElapsedTime &&
+			indexed.sourceIP == span.sourceIP && indexed.destIP == span.destIP {
+			indexed.Id = span.Id
+			break
+		}
+	}
+
+	return traceId
+}
+
+// Get builds the tree of the spans of a trace, it returns nil if the trace is not indexed.
+func (i *Index) Get(traceId string) *Trace {
+	i.lock.Lock()
+	t, ok := i.traces[traceId]
+	if !ok {
+		i.lock.Unlock()
+		return nil
+	}
+	spans := copySpans(t.spans)
+	truncated := t.truncated
+	i.lock.Unlock()
+
+	result := buildTree(spans)
+	result.TraceId = traceId
+	result.Truncated = truncated
+	return result
+}
+
+// GetRecent summarizes the most recently updated traces, up to the limit.
+func (i *Index) GetRecent(limit int) []*Summary {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	summaries := make([]*Summary, 0)
+	for element := i.recent.Front(); element != nil && len(summaries) < limit; element = element.Next() {
+		t := element.Value.(*trace)
+		summaries = append(summaries, summarize(t.id, copySpans(t.spans)))
+	}
+
+	return summaries
+}
+
+// GetSummaries summarizes the indexed traces among the trace ids, in their order.
+func (i *Index) GetSummaries(traceIds []string) []*Summary {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	summaries := make([]*Summary, 0)
+	for _, traceId := range traceIds {
+		if t, ok := i.traces[traceId]; ok {
+			summaries = append(summaries, summarize(t.id, copySpans(t.spans)))
+		}
+	}
+
+	return summaries
+}
+
+// copySpans copies the spans so the tree and the summary are built out of the lock.
+func copySpans(spans []*Span) []*Span {
+	copied := make([]*Span, len(spans))
+	for j, span := range spans {
+		spanCopy := *span
+		copied[j] = &spanCopy
+	}
+	return copied
+}
+
+func sortSpans(spans []*Span) {
+	sort.Slice(spans, func(a, b int) bool {
+		if spans[a].Timestamp != spans[b].Timestamp {
+			return spans[a].Timestamp < spans[b].Timestamp
+		}
+		return spans[a].seq < spans[b].seq
+	})
+}
+
+// findParents returns the index of the parent of each span of the sorted spans, or -1 for the roots.
+// A span is the child of the span whose id is its parent span id, as with B3 and Jaeger. Otherwise,
+// as with W3C whose server spans are not propagated, it is the child of the latest span to its source
+// that started before it and ended after it. A parent always precedes its children, so there are no cycles.
+func findParents(spans []*Span) []int {
+	parents := make([]int, len(spans))
+	bySpanId := make(map[string]int)
+	for j, span := range spans {
+		parents[j] = -1
+
+		if parent, ok := bySpanId[span.ParentSpanId]; ok && span.ParentSpanId != "" {
+			parents[j] = parent
+		} else {
+			for k := j - 1; k >= 0; k-- {
+				candidate := spans[k]
+				if candidate.destIP == span.sourceIP && candidate.end() >= span.end() {
+					parents[j] = k
+					break
+				}
+			}
+		}
+
+		// the client and the server of a call share the span id of B3, the earliest one is kept as the parent
+		if _, ok := bySpanId[span.SpanId]; !ok && span.SpanId != "" {
+			bySpanId[span.SpanId] = j
+		}
+	}
+
+	return parents
+}
+
+func buildTree(spans []*Span) *Trace {
+	sortSpans(spans)
+	result := &Trace{
+		SpanCount: len(spans),
+		Roots:     make([]*Node, 0),
+	}
+	if len(spans) == 0 {
+		return result
+	}
+
+	result.StartTime = spans[0].Timestamp
+	end := result.StartTime
+
+	nodes := make([]*Node, len(spans))
+	for j, span := range spans {
+		nodes[j] = &Node{
+			Span:     span,
+			OffsetMs: span.Timestamp - result.StartTime,
+			Children: make([]*Node, 0),
+		}
+		if span.end() > end {
+			end = span.end()
+		}
+		if span.Failed {
+			result.Failed = true
+		}
+	}
+	result.Duration = end - result.StartTime
+
+	for j, parent := range findParents(spans) {
+		node := nodes[j]
+		siblings := &result.Roots
+		previousEnd := result.StartTime
+		if parent >= 0 {
+			siblings = &nodes[parent].Children
+			previousEnd = nodes[parent].Timestamp
+		}
+		if len(*siblings) > 0 {
+			previousEnd = (*siblings)[len(*siblings)-1].end()
+		}
+
+		node.GapMs = node.Timestamp - previousEnd
+		*siblings = append(*siblings, node)
+	}
+
+	return result
+}
+
+func summarize(traceId string, spans []*Span) *Summary {
+	tree := buildTree(spans)
+	summary := &Summary{
+		TraceId:   traceId,
+		StartTime: tree.StartTime,
+		Duration:  tree.Duration,
+		SpanCount: tree.SpanCount,
+		Failed:    tree.Failed,
+		Services:  make([]string, 0),
+	}
+
+	services := make(map[string]bool)
+	for _, span := range spans {
+		for _, service := range []string{span.Source, span.Destination} {
+			if service != "" && !services[service] {
+				services[service] = true
+				summary.Services = append(summary.Services, service)
+			}
+		}
+	}
+	sort.Strings(summary.Services)
+
+	if len(tree.Roots) > 0 {
+		root := tree.Roots[0]
+		summary.Propagation = root.Propagation
+		summary.RootService = root.Destination
+		path := strings.SplitN(root.Summary, "?", 2)[0]
+		summary.RootEndpoint = strings.Join(strings.Fields(strings.Join([]string{root.Method, path}, " ")), " ")
+	}
+
+	return summary
+}
@@ -0,0 +1,240 @@
+package traces
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/kubeshark/base/pkg/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var start = time.Date(2022, time.Month(1), 1, 10, 0, 0, 0, time.UTC).UnixMilli()
+
+var peers = map[string]string{
+	"front-end": "10.0.0.1",
+	"orders":    "10.0.0.2",
+	"carts":     "10.0.0.3",
+	"payment":   "10.0.0.4",
+}
+
+func newEntry(headers map[string]interface{}, source string, destination string, timestamp int64, elapsedTime int64) *api.Entry {
+	return &api.Entry{
+		Source:      &api.TCP{IP: peers[source], Name: source},
+		Destination: &api.TCP{IP: peers[destination], Name: destination},
+		Timestamp:   timestamp,
+		ElapsedTime: elapsedTime,
+		Request:     map[string]interface{}{"headers": headers},
+	}
+}
+
+func newSummary(method string, path string, status int) *api.BaseEntry {
+	return &api.BaseEntry{
+		Protocol: api.Protocol{ProtocolSummary: api.ProtocolSummary{Name: "http", Abbreviation: "HTTP"}},
+		Method:   method,
+		Summary:  path,
+		Status:   status,
+	}
+}
+
+func TestGetContext(t *testing.T) {
+	tests := map[string]struct {
+		headers  map[string]interface{}
+		expected *Context
+	}{
+		"w3c": {
+			headers:  map[string]interface{}{"Traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+			expected: &Context{Propagation: PropagationW3C, TraceId: "4bf92f3577b34da6a3ce929d0e0e4736", SpanId: "00f067aa0ba902b7"},
+		},
+		"w3c with an all zero trace id": {
+			headers: map[string]interface{}{"traceparent": "00-00000000000000000000000000000000-00f067aa0ba902b7-01"},
+		},
+		"b3 takes precedence over x-request-id": {
+			headers: map[string]interface{}{
+				"X-B3-TraceId":      "463AC35C9F6413AD",
+				"X-B3-SpanId":       "a2fb4a1d1a96d312",
+				"X-B3-ParentSpanId": "0020000000000001",
+				"X-Request-Id":      "abc",
+			},
+			expected: &Context{Propagation: PropagationB3, TraceId: "463ac35c9f6413ad", SpanId: "a2fb4a1d1a96d312", ParentSpanId: "0020000000000001"},
+		},
+		"jaeger url encoded without leading zeros": {
+			headers:  map[string]interface{}{"uber-trace-id": "5f2c1b%3A7a%3A0%3A1"},
+			expected: &Context{Propagation: PropagationJaeger, TraceId: "00000000005f2c1b", SpanId: "000000000000007a"},
+		},
+		"x-request-id": {
+			headers:  map[string]interface{}{"x-request-id": " 6a1b2c "},
+			expected: &Context{Propagation: PropagationRequestId, TraceId: "6a1b2c"},
+		},
+		"no propagation headers": {
+			headers: map[string]interface{}{"Accept": "*/*"},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.expected, GetContext(newEntry(test.headers, "front-end", "orders", start, 1)))
+		})
+	}
+}
+
+func TestGetBuildsTreeFromParentSpanIds(t *testing.T) {
+	index := NewIndex()
+	b3 := func(spanId string, parentSpanId string) map[string]interface{} {
+		return map[string]interface{}{"x-b3-traceid": "1", "x-b3-spanid": spanId, "x-b3-parentspanid": parentSpanId}
+	}
+
+	// added out of order, as the entries of several workers are
+	index.Add(newEntry(b3("3", "2"), "orders", "payment", start+30, 40), newSummary("POST", "/paymentAuth", 500))
+	index.Add(newEntry(b3("1", ""), "front-end", "orders", start, 100), newSummary("GET", "/orders?id=1", 200))
+	index.Add(newEntry(b3("2", "1"), "orders", "carts", start+10, 15), newSummary("GET", "/carts/1", 200))
+
+	trace := index.Get("0000000000000001")
+	require.NotNil(t, trace)
+	assert.Equal(t, start, trace.StartTime)
+	assert.Equal(t, int64(100), trace.Duration)
+	assert.Equal(t, 3, trace.SpanCount)
+	assert.True(t, trace.Failed)
+
+	require.Len(t, trace.Roots, 1)
+	root := trace.Roots[0]
+	assert.Equal(t, "orders", root.Destination)
+	require.Len(t, root.Children, 1)
+
+	carts := root.Children[0]
+	assert.Equal(t, "carts", carts.Destination)
+	assert.Equal(t, int64(10), carts.OffsetMs)
+	assert.Equal(t, int64(10), carts.GapMs)
+	require.Len(t, carts.Children, 1)
+
+	payment := carts.Children[0]
+	assert.Equal(t, "payment", payment.Destination)
+	assert.Equal(t, int64(20), payment.GapMs)
+
+	assert.Nil(t, index.Get("2"))
+}
+
+func TestGetInfersParentsOfW3CSpans(t *testing.T) {
+	index := NewIndex()
+	traceparent := func(spanId string) map[string]interface{} {
+		return map[string]interface{}{"traceparent": fmt.Sprintf("00-%032d-%016s-01", 7, spanId)}
+	}
+
+	index.Add(newEntry(traceparent("a"), "front-end", "orders", start, 100), newSummary("GET", "/orders", 200))
+	index.Add(newEntry(traceparent("b"), "orders", "carts", start+10, 20), newSummary("GET", "/carts", 200))
+	index.Add(newEntry(traceparent("c"), "orders", "payment", start+40, 30), newSummary("POST", "/payment", 200))
+
+	trace := index.Get(fmt.Sprintf("%032d", 7))
+	require.NotNil(t, trace)
+	require.Len(t, trace.Roots, 1)
+
+	children := trace.Roots[0].Children
+	require.Len(t, children, 2)
+	assert.Equal(t, "carts", children[0].Destination)
+	assert.Equal(t, int64(10), children[0].GapMs)
+	assert.Equal(t, "payment", children[1].Destination)
+	assert.Equal(t, int64(10), children[1].GapMs)
+}
+
+func TestGetRecent(t *testing.T) {
+	index := NewIndex()
+	requestId := func(id string) map[string]interface{} {
+		return map[string]interface{}{"x-request-id": id}
+	}
+
+	index.Add(newEntry(requestId("first"), "front-end", "orders", start, 50), newSummary("GET", "/orders?id=1", 200))
+	index.Add(newEntry(requestId("second"), "front-end", "carts", start+100, 10), newSummary("GET", "/carts", 503))
+	index.Add(newEntry(requestId("first"), "orders", "payment", start+10, 60), newSummary("POST", "/payment", 200))
+	index.Add(newEntry(map[string]interface{}{}, "front-end", "orders", start+200, 10), newSummary("GET", "/orders", 200))
+
+	summaries := index.GetRecent(DefaultLimit)
+	require.Len(t, summaries, 2)
+
+	assert.Equal(t, &Summary{
+		TraceId:      "first",
+		Propagation:  PropagationRequestId,
+		RootService:  "orders",
+		RootEndpoint: "GET /orders",
+		StartTime:    start,
+		Duration:     70,
+		SpanCount:    2,
+		Services:     []string{"front-end", "orders", "payment"},
+	}, summaries[0])
+	assert.Equal(t, "second", summaries[1].TraceId)
+	assert.True(t, summaries[1].Failed)
+
+	assert.Len(t, index.GetRecent(1), 1)
+	assert.Equal(t, []*Summary{summaries[1]}, index.GetSummaries([]string{"second", "unknown"}))
+}
+
+func TestSetEntryId(t *testing.T) {
+	index := NewIndex()
+	headers := map[string]interface{}{"x-request-id": "1"}
+
+	index.Add(newEntry(headers, "front-end", "orders", start, 50), newSummary("GET", "/orders", 200))
+
+	stored := newEntry(headers, "front-end", "orders", start, 50)
+	stored.Id = "42"
+	assert.Equal(t, "1", index.SetEntryId(stored, newSummary("GET", "/orders", 200)))
+	assert.Equal(t, "", index.SetEntryId(newEntry(map[string]interface{}{"x-request-id": "2"}, "front-end", "orders", start, 50), newSummary("GET", "/orders", 200)))
+
+	trace := index.Get("1")
+	require.Len(t, trace.Roots, 1)
+	assert.Equal(t, "42", trace.Roots[0].Id)
+}
+
+func TestAddEvictsLeastRecentlyUpdatedTrace(t *testing.T) {
+	index := NewIndex()
+	for i := 0; i <= maxTraces; i++ {
+		headers := map[string]interface{}{"x-request-id": fmt.Sprint(i)}
+		index.Add(newEntry(headers, "front-end", "orders", start+int64(i), 1), newSummary("GET", "/orders", 200))
+	}
+
+	assert.Nil(t, index.Get("0"))
+	assert.NotNil(t, index.Get("1"))
+	assert.NotNil(t, index.Get(fmt.Sprint(maxTraces)))
+}
+
+func TestAddEvictsLeastRecentlyUpdatedTracesBeyondMaxSpans(t *testing.T) {
+	index := NewIndex()
+	for i := 0; i < maxSpans/maxSpansPerTrace; i++ {
+		headers := map[string]interface{}{"x-request-id": fmt.Sprint(i)}
+		for j := 0; j < maxSpansPerTrace; j++ {
+			index.Add(newEntry(headers, "front-end", "orders", start+int64(j), 1), newSummary("GET", "/orders", 200))
+		}
+	}
+	require.NotNil(t, index.Get("0"))
+
+	index.Add(newEntry(map[string]interface{}{"x-request-id": "last"}, "front-end", "orders", start, 1), newSummary("GET", "/orders", 200))
+
+	assert.Nil(t, index.Get("0"))
+	assert.NotNil(t, index.Get("1"))
+	assert.NotNil(t, index.Get("last"))
+	assert.Equal(t, maxSpans-maxSpansPerTrace+1, index.spans)
+}
+
+func TestGetMissingIdsQuery(t *testing.T) {
+	index := NewIndex()
+	headers := map[string]interface{}{"x-request-id": "1"}
+
+	index.Add(newEntry(headers, "front-end", "orders", start, 50), newSummary("GET", "/orders", 200))
+	index.Add(newEntry(headers, "orders", "payment", start+10, 20), newSummary("POST", "/payment", 200))
+	index.Add(newEntry(headers, "orders", "carts", start+40, 5), newSummary("GET", "/carts", 200))
+
+	query, missing := index.GetMissingIdsQuery("1")
+	assert.Equal(t, 3, missing)
+	assert.Equal(t, fmt.Sprintf(`timestamp >= %d and timestamp <= %d and (src.ip == "10.0.0.1" or src.ip == "10.0.0.2")`, start, start+40), query)
+
+	stored := newEntry(headers, "orders", "carts", start+40, 5)
+	stored.Id = "42"
+	index.SetEntryId(stored, newSummary("GET", "/carts", 200))
+
+	query, missing = index.GetMissingIdsQuery("1")
+	assert.Equal(t, 2, missing)
+	assert.Equal(t, fmt.Sprintf(`timestamp >= %d and timestamp <= %d and (src.ip == "10.0.0.1" or src.ip == "10.0.0.2")`, start, start+10), query)
+
+	query, missing = index.GetMissingIdsQuery("unknown")
+	assert.Equal(t, 0, missing)
+	assert.Empty(t, query)
+}